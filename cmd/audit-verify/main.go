@@ -0,0 +1,38 @@
+// Command audit-verify checks the hash chain of a secret-keeper audit log.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"secret-keeper/internal/server/audit"
+)
+
+func main() {
+	path := flag.String("f", "audit.log", "path of the audit log")
+	key := flag.String("key", os.Getenv("SECRET_KEEPER_AUDIT_KEY"), "HMAC key of the audit log hash chain")
+	flag.Parse()
+
+	last, err := verify(*path, []byte(*key))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAIL: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("OK: %d entries, head %s\n", last.Seq, last.Hash)
+}
+
+func verify(path string, key []byte) (audit.Head, error) {
+	head, err := audit.ReadHead(audit.HeadPath(path))
+	if err != nil {
+		return audit.Head{}, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return audit.Head{}, err
+	}
+	defer f.Close()
+
+	return audit.Verify(f, key, head)
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"secret-keeper/pkg/api/server"
+	"strconv"
+	"strings"
+)
+
+// maxGatewayBody limits the size of a request body of the gateway
+const maxGatewayBody = 8 << 20
+
+// openAPIPath serves the OpenAPI document of the gateway
+const openAPIPath = "/v1/openapi.json"
+
+// gatewayRoute is the REST endpoint of a unary RPC
+type gatewayRoute struct {
+	method string
+	// path binds request fields to segments with {field}, a last
+	// {field...} takes the rest of the path so that keys may hold slashes
+	path string
+	rpc  string
+	// body is the request field the JSON body is decoded into, "*" for the
+	// whole request and empty for none. Fields not bound otherwise are
+	// taken from the query.
+	body string
+	// public endpoints need no bearer token
+	public bool
+
+	newRequest func() proto.Message
+	call       func(ctx context.Context, srv server.SecretKeeperServer, req proto.Message) (proto.Message, error)
+}
+
+// route returns the endpoint of rpc, call is its method expression on
+// server.SecretKeeperServer
+func route[Req, Resp proto.Message](method, path, rpc, body string, call func(server.SecretKeeperServer, context.Context, Req) (Resp, error)) gatewayRoute {
+	return gatewayRoute{
+		method: method,
+		path:   path,
+		rpc:    rpc,
+		body:   body,
+		newRequest: func() proto.Message {
+			var req Req
+			return req.ProtoReflect().Type().New().Interface()
+		},
+		call: func(ctx context.Context, srv server.SecretKeeperServer, req proto.Message) (proto.Message, error) {
+			resp, err := call(srv, ctx, req.(Req))
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		},
+	}
+}
+
+func (r gatewayRoute) withPublic() gatewayRoute {
+	r.public = true
+	return r
+}
+
+// gatewayRoutes are the REST endpoints of the SecretKeeper service. The
+// streaming RPCs are only served over gRPC.
+func gatewayRoutes() []gatewayRoute {
+	type s = server.SecretKeeperServer
+	return []gatewayRoute{
+		route(http.MethodPost, "/v1/auth", "Auth", "*", s.Auth).withPublic(),
+		route(http.MethodPost, "/v1/register", "Register", "*", s.Register).withPublic(),
+
+		route(http.MethodGet, "/v1/secrets", "ListSecrets", "", s.ListSecrets),
+		route(http.MethodGet, "/v1/secrets/{key...}", "Get", "", s.Get),
+		route(http.MethodPut, "/v1/secrets/{key...}", "Set", "*", s.Set),
+		route(http.MethodDelete, "/v1/secrets/{key...}", "Delete", "", s.Delete),
+		route(http.MethodGet, "/v1/names", "GetAllNames", "", s.GetAllNames),
+		route(http.MethodPut, "/v1/tags/{key...}", "SetTags", "*", s.SetTags),
+		route(http.MethodPost, "/v1/batch/get", "BatchGet", "*", s.BatchGet),
+		route(http.MethodPost, "/v1/batch/set", "BatchSet", "*", s.BatchSet),
+		route(http.MethodPost, "/v1/batch/delete", "BatchDelete", "*", s.BatchDelete),
+		route(http.MethodGet, "/v1/files", "ListFiles", "", s.ListFiles),
+		route(http.MethodDelete, "/v1/files/{key...}", "DeleteFile", "", s.DeleteFile),
+
+		route(http.MethodGet, "/v1/audit", "AuditLog", "", s.AuditLog),
+		route(http.MethodPost, "/v1/unlock", "Unlock", "*", s.Unlock).withPublic(),
+
+		route(http.MethodPost, "/v1/totp/enroll", "EnrollTOTP", "*", s.EnrollTOTP),
+		route(http.MethodPost, "/v1/totp/confirm", "ConfirmTOTP", "*", s.ConfirmTOTP),
+		route(http.MethodPost, "/v1/account/password", "ChangePassword", "*", s.ChangePassword),
+		route(http.MethodPost, "/v1/account/delete", "DeleteAccount", "*", s.DeleteAccount),
+		route(http.MethodGet, "/v1/usage", "GetUsage", "", s.GetUsage),
+
+		route(http.MethodPost, "/v1/shares", "ShareSecret", "*", s.ShareSecret),
+		route(http.MethodDelete, "/v1/shares/{recipient}/{key...}", "RevokeShare", "", s.RevokeShare),
+		route(http.MethodGet, "/v1/shares/with-me", "ListSharedWithMe", "", s.ListSharedWithMe),
+		route(http.MethodGet, "/v1/shares/by-me", "ListSharedByMe", "", s.ListSharedByMe),
+		route(http.MethodPost, "/v1/links", "CreateShareLink", "*", s.CreateShareLink),
+		route(http.MethodPost, "/v1/links/redeem", "RedeemShareLink", "*", s.RedeemShareLink).withPublic(),
+
+		route(http.MethodGet, "/v1/teams", "ListTeams", "", s.ListTeams),
+		route(http.MethodPost, "/v1/teams", "CreateTeam", "*", s.CreateTeam),
+		route(http.MethodGet, "/v1/teams/{team}", "GetTeam", "", s.GetTeam),
+		route(http.MethodDelete, "/v1/teams/{team}", "DeleteTeam", "", s.DeleteTeam),
+		route(http.MethodPut, "/v1/teams/{team}/members/{username}", "SetTeamMember", "*", s.SetTeamMember),
+		route(http.MethodDelete, "/v1/teams/{team}/members/{username}", "RemoveTeamMember", "", s.RemoveTeamMember),
+
+		// policies are managed with the admin token instead of a bearer token
+		route(http.MethodGet, "/v1/policies", "ListPolicies", "", s.ListPolicies).withPublic(),
+		route(http.MethodGet, "/v1/policies/{name}", "GetPolicy", "", s.GetPolicy).withPublic(),
+		route(http.MethodPut, "/v1/policies/{policy.name}", "PutPolicy", "policy", s.PutPolicy).withPublic(),
+		route(http.MethodDelete, "/v1/policies/{name}", "DeletePolicy", "", s.DeletePolicy).withPublic(),
+		route(http.MethodPost, "/v1/permissions/check", "CheckPermission", "*", s.CheckPermission),
+	}
+}
+
+// gateway serves the SecretKeeper service as REST endpoints with JSON
+// bodies. Calls go through the same handler and interceptors as gRPC calls:
+// the bearer token is passed on as the token metadata, X-Admin-Token as
+// admin-token, and status codes are mapped to HTTP statuses.
+type gateway struct {
+	srv         server.SecretKeeperServer
+	interceptor grpc.UnaryServerInterceptor
+	routes      []gatewayRoute
+	openAPI     []byte
+}
+
+func newGateway(srv server.SecretKeeperServer, interceptors ...grpc.UnaryServerInterceptor) (*gateway, error) {
+	routes := gatewayRoutes()
+	doc, err := openAPIDocument(routes)
+	if err != nil {
+		return nil, fmt.Errorf("openAPIDocument: %w", err)
+	}
+
+	return &gateway{
+		srv:         srv,
+		interceptor: chainUnary(interceptors...),
+		routes:      routes,
+		openAPI:     doc,
+	}, nil
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == openAPIPath && r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(g.openAPI)
+		return
+	}
+
+	rt, params, allowed := g.match(r.Method, r.URL.EscapedPath())
+	if rt.rpc == "" {
+		if len(allowed) != 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			writeGatewayStatus(w, http.StatusMethodNotAllowed, status.Newf(codes.Unimplemented, "method %s not allowed", r.Method))
+			return
+		}
+		writeGatewayError(w, status.Errorf(codes.NotFound, "no endpoint %s", r.URL.Path))
+		return
+	}
+
+	md := metadata.MD{}
+	token, hasToken := bearerToken(r)
+	if hasToken {
+		md.Set("token", token)
+	} else if !rt.public {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeGatewayError(w, status.Error(codes.Unauthenticated, "bearer token required"))
+		return
+	}
+	if v := r.Header.Get("X-Admin-Token"); v != "" {
+		md.Set("admin-token", v)
+	}
+	if v := r.Header.Get("X-Request-Id"); v != "" {
+		md.Set("x-request-id", v)
+	}
+
+	req := rt.newRequest()
+	if err := decodeRequest(w, r, rt, req, params); err != nil {
+		writeGatewayError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+
+	fullMethod := "/" + server.SecretKeeper_ServiceDesc.ServiceName + "/" + rt.rpc
+	stream := &gatewayStream{method: fullMethod, header: metadata.MD{}}
+
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+
+	info := &grpc.UnaryServerInfo{Server: g.srv, FullMethod: fullMethod}
+	resp, err := g.interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return rt.call(ctx, g.srv, req.(proto.Message))
+	})
+
+	for key, values := range stream.header {
+		// a failed call only tells when to retry, any other header such as
+		// a token must not leave with an error
+		if err != nil && key != "retry-after" && key != "x-request-id" {
+			continue
+		}
+		for _, v := range values {
+			w.Header().Add(gatewayHeader(key), v)
+		}
+	}
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+
+	b, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(resp.(proto.Message))
+	if err != nil {
+		writeGatewayError(w, status.Error(codes.Internal, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+// match returns the route of method and path with the values of its path
+// parameters. Without one it returns the methods allowed for path.
+func (g *gateway) match(method, path string) (gatewayRoute, map[string]string, []string) {
+	var allowed []string
+	for _, rt := range g.routes {
+		params, ok := matchPath(rt.path, path)
+		if !ok {
+			continue
+		}
+		if rt.method == method {
+			return rt, params, nil
+		}
+		allowed = append(allowed, rt.method)
+	}
+	return gatewayRoute{}, nil, allowed
+}
+
+// matchPath matches the escaped path against pattern
+func matchPath(pattern, path string) (map[string]string, bool) {
+	want := strings.Split(strings.Trim(pattern, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+
+	params := make(map[string]string)
+	for i, seg := range want {
+		if i >= len(got) {
+			return nil, false
+		}
+
+		if name, ok := paramName(seg); ok {
+			raw := got[i]
+			if strings.HasSuffix(name, "...") {
+				name = strings.TrimSuffix(name, "...")
+				raw = strings.Join(got[i:], "/")
+				got = got[:i+1]
+			}
+
+			v, err := pathUnescape(raw)
+			if err != nil || v == "" {
+				return nil, false
+			}
+			params[name] = v
+			continue
+		}
+
+		if seg != got[i] {
+			return nil, false
+		}
+	}
+	return params, len(got) == len(want)
+}
+
+// pathUnescape unescapes every segment of raw on its own, so that an
+// escaped slash stays part of its segment
+func pathUnescape(raw string) (string, error) {
+	segs := strings.Split(raw, "/")
+	for i, seg := range segs {
+		v, err := url.PathUnescape(seg)
+		if err != nil {
+			return "", err
+		}
+		segs[i] = v
+	}
+	return strings.Join(segs, "/"), nil
+}
+
+func paramName(seg string) (string, bool) {
+	if len(seg) > 2 && seg[0] == '{' && seg[len(seg)-1] == '}' {
+		return seg[1 : len(seg)-1], true
+	}
+	return "", false
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < len("Bearer ") || !strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(auth[len("Bearer "):])
+	return token, token != ""
+}
+
+// decodeRequest fills req from the body, the path parameters and the query
+// in this order
+func decodeRequest(w http.ResponseWriter, r *http.Request, rt gatewayRoute, req proto.Message, params map[string]string) error {
+	if rt.body != "" && r.Body != nil {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGatewayBody))
+		if err != nil {
+			return fmt.Errorf("body: %w", err)
+		}
+
+		if len(body) != 0 {
+			target := req.ProtoReflect()
+			if rt.body != "*" {
+				fd := target.Descriptor().Fields().ByName(protoreflect.Name(rt.body))
+				target = target.Mutable(fd).Message()
+			}
+			if err = protojson.Unmarshal(body, target.Interface()); err != nil {
+				return fmt.Errorf("body: %w", err)
+			}
+		}
+	}
+
+	for name, v := range params {
+		if err := setField(req.ProtoReflect(), name, v); err != nil {
+			return err
+		}
+	}
+
+	for name, values := range r.URL.Query() {
+		for _, v := range values {
+			if err := setField(req.ProtoReflect(), name, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setField sets the field at the dotted path in msg to v, appending to
+// repeated fields
+func setField(msg protoreflect.Message, path, v string) error {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		fd := findField(msg.Descriptor(), part)
+		if fd == nil || fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return fmt.Errorf("%s: unknown field", path)
+		}
+		msg = msg.Mutable(fd).Message()
+	}
+
+	fd := findField(msg.Descriptor(), parts[len(parts)-1])
+	if fd == nil || fd.IsMap() {
+		return fmt.Errorf("%s: unknown field", path)
+	}
+
+	value, err := parseValue(msg, fd, v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if fd.IsList() {
+		msg.Mutable(fd).List().Append(value)
+	} else {
+		msg.Set(fd, value)
+	}
+	return nil
+}
+
+func findField(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if fd := md.Fields().ByJSONName(name); fd != nil {
+		return fd
+	}
+	return md.Fields().ByName(protoreflect.Name(name))
+}
+
+// parseValue parses v the way it would be written in a query
+func parseValue(msg protoreflect.Message, fd protoreflect.FieldDescriptor, v string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(v), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(v)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(v, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(v, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(v, 10, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(v, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(v, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(v)
+		return protoreflect.ValueOfBytes(b), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(v)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("unknown value %q", v)
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	case protoreflect.MessageKind:
+		// well-known types such as durations and timestamps are written as
+		// their JSON strings
+		m := msg.NewField(fd).Message()
+		if err := protojson.Unmarshal([]byte(strconv.Quote(v)), m.Interface()); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfMessage(m), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field type %s", fd.Kind())
+}
+
+// gatewayHeader returns the HTTP header of a response metadata key
+func gatewayHeader(key string) string {
+	switch key {
+	case "retry-after":
+		return "Retry-After"
+	case "x-request-id":
+		return "X-Request-Id"
+	}
+	return "Grpc-Metadata-" + key
+}
+
+// writeGatewayError writes err as a status with the HTTP status of its code
+func writeGatewayError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeGatewayStatus(w, httpStatus(st.Code()), st)
+}
+
+func writeGatewayStatus(w http.ResponseWriter, code int, st *status.Status) {
+	b, err := protojson.Marshal(st.Proto())
+	if err != nil {
+		b = []byte(`{"code":13,"message":"failed to marshal error"}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
+
+// httpStatus maps a status code to the HTTP status of the same meaning
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		// the status nginx uses for clients closing the request
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// gatewayStream collects the metadata the handler sends
+type gatewayStream struct {
+	method string
+	header metadata.MD
+}
+
+func (s *gatewayStream) Method() string {
+	return s.method
+}
+
+func (s *gatewayStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *gatewayStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *gatewayStream) SetTrailer(md metadata.MD) error {
+	return nil
+}
+
+// chainUnary chains interceptors the way grpc.ChainUnaryInterceptor does,
+// the first one outermost
+func chainUnary(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next, interceptor := handler, interceptors[i]
+			handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"secret-keeper/pkg/api/server"
+	"strings"
+	"testing"
+)
+
+// gatewayServer records the requests of the RPCs the gateway tests call
+type gatewayServer struct {
+	server.UnimplementedSecretKeeperServer
+	token     string
+	gets      map[string]*server.GetRequest
+	putPolicy *server.PutPolicyRequest
+}
+
+func (s *gatewayServer) Auth(ctx context.Context, req *server.AuthRequest) (*server.AuthResponse, error) {
+	if err := grpc.SetHeader(ctx, metadata.Pairs("token", "t-"+req.Username)); err != nil {
+		return nil, err
+	}
+	return &server.AuthResponse{Token: "t-" + req.Username}, nil
+}
+
+func (s *gatewayServer) Register(ctx context.Context, req *server.RegisterRequest) (*server.RegisterResponse, error) {
+	if err := grpc.SetHeader(ctx, metadata.Pairs("token", "t-"+req.Username, "retry-after", "1")); err != nil {
+		return nil, err
+	}
+	return nil, status.Error(codes.AlreadyExists, "user exists")
+}
+
+func (s *gatewayServer) Get(ctx context.Context, req *server.GetRequest) (*server.GetResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("token"); len(values) != 0 {
+		s.token = values[0]
+	}
+	s.gets[req.Key] = req
+
+	if req.Key == "missing" {
+		return nil, status.Error(codes.NotFound, "secret not found")
+	}
+	return &server.GetResponse{Value: "value of " + req.Key}, nil
+}
+
+func (s *gatewayServer) PutPolicy(ctx context.Context, req *server.PutPolicyRequest) (*server.PutPolicyResponse, error) {
+	s.putPolicy = req
+	return &server.PutPolicyResponse{}, nil
+}
+
+func TestGatewayRoutes(t *testing.T) {
+	routes := make(map[string]int)
+	for _, rt := range gatewayRoutes() {
+		routes[rt.rpc]++
+	}
+
+	methods := server.File_api_proto_server_proto.Services().ByName("SecretKeeper").Methods()
+	for i := 0; i < methods.Len(); i++ {
+		method := methods.Get(i)
+		want := 1
+		if method.IsStreamingClient() || method.IsStreamingServer() {
+			want = 0
+		}
+		if got := routes[string(method.Name())]; got != want {
+			t.Errorf("%s has %d routes, want %d", method.Name(), got, want)
+		}
+	}
+}
+
+func TestGateway(t *testing.T) {
+	srv := &gatewayServer{gets: make(map[string]*server.GetRequest)}
+	var intercepted []string
+	gw, err := newGateway(srv, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		intercepted = append(intercepted, info.FullMethod)
+		return handler(ctx, req)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantHeader map[string]string
+	}{
+		{
+			name:       "get",
+			method:     http.MethodGet,
+			path:       "/v1/secrets/prod/db%2Fprimary?team=ops",
+			token:      "secret-token",
+			wantStatus: http.StatusOK,
+			wantBody:   `"value":"value of prod/db/primary"`,
+		},
+		{
+			name:       "notFound",
+			method:     http.MethodGet,
+			path:       "/v1/secrets/missing",
+			token:      "secret-token",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `"code":5`,
+		},
+		{
+			name:       "withoutToken",
+			method:     http.MethodGet,
+			path:       "/v1/secrets/db",
+			wantStatus: http.StatusUnauthorized,
+			wantHeader: map[string]string{"WWW-Authenticate": "Bearer"},
+		},
+		{
+			name:       "unknownQuery",
+			method:     http.MethodGet,
+			path:       "/v1/secrets/db?color=red",
+			token:      "secret-token",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "public",
+			method:     http.MethodPost,
+			path:       "/v1/auth",
+			body:       `{"username":"alice","password":"secret"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"token":"t-alice"`,
+			wantHeader: map[string]string{"Grpc-Metadata-Token": "t-alice"},
+		},
+		{
+			name:       "noHeadersOnError",
+			method:     http.MethodPost,
+			path:       "/v1/register",
+			body:       `{"username":"alice","password":"secret"}`,
+			wantStatus: http.StatusConflict,
+			wantHeader: map[string]string{"Grpc-Metadata-Token": "", "Retry-After": "1"},
+		},
+		{
+			name:       "invalidBody",
+			method:     http.MethodPost,
+			path:       "/v1/auth",
+			body:       `{"username":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "bodyField",
+			method:     http.MethodPut,
+			path:       "/v1/policies/readers",
+			body:       `{"rules":[{"path":"prod/**","capabilities":["read"]}]}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unimplemented",
+			method:     http.MethodGet,
+			path:       "/v1/usage",
+			token:      "secret-token",
+			wantStatus: http.StatusNotImplemented,
+		},
+		{
+			name:       "methodNotAllowed",
+			method:     http.MethodPost,
+			path:       "/v1/secrets/db",
+			token:      "secret-token",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantHeader: map[string]string{"Allow": "GET, PUT, DELETE"},
+		},
+		{
+			name:       "noEndpoint",
+			method:     http.MethodGet,
+			path:       "/v2/secrets",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			gw.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+			for key, want := range tt.wantHeader {
+				if got := rec.Header().Get(key); got != want {
+					t.Errorf("header %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+
+	if get := srv.gets["prod/db/primary"]; srv.token != "secret-token" || get.GetTeam() != "ops" {
+		t.Errorf("Get() got token %q and team %q, want the bearer token and the query", srv.token, get.GetTeam())
+	}
+	if srv.putPolicy.GetPolicy().GetName() != "readers" || len(srv.putPolicy.GetPolicy().GetRules()) != 1 {
+		t.Errorf("PutPolicy() got %v, want the name from the path and the rules from the body", srv.putPolicy)
+	}
+	if len(intercepted) == 0 || intercepted[0] != "/api.SecretKeeper/Get" {
+		t.Errorf("intercepted %v, want the full gRPC methods", intercepted)
+	}
+}
+
+func TestGateway_OpenAPI(t *testing.T) {
+	gw, err := newGateway(&gatewayServer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, openAPIPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	body, _ := io.ReadAll(rec.Body)
+	if err = json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/v1/secrets/{key}", "/v1/teams/{team}/members/{username}", "/v1/policies/{policy.name}"} {
+		if len(doc.Paths[path]) == 0 {
+			t.Errorf("paths has no %s", path)
+		}
+	}
+	for _, schema := range []string{"api.GetResponse", "api.Policy", "google.rpc.Status"} {
+		if _, ok := doc.Components.Schemas[schema]; !ok {
+			t.Errorf("schemas has no %s", schema)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"secret-keeper/pkg"
+	"secret-keeper/pkg/api/server"
+	"time"
+)
+
+// pinger checks that a dependency of the server is reachable.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// watchStorage pings the storage every interval and publishes the result as
+// the serving status of the server until ctx is done.
+func watchStorage(ctx context.Context, logger pkg.Logger, healthServer *health.Server, store pinger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last healthpb.HealthCheckResponse_ServingStatus
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		if err := ping(ctx, store, interval); err != nil {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+			if last != status {
+				logger.Warn("storage is unavailable", pkg.Err(err))
+			}
+		} else if last == healthpb.HealthCheckResponse_NOT_SERVING {
+			logger.Info("storage is available again")
+		}
+		last = status
+
+		healthServer.SetServingStatus("", status)
+		healthServer.SetServingStatus(server.SecretKeeper_ServiceDesc.ServiceName, status)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func ping(ctx context.Context, store pinger, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return store.Ping(ctx)
+}
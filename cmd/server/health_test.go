@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"secret-keeper/pkg"
+	"secret-keeper/pkg/api/server"
+	"sync"
+	"testing"
+	"time"
+)
+
+type pingerStub struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (p *pingerStub) Ping(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *pingerStub) setErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func waitForStatus(t *testing.T, healthServer *health.Server, service string, want healthpb.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+
+	var got healthpb.HealthCheckResponse_ServingStatus
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		resp, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			// the service is unknown until the first status is published
+			continue
+		}
+		if got = resp.Status; got == want {
+			return
+		}
+	}
+	t.Fatalf("health status of %q = %v, want %v", service, got, want)
+}
+
+func Test_watchStorage(t *testing.T) {
+	healthServer := health.NewServer()
+	store := &pingerStub{}
+	store.setErr(errors.New("connection refused"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go watchStorage(ctx, pkg.NewNop(), healthServer, store, 5*time.Millisecond)
+
+	service := server.SecretKeeper_ServiceDesc.ServiceName
+	waitForStatus(t, healthServer, service, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	store.setErr(nil)
+	waitForStatus(t, healthServer, service, healthpb.HealthCheckResponse_SERVING)
+	waitForStatus(t, healthServer, "", healthpb.HealthCheckResponse_SERVING)
+
+	store.setErr(errors.New("connection refused"))
+	waitForStatus(t, healthServer, "", healthpb.HealthCheckResponse_NOT_SERVING)
+}
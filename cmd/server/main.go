@@ -1,17 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"secret-keeper/internal/server/audit"
 	"secret-keeper/internal/server/config"
 	grpchandler "secret-keeper/internal/server/handler/grpc"
+	vaulthandler "secret-keeper/internal/server/handler/vault"
+	"secret-keeper/internal/server/lockout"
+	"secret-keeper/internal/server/metrics"
 	"secret-keeper/internal/server/storage"
 	"secret-keeper/internal/server/usecase"
+	"secret-keeper/internal/server/validate"
+	"secret-keeper/internal/server/watch"
+	"secret-keeper/pkg"
 	"secret-keeper/pkg/api/server"
 	"syscall"
+	"time"
 )
 
 func main() {
@@ -20,37 +34,219 @@ func main() {
 		log.Fatalf("failed to initialize config: %v", err)
 	}
 
-	store, err := storage.New(cfg.DBConfig)
+	if cfg.PrintConfig {
+		if err = cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("failed to print config: %v", err)
+		}
+		return
+	}
+
+	logger, err := pkg.NewZap(cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		log.Fatalf("failed to initialize logger: %v", err)
+	}
+
+	m := metrics.New()
+
+	store, err := storage.New(cfg.DBConfig, storage.WithObserver(m), storage.WithLogger(logger))
 	if err != nil {
-		log.Fatalf("failed to initialize storage: %v", err)
+		logger.Fatal("failed to initialize storage", pkg.Err(err))
 	}
+	m.RegisterIssuedTokens(store.CountTokens)
 
-	logic, err := usecase.New(store)
+	validator, err := validate.New(validate.Config{
+		UsernamePattern:   cfg.UsernamePattern,
+		PasswordMinLength: cfg.PasswordMinLength,
+		PasswordMaxLength: cfg.PasswordMaxLength,
+		KeyMaxLength:      cfg.KeyMaxLength,
+		ValueMaxSize:      cfg.ValueMaxSize,
+	})
 	if err != nil {
-		log.Fatalf("failed to initialize logic: %v", err)
+		logger.Fatal("failed to initialize validator", pkg.Err(err))
 	}
 
-	go func() {
-		log.Println("Server is running on grpc://" + cfg.Host)
-		grpcServer := grpc.NewServer()
-		ghandler := grpchandler.New(logic)
+	hub := watch.New(cfg.WatchHistory)
 
-		lis, err := net.Listen("tcp", cfg.Host)
+	opts := []usecase.Option{
+		usecase.WithValidator(validator),
+		usecase.WithQuota(usecase.Quota{MaxSecrets: cfg.MaxSecretsPerUser, MaxBytes: cfg.MaxBytesPerUser}),
+		usecase.WithLimiter(lockout.New(lockout.Config{
+			MaxAttempts: cfg.LoginMaxAttempts,
+			Backoff:     cfg.LoginBackoff,
+			Lockout:     cfg.LoginLockout,
+		})),
+		usecase.WithAdminToken(cfg.AdminToken),
+		usecase.WithExpiryWarning(cfg.ExpiryWarning),
+		usecase.WithWatch(hub),
+		usecase.WithMaxFileSize(int64(cfg.MaxFileSize)),
+	}
+	var auditLog *audit.Log
+	if cfg.AuditLog != "" {
+		auditLog, err = audit.Open(cfg.AuditLog, []byte(cfg.AuditKey))
 		if err != nil {
-			log.Fatalf("failed to listen: %v", err)
+			logger.Fatal("failed to open audit log", pkg.Err(err))
 		}
-		server.RegisterSecretKeeperServer(grpcServer, ghandler)
+		opts = append(opts, usecase.WithAudit(auditLog))
+	}
+
+	logic, err := usecase.New(store, logger, opts...)
+	if err != nil {
+		logger.Fatal("failed to initialize logic", pkg.Err(err))
+	}
+
+	lis, err := net.Listen("tcp", cfg.Host)
+	if err != nil {
+		logger.Fatal("failed to listen", pkg.Err(err))
+	}
+
+	ghandler := grpchandler.New(logic, logger)
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(ghandler.UnaryLoggingInterceptor(), m.UnaryServerInterceptor(), ghandler.UnaryAuditInterceptor()),
+		grpc.ChainStreamInterceptor(ghandler.StreamLoggingInterceptor(), m.StreamServerInterceptor(), ghandler.StreamAuditInterceptor()),
+	)
+	server.RegisterSecretKeeperServer(grpcServer, ghandler)
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	if cfg.Reflection {
+		reflection.Register(grpcServer)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		err = grpcServer.Serve(lis)
+	go watchStorage(ctx, logger, healthServer, store, cfg.HealthCheckInterval)
+	go sweepExpired(ctx, logger, logic, cfg.SweepInterval)
+
+	go func() {
+		logger.Info("Server is running on grpc://" + cfg.Host)
+		err := grpcServer.Serve(lis)
 		if err != nil {
-			log.Fatalf("grpcServer Serve: %v", err)
+			logger.Fatal("grpcServer Serve", pkg.Err(err))
 		}
 	}()
 
+	var metricsServer *http.Server
+	if cfg.MetricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		metricsServer = &http.Server{Addr: cfg.MetricsAddress, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+		go func() {
+			logger.Info("Metrics are served on http://" + cfg.MetricsAddress + "/metrics")
+			err := metricsServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Fatal("metricsServer ListenAndServe", pkg.Err(err))
+			}
+		}()
+	}
+
+	var gatewayServer *http.Server
+	if cfg.GatewayAddress != "" {
+		gw, err := newGateway(ghandler, ghandler.UnaryLoggingInterceptor(), m.UnaryServerInterceptor(), ghandler.UnaryAuditInterceptor())
+		if err != nil {
+			logger.Fatal("failed to initialize gateway", pkg.Err(err))
+		}
+		gatewayServer = &http.Server{Addr: cfg.GatewayAddress, Handler: gw, ReadHeaderTimeout: 5 * time.Second}
+
+		go func() {
+			logger.Info("Gateway is running on http://" + cfg.GatewayAddress + "/v1")
+			err := gatewayServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Fatal("gatewayServer ListenAndServe", pkg.Err(err))
+			}
+		}()
+	}
+
+	var vaultServer *http.Server
+	if cfg.VaultAddress != "" {
+		vh := vaulthandler.New(logic, logger, cfg.VaultMount, chainUnary(ghandler.UnaryLoggingInterceptor(), m.UnaryServerInterceptor(), ghandler.UnaryAuditInterceptor()))
+		vaultServer = &http.Server{Addr: cfg.VaultAddress, Handler: vh, ReadHeaderTimeout: 5 * time.Second}
+
+		go func() {
+			logger.Info("Vault compatible API is running on http://" + cfg.VaultAddress + "/v1/" + cfg.VaultMount)
+			err := vaultServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Fatal("vaultServer ListenAndServe", pkg.Err(err))
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	<-quit
 
-	log.Println("Shutdown Server ...")
+	logger.Info("Shutdown Server ...")
+	cancel()
+	// watches never end by themselves, closing the hub ends them with
+	// UNAVAILABLE so that clients reconnect to another instance
+	hub.Close()
+
+	if gatewayServer != nil {
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		if err = gatewayServer.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("failed to shutdown gateway", pkg.Err(err))
+		}
+		cancelShutdown()
+	}
+
+	if vaultServer != nil {
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		if err = vaultServer.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("failed to shutdown vault compatible API", pkg.Err(err))
+		}
+		cancelShutdown()
+	}
+
+	if !gracefulStop(grpcServer, healthServer, cfg.ShutdownTimeout) {
+		logger.Warn("shutdown timeout exceeded, in-flight requests were cancelled", pkg.Duration("timeout", cfg.ShutdownTimeout))
+	}
+
+	if metricsServer != nil {
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		if err = metricsServer.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("failed to shutdown metrics server", pkg.Err(err))
+		}
+		cancelShutdown()
+	}
+
+	if err = store.Close(); err != nil {
+		logger.Warn("failed to close storage", pkg.Err(err))
+	}
+
+	if auditLog != nil {
+		if err = auditLog.Close(); err != nil {
+			logger.Warn("failed to close audit log", pkg.Err(err))
+		}
+	}
+
+	logger.Info("Server exited")
+}
+
+// gracefulStop marks the server as NOT_SERVING so that load balancers stop
+// routing new requests to it, then waits for in-flight RPCs to finish.
+// If they are still running after timeout the server is stopped forcibly
+// and false is returned.
+func gracefulStop(grpcServer *grpc.Server, healthServer *health.Server, timeout time.Duration) bool {
+	healthServer.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		grpcServer.Stop()
+		<-done
+		return false
+	}
 }
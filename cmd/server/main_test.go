@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"secret-keeper/internal/server/audit"
+	grpchandler "secret-keeper/internal/server/handler/grpc"
+	"secret-keeper/internal/server/policy"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/usecase"
+	"secret-keeper/internal/server/watch"
+	"secret-keeper/pkg"
+	"secret-keeper/pkg/api/server"
+	"testing"
+	"time"
+)
+
+// blockingUseCase blocks every Set until release is closed.
+type blockingUseCase struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingUseCase) Get(ctx context.Context, key string) (string, error) {
+	return "", nil
+}
+
+func (b *blockingUseCase) Set(ctx context.Context, key, value string, expiresAt time.Time) error {
+	b.entered <- struct{}{}
+	select {
+	case <-b.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *blockingUseCase) Auth(ctx context.Context, username, password, code string) (string, error) {
+	return "", nil
+}
+
+func (b *blockingUseCase) Register(ctx context.Context, username string, password string) (string, error) {
+	return "", nil
+}
+
+func (b *blockingUseCase) CurrentUser(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+func (b *blockingUseCase) GetAllNames(ctx context.Context) ([]usecase.SecretInfo, error) {
+	return nil, nil
+}
+
+func (b *blockingUseCase) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+func (b *blockingUseCase) AuditLog(ctx context.Context, limit int) ([]audit.Entry, error) {
+	return nil, nil
+}
+
+func (b *blockingUseCase) Unlock(ctx context.Context, username, peerIP string) (bool, error) {
+	return false, nil
+}
+
+func (b *blockingUseCase) EnrollTOTP(ctx context.Context) (usecase.Enrollment, error) {
+	return usecase.Enrollment{}, nil
+}
+
+func (b *blockingUseCase) ConfirmTOTP(ctx context.Context, code string) error {
+	return nil
+}
+
+func (b *blockingUseCase) ChangePassword(ctx context.Context, oldPassword, newPassword string) (int, error) {
+	return 0, nil
+}
+
+func (b *blockingUseCase) DeleteAccount(ctx context.Context, password, code string) error {
+	return nil
+}
+
+func (b *blockingUseCase) GetUsage(ctx context.Context) (usecase.Usage, error) {
+	return usecase.Usage{}, nil
+}
+
+func (b *blockingUseCase) ShareSecret(ctx context.Context, key, recipient, mode string) error {
+	return nil
+}
+
+func (b *blockingUseCase) RevokeShare(ctx context.Context, key, recipient string) (bool, error) {
+	return false, nil
+}
+
+func (b *blockingUseCase) ListSharedWithMe(ctx context.Context) ([]storage.Share, error) {
+	return nil, nil
+}
+
+func (b *blockingUseCase) ListSharedByMe(ctx context.Context) ([]storage.Share, error) {
+	return nil, nil
+}
+
+func (b *blockingUseCase) GetShared(ctx context.Context, owner, key string) (string, error) {
+	return "", nil
+}
+
+func (b *blockingUseCase) SetShared(ctx context.Context, owner, key, value string, expiresAt time.Time) error {
+	return nil
+}
+
+func (b *blockingUseCase) CreateTeam(ctx context.Context, team string) error {
+	return nil
+}
+
+func (b *blockingUseCase) DeleteTeam(ctx context.Context, team string) error {
+	return nil
+}
+
+func (b *blockingUseCase) ListTeams(ctx context.Context) ([]usecase.Membership, error) {
+	return nil, nil
+}
+
+func (b *blockingUseCase) GetTeam(ctx context.Context, team string) (storage.Team, error) {
+	return storage.Team{}, nil
+}
+
+func (b *blockingUseCase) SetTeamMember(ctx context.Context, team, member, role string) error {
+	return nil
+}
+
+func (b *blockingUseCase) RemoveTeamMember(ctx context.Context, team, member string) error {
+	return nil
+}
+
+func (b *blockingUseCase) TeamGet(ctx context.Context, team, key string) (string, error) {
+	return "", nil
+}
+
+func (b *blockingUseCase) TeamSet(ctx context.Context, team, key, value string, expiresAt time.Time) error {
+	return nil
+}
+
+func (b *blockingUseCase) TeamDelete(ctx context.Context, team, key string) error {
+	return nil
+}
+
+func (b *blockingUseCase) TeamGetAllNames(ctx context.Context, team string) ([]usecase.SecretInfo, error) {
+	return nil, nil
+}
+
+func (b *blockingUseCase) PutPolicy(ctx context.Context, p policy.Policy) error {
+	return nil
+}
+
+func (b *blockingUseCase) GetPolicy(ctx context.Context, name string) (policy.Policy, error) {
+	return policy.Policy{}, nil
+}
+
+func (b *blockingUseCase) DeletePolicy(ctx context.Context, name string) error {
+	return nil
+}
+
+func (b *blockingUseCase) ListPolicies(ctx context.Context) ([]policy.Policy, error) {
+	return nil, nil
+}
+
+func (b *blockingUseCase) CheckPermission(ctx context.Context, username, key, capability string) (policy.Decision, error) {
+	return policy.Decision{}, nil
+}
+
+func (b *blockingUseCase) CreateShareLink(ctx context.Context, key string, ttl time.Duration, maxViews int, passphrase string) (usecase.ShareLink, error) {
+	return usecase.ShareLink{}, nil
+}
+
+func (b *blockingUseCase) RedeemShareLink(ctx context.Context, token, passphrase string) (usecase.RedeemedLink, error) {
+	return usecase.RedeemedLink{}, nil
+}
+
+func (b *blockingUseCase) ListSecrets(ctx context.Context, opts usecase.ListOptions) (usecase.SecretPage, error) {
+	return usecase.SecretPage{}, nil
+}
+
+func (b *blockingUseCase) SetTags(ctx context.Context, key string, tags []string) error {
+	return nil
+}
+
+func (b *blockingUseCase) BatchGet(ctx context.Context, keys []string) ([]usecase.BatchResult, error) {
+	return nil, nil
+}
+
+func (b *blockingUseCase) BatchSet(ctx context.Context, items []usecase.BatchItem, transactional bool) ([]usecase.BatchResult, error) {
+	return nil, nil
+}
+
+func (b *blockingUseCase) BatchDelete(ctx context.Context, keys []string) ([]usecase.BatchResult, error) {
+	return nil, nil
+}
+
+func (b *blockingUseCase) Upload(ctx context.Context, key, sum string, next func() ([]byte, error)) (usecase.FileInfo, error) {
+	return usecase.FileInfo{}, nil
+}
+
+func (b *blockingUseCase) Download(ctx context.Context, key string, start func(usecase.FileInfo) error, send func([]byte) error) error {
+	return nil
+}
+
+func (b *blockingUseCase) ListFiles(ctx context.Context) ([]usecase.FileInfo, error) {
+	return nil, nil
+}
+
+func (b *blockingUseCase) DeleteFile(ctx context.Context, key string) error {
+	return nil
+}
+
+func (b *blockingUseCase) Watch(ctx context.Context, key string, prefix bool, after uint64, send func(watch.Event) error) error {
+	return nil
+}
+
+func (b *blockingUseCase) AuditReady() error {
+	return nil
+}
+
+func upTestServer(t *testing.T, logic *blockingUseCase) (*grpc.Server, *health.Server, server.SecretKeeperClient) {
+	lis := bufconn.Listen(1024 * 1024)
+
+	grpcServer := grpc.NewServer()
+	server.RegisterSecretKeeperServer(grpcServer, grpchandler.New(logic, pkg.NewNop()))
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			t.Errorf("grpcServer Serve: %v", err)
+		}
+	}()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return grpcServer, healthServer, server.NewSecretKeeperClient(conn)
+}
+
+func Test_gracefulStop(t *testing.T) {
+	logic := &blockingUseCase{entered: make(chan struct{}, 1), release: make(chan struct{})}
+	grpcServer, healthServer, cl := upTestServer(t, logic)
+
+	setErr := make(chan error, 1)
+	go func() {
+		_, err := cl.Set(context.Background(), &server.SetRequest{Key: "key", Value: "value"})
+		setErr <- err
+	}()
+	<-logic.entered
+
+	stopped := make(chan bool, 1)
+	go func() {
+		stopped <- gracefulStop(grpcServer, healthServer, 5*time.Second)
+	}()
+
+	for deadline := time.Now().Add(time.Second); ; {
+		resp, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status == healthpb.HealthCheckResponse_NOT_SERVING {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("health status = %v, want NOT_SERVING", resp.Status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case <-stopped:
+		t.Fatal("gracefulStop() returned before in-flight Set completed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(logic.release)
+
+	if err := <-setErr; err != nil {
+		t.Errorf("Set() error = %v, want nil", err)
+	}
+	if ok := <-stopped; !ok {
+		t.Errorf("gracefulStop() = %v, want true", ok)
+	}
+}
+
+func Test_gracefulStopTimeout(t *testing.T) {
+	logic := &blockingUseCase{entered: make(chan struct{}, 1), release: make(chan struct{})}
+	grpcServer, healthServer, cl := upTestServer(t, logic)
+
+	setErr := make(chan error, 1)
+	go func() {
+		_, err := cl.Set(context.Background(), &server.SetRequest{Key: "key", Value: "value"})
+		setErr <- err
+	}()
+	<-logic.entered
+
+	if ok := gracefulStop(grpcServer, healthServer, 50*time.Millisecond); ok {
+		t.Errorf("gracefulStop() = %v, want false", ok)
+	}
+	if err := <-setErr; err == nil {
+		t.Error("Set() error = nil, want error after forced stop")
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/http"
+	"secret-keeper/pkg/api/server"
+	"strings"
+)
+
+// openAPIDocument describes routes in OpenAPI 3.0, taking the schemas of
+// their requests and responses from the proto descriptors
+func openAPIDocument(routes []gatewayRoute) ([]byte, error) {
+	service := server.File_api_proto_server_proto.Services().ByName("SecretKeeper")
+	if service == nil {
+		return nil, fmt.Errorf("service SecretKeeper not found")
+	}
+
+	schemas := make(map[string]interface{})
+	paths := make(map[string]map[string]interface{})
+	errorSchema := schemaRef(schemas, (&status.Status{}).ProtoReflect().Descriptor())
+
+	for _, rt := range routes {
+		method := service.Methods().ByName(protoreflect.Name(rt.rpc))
+		if method == nil {
+			return nil, fmt.Errorf("%s: no such rpc", rt.rpc)
+		}
+
+		path, bound := openAPIPathParams(rt.path)
+		op := map[string]interface{}{
+			"operationId": rt.rpc,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content":     jsonContent(schemaRef(schemas, method.Output())),
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content":     jsonContent(errorSchema),
+				},
+			},
+		}
+		if rt.public {
+			op["security"] = []interface{}{}
+		}
+
+		var params []interface{}
+		for _, name := range bound {
+			params = append(params, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+
+		input := method.Input()
+		switch rt.body {
+		case "":
+			params = append(params, queryParams(input, bound)...)
+		case "*":
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaRef(schemas, input)),
+			}
+		default:
+			fd := input.Fields().ByName(protoreflect.Name(rt.body))
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaRef(schemas, fd.Message())),
+			}
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+		paths[path][strings.ToLower(rt.method)] = op
+	}
+
+	paths[openAPIPath] = map[string]interface{}{
+		strings.ToLower(http.MethodGet): map[string]interface{}{
+			"operationId": "OpenAPI",
+			"security":    []interface{}{},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "This document",
+					"content":     jsonContent(map[string]interface{}{"type": "object"}),
+				},
+			},
+		},
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "SecretKeeper",
+			"version": "v1",
+		},
+		"paths":    paths,
+		"security": []interface{}{map[string]interface{}{"bearer": []interface{}{}}},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"admin":  map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-Admin-Token"},
+			},
+		},
+	}, "", "  ")
+}
+
+// openAPIPathParams returns the path of the pattern in OpenAPI, where a
+// parameter cannot take the rest of the path, and its parameters
+func openAPIPathParams(pattern string) (string, []string) {
+	segs := strings.Split(pattern, "/")
+	var params []string
+	for i, seg := range segs {
+		if name, ok := paramName(seg); ok {
+			name = strings.TrimSuffix(name, "...")
+			params = append(params, name)
+			segs[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segs, "/"), params
+}
+
+// queryParams describes the fields of md not bound to the path as query
+// parameters
+func queryParams(md protoreflect.MessageDescriptor, bound []string) []interface{} {
+	var params []interface{}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsMap() || contains(bound, string(fd.Name())) {
+			continue
+		}
+		if fd.Kind() == protoreflect.MessageKind && wellKnownSchema(fd.Message()) == nil {
+			continue
+		}
+
+		params = append(params, map[string]interface{}{
+			"name":   fd.JSONName(),
+			"in":     "query",
+			"schema": fieldSchema(nil, fd),
+		})
+	}
+	return params
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// schemaRef adds the schema of md to schemas and returns a reference to it
+func schemaRef(schemas map[string]interface{}, md protoreflect.MessageDescriptor) interface{} {
+	if schema := wellKnownSchema(md); schema != nil {
+		return schema
+	}
+
+	name := string(md.FullName())
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	if _, ok := schemas[name]; ok {
+		return ref
+	}
+
+	properties := make(map[string]interface{})
+	schemas[name] = map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		properties[fd.JSONName()] = fieldSchema(schemas, fd)
+	}
+	return ref
+}
+
+// fieldSchema returns the schema of fd as protojson writes it
+func fieldSchema(schemas map[string]interface{}, fd protoreflect.FieldDescriptor) interface{} {
+	if fd.IsMap() {
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": fieldSchema(schemas, fd.MapValue()),
+		}
+	}
+
+	schema := kindSchema(schemas, fd)
+	if fd.IsList() {
+		return map[string]interface{}{"type": "array", "items": schema}
+	}
+	return schema
+}
+
+func kindSchema(schemas map[string]interface{}, fd protoreflect.FieldDescriptor) interface{} {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]interface{}{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson writes 64-bit integers as strings
+		return map[string]interface{}{"type": "string", "format": "int64"}
+	case protoreflect.FloatKind:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		names := make([]interface{}, values.Len())
+		for i := range names {
+			names[i] = string(values.Get(i).Name())
+		}
+		return map[string]interface{}{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if schemas == nil {
+			return wellKnownSchema(fd.Message())
+		}
+		return schemaRef(schemas, fd.Message())
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+// wellKnownSchema returns the schema of the well-known types protojson
+// writes as strings or free-form objects
+func wellKnownSchema(md protoreflect.MessageDescriptor) interface{} {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return map[string]interface{}{"type": "string", "example": "3600s"}
+	case "google.protobuf.Any":
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           map[string]interface{}{"@type": map[string]interface{}{"type": "string"}},
+			"additionalProperties": true,
+		}
+	}
+	return nil
+}
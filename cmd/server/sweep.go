@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"secret-keeper/pkg"
+	"time"
+)
+
+// sweeper deletes what expired.
+type sweeper interface {
+	SweepExpired(ctx context.Context) (int, error)
+}
+
+// sweepExpired sweeps every interval until ctx is done. A failed sweep is
+// logged and retried with the next one.
+func sweepExpired(ctx context.Context, logger pkg.Logger, s sweeper, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		removed, err := s.SweepExpired(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Warn("failed to sweep expired secrets", pkg.Err(err))
+			}
+			continue
+		}
+		if removed != 0 {
+			logger.Info("expired secrets deleted", pkg.String("count", fmt.Sprint(removed)))
+		}
+	}
+}
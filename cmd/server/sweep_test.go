@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"secret-keeper/pkg"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type sweeperStub struct {
+	sweeps atomic.Int32
+}
+
+func (s *sweeperStub) SweepExpired(ctx context.Context) (int, error) {
+	if s.sweeps.Add(1) == 1 {
+		return 0, errors.New("connection refused")
+	}
+	return 1, nil
+}
+
+func Test_sweepExpired(t *testing.T) {
+	s := &sweeperStub{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sweepExpired(ctx, pkg.NewNop(), s, 5*time.Millisecond)
+		close(done)
+	}()
+
+	// a failed sweep does not stop the next ones
+	for deadline := time.Now().Add(time.Second); s.sweeps.Load() < 3; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("swept %d times, want at least 3", s.sweeps.Load())
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweepExpired() did not return once ctx was done")
+	}
+}
@@ -8,12 +8,17 @@ import (
 	"github.com/erikgeiser/promptkit/textinput"
 	"log"
 	"secret-keeper/internal/client/usecase"
+	"secret-keeper/pkg/api/server"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // CLI is the command line interface
 type CLI struct {
 	logic *usecase.UseCase
+	// team whose vault is in use, empty for the personal one
+	team string
 }
 
 // ErrExit is the exit error
@@ -28,30 +33,104 @@ const (
 	get  = "GET ◀️"
 	set  = "SET ▶️"
 	del  = "DELETE 🗑"
+	tfa  = "2FA 🔐"
+	pwd  = "PASSWORD 🔑"
+	drop = "DELETE ACCOUNT ⚠️"
+	use  = "USAGE 📊"
+	shr  = "SHARE 🤝"
+	vlt  = "VAULT 🗄"
+	fls  = "FILES 📁"
 	back = "BACK ⬅️"
 )
 
+const (
+	personalVault = "PERSONAL"
+	newTeam       = "NEW TEAM"
+	teamMembers   = "MEMBERS"
+	setMember     = "ADD OR CHANGE MEMBER"
+	removeMember  = "REMOVE MEMBER"
+	leaveTeam     = "LEAVE TEAM"
+)
+
+// teamRoles are the roles in the order they are offered
+var teamRoles = []server.TeamRole{
+	server.TeamRole_TEAM_ROLE_MEMBER,
+	server.TeamRole_TEAM_ROLE_READ_ONLY,
+	server.TeamRole_TEAM_ROLE_ADMIN,
+	server.TeamRole_TEAM_ROLE_OWNER,
+}
+
+const (
+	shareSecret = "SHARE A SECRET"
+	revokeShare = "REVOKE A SHARE"
+	shareLink   = "CREATE A ONE-TIME LINK"
+	shareRead   = "READ"
+	shareWrite  = "READ AND WRITE"
+)
+
+const (
+	listFiles  = "LIST FILES"
+	pushFile   = "PUSH A FILE"
+	pullFile   = "PULL A FILE"
+	deleteFile = "DELETE A FILE"
+)
+
+// linkTTLs are the lifetimes of share links in the order they are offered
+var linkTTLs = []struct {
+	name string
+	ttl  time.Duration
+}{
+	{name: "1 HOUR", ttl: time.Hour},
+	{name: "1 DAY", ttl: 24 * time.Hour},
+	{name: "7 DAYS", ttl: 7 * 24 * time.Hour},
+}
+
 var minCharacters = 8
 
 const (
-	exit = "EXIT 🚪"
-	auth = "SIGN IN 👤"
-	reg  = "SIGN UP 🆕"
+	exit   = "EXIT 🚪"
+	auth   = "SIGN IN 👤"
+	reg    = "SIGN UP 🆕"
+	redeem = "OPEN A LINK 🔗"
 )
 
 const (
 	succeedAuth           = "Authenticated"
 	chooseAction          = "Choose"
 	noSecrets             = "No secrets"
+	noShares              = "No shared secrets"
+	noFiles               = "No files"
+	recipientFieldName    = "Share with: "
+	teamFieldName         = "Team: "
+	memberFieldName       = "Member: "
+	linkFieldName         = "Link: "
+	viewsFieldName        = "Views: "
+	viewsFieldPlaceholder = "1"
+	linkPassphraseName    = "Link passphrase: "
+	linkPassphraseHint    = "empty for none"
 	keyFieldName          = "Key: "
 	keyFieldPlaceholder   = "name of your secret"
 	valueFieldName        = "Value: "
 	valueFieldPlaceholder = "your secret"
+	ttlFieldName          = "Expires in: "
+	ttlFieldPlaceholder   = "empty for never, or like 1h30m"
 	PassphraseFieldName   = "Passphrase: "
 	UserFieldName         = "Username: "
 	UserFieldPlaceholder  = "nickname"
 	NonUniqueUsername     = "Username already exists"
 	InvalidCredentials    = "Invalid username or password"
+	codeFieldName         = "Code: "
+	codeFieldPlaceholder  = "authenticator or recovery code"
+	InvalidCode           = "Invalid code"
+	OldPassphraseName     = "Old passphrase: "
+	NewPassphraseName     = "New passphrase: "
+	confirmDeleteAccount  = "Delete the account and all its secrets? This cannot be undone"
+	confirmYes            = "YES, DELETE"
+	confirmNo             = "NO"
+	pathFieldName         = "Path: "
+	pathFieldPlaceholder  = "path of the local file"
+	filesOnlyPersonal     = "Files are only kept in the personal vault"
+	recoveryCodesText     = "Recovery codes, each can be used once instead of a code. Keep them safe, they are shown only once:"
 )
 
 // Start starts the CLI
@@ -85,6 +164,13 @@ func (c *CLI) operate(ctx context.Context) error {
 		get,
 		set,
 		del,
+		tfa,
+		use,
+		shr,
+		vlt,
+		fls,
+		pwd,
+		drop,
 		exit})
 
 	for {
@@ -105,7 +191,7 @@ func (c *CLI) operate(ctx context.Context) error {
 		case exit:
 			return nil
 		case get:
-			key, backToMenu, err := c.getOneFromList(ctx)
+			owner, key, backToMenu, err := c.getOneFromList(ctx, true)
 			if err != nil {
 				return fmt.Errorf("failed to get from list: %w", err)
 			}
@@ -114,7 +200,12 @@ func (c *CLI) operate(ctx context.Context) error {
 				continue
 			}
 
-			secret, err := c.logic.GetSecret(ctx, trimNewlines(key))
+			var secret string
+			if c.team != "" {
+				secret, err = c.logic.GetTeamSecret(ctx, c.team, key)
+			} else {
+				secret, err = c.logic.GetSharedSecret(ctx, owner, key)
+			}
 			if err != nil {
 				fmt.Printf("Failed to get: %v\n", err)
 				continue
@@ -135,13 +226,35 @@ func (c *CLI) operate(ctx context.Context) error {
 				return fmt.Errorf("failed to read password: %w", err)
 			}
 
-			if err = c.logic.SetSecret(ctx, trimNewlines(key), trimNewlines(value)); err != nil {
+			ttlInput := textinput.New(ttlFieldName)
+			ttlInput.Placeholder = ttlFieldPlaceholder
+			ttlInput.Validate = func(s string) error {
+				if s == "" {
+					return nil
+				}
+				_, err := time.ParseDuration(s)
+				return err
+			}
+			rawTTL, err := ttlInput.RunPrompt()
+			if err != nil {
+				return fmt.Errorf("failed to read expiry: %w", err)
+			}
+
+			switch ttl, _ := time.ParseDuration(trimNewlines(rawTTL)); {
+			case ttl != 0:
+				err = c.logic.SetExpiringSecret(ctx, c.team, trimNewlines(key), trimNewlines(value), ttl)
+			case c.team != "":
+				err = c.logic.SetTeamSecret(ctx, c.team, trimNewlines(key), trimNewlines(value))
+			default:
+				err = c.logic.SetSecret(ctx, trimNewlines(key), trimNewlines(value))
+			}
+			if err != nil {
 				log.Println(err)
 			} else {
 				log.Print("OK\n")
 			}
 		case del:
-			key, backToMenu, err := c.getOneFromList(ctx)
+			_, key, backToMenu, err := c.getOneFromList(ctx, false)
 			if err != nil {
 				return fmt.Errorf("failed to get from list: %w", err)
 			}
@@ -150,24 +263,89 @@ func (c *CLI) operate(ctx context.Context) error {
 				continue
 			}
 
-			if err = c.logic.DeleteSecret(ctx, trimNewlines(key)); err != nil {
+			if c.team != "" {
+				err = c.logic.DeleteTeamSecret(ctx, c.team, key)
+			} else {
+				err = c.logic.DeleteSecret(ctx, key)
+			}
+			if err != nil {
 				log.Println(err)
 			} else {
 				log.Printf("Deleted: %s", key)
 			}
+		case tfa:
+			if err = c.enrollTOTP(ctx); err != nil {
+				return fmt.Errorf("failed to enroll: %w", err)
+			}
+		case pwd:
+			if err = c.changePassword(ctx); err != nil {
+				return fmt.Errorf("failed to change password: %w", err)
+			}
+		case use:
+			usage, err := c.logic.GetUsage(ctx)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Printf("Secrets: %s\nFiles: %d\nBytes: %s\n",
+				formatUsage(usage.Secrets, usage.MaxSecrets), usage.Files, formatUsage(usage.Bytes, usage.MaxBytes))
+		case shr:
+			if err = c.share(ctx); err != nil {
+				return fmt.Errorf("failed to share: %w", err)
+			}
+		case vlt:
+			if err = c.switchVault(ctx); err != nil {
+				return fmt.Errorf("failed to switch vault: %w", err)
+			}
+		case fls:
+			if err = c.files(ctx); err != nil {
+				return fmt.Errorf("failed to handle files: %w", err)
+			}
+		case drop:
+			deleted, err := c.deleteAccount(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to delete account: %w", err)
+			}
+			if deleted {
+				return ErrExit
+			}
 		}
 	}
 }
 
-func (c *CLI) getOneFromList(ctx context.Context) (string, bool, error) {
-	keys, err := c.logic.GetAllNames(ctx)
+// getOneFromList lets the user choose one of the secrets of the vault in
+// use and, if shared is set and it is the personal one, one of the secrets
+// shared with them. The owner is empty for own secrets.
+func (c *CLI) getOneFromList(ctx context.Context, shared bool) (owner, key string, backToMenu bool, err error) {
+	secrets, err := c.logic.ListTeamSecrets(ctx, c.team)
 	if err != nil {
-		return "", false, err
+		return "", "", false, err
 	}
 
-	var names = make([]string, 0, len(keys)+1)
+	var names = make([]string, 0, len(secrets)+1)
 	names = append(names, back)
-	names = append(names, keys...)
+	keyByName := make(map[string]string, len(secrets))
+	for _, s := range secrets {
+		name := s.GetName()
+		if s.GetExpiresAt() != nil {
+			name = fmt.Sprintf("%s (%s)", name, formatExpiry(s))
+		}
+		keyByName[name] = s.GetName()
+		names = append(names, name)
+	}
+
+	sharedByName := make(map[string]*server.SharedSecret)
+	if shared && c.team == "" {
+		secrets, err := c.logic.ListSharedWithMe(ctx)
+		if err != nil {
+			return "", "", false, err
+		}
+		for _, s := range secrets {
+			name := fmt.Sprintf("shared: %s/%s (%s)", s.GetOwner(), s.GetKey(), formatShareMode(s.GetMode()))
+			sharedByName[name] = s
+			names = append(names, name)
+		}
+	}
 
 	msg := chooseAction
 	if len(names) == 1 {
@@ -179,34 +357,37 @@ func (c *CLI) getOneFromList(ctx context.Context) (string, bool, error) {
 
 	choice, err := getAllInput.RunPrompt()
 	if err != nil {
-		return "", false, fmt.Errorf("failed to run prompt: %w", err)
+		return "", "", false, fmt.Errorf("failed to run prompt: %w", err)
 	}
 
 	choice = trimNewlines(choice)
 
 	if choice == back {
-		return "", true, nil
+		return "", "", true, nil
+	}
+	if s, ok := sharedByName[choice]; ok {
+		return s.GetOwner(), s.GetKey(), false, nil
 	}
-	return choice, false, nil
+	return "", keyByName[choice], false, nil
+}
+
+func formatExpiry(s *server.SecretInfo) string {
+	left := time.Until(s.GetExpiresAt().AsTime()).Round(time.Minute)
+	if s.GetExpiringSoon() {
+		return fmt.Sprintf("expires in %s ⚠️", left)
+	}
+	return fmt.Sprintf("expires in %s", left)
 }
 
 func (c *CLI) authenticate(ctx context.Context) (context.Context, error) {
 	authInput := selection.New(chooseAction, []string{
 		auth,
 		reg,
+		redeem,
 		exit})
-	authInput.PageSize = 3
+	authInput.PageSize = 4
 
-	passInput := textinput.New(PassphraseFieldName)
-	passInput.Placeholder = fmt.Sprintf("more than %d characters", minCharacters)
-	passInput.Validate = func(s string) error {
-		if len(s) < minCharacters {
-			return fmt.Errorf("at least %d more characters", minCharacters-len(s))
-		}
-
-		return nil
-	}
-	passInput.Hidden = true
+	passInput := newPassphraseInput(PassphraseFieldName)
 
 	usernameInput := textinput.New(UserFieldName)
 	usernameInput.Placeholder = UserFieldPlaceholder
@@ -235,11 +416,27 @@ func (c *CLI) authenticate(ctx context.Context) (context.Context, error) {
 			password = trimNewlines(password)
 
 			ctx, err = c.logic.Auth(ctx, username, password)
+			if errors.Is(err, usecase.ErrCodeRequired) {
+				code, promptErr := newCodeInput().RunPrompt()
+				if promptErr != nil {
+					return ctx, fmt.Errorf("failed to read code: %w", promptErr)
+				}
+
+				ctx, err = c.logic.AuthWithCode(ctx, username, password, trimNewlines(code))
+				if errors.Is(err, usecase.ErrInvalidPassword) {
+					fmt.Println(InvalidCode)
+					continue
+				}
+			}
 			if err != nil {
 				if errors.Is(err, usecase.ErrInvalidPassword) {
 					fmt.Println(InvalidCredentials)
 					continue
 				}
+				if errors.Is(err, usecase.ErrTooManyAttempts) {
+					fmt.Println(err)
+					continue
+				}
 				return ctx, fmt.Errorf("failed to auth: %w", err)
 			}
 			return ctx, nil
@@ -263,11 +460,544 @@ func (c *CLI) authenticate(ctx context.Context) (context.Context, error) {
 					fmt.Println(NonUniqueUsername)
 					continue
 				}
+				if errors.Is(err, usecase.ErrInvalidArgument) {
+					fmt.Println(err)
+					continue
+				}
 				return ctx, fmt.Errorf("failed to register: %w", err)
 			}
 			return ctx, nil
+		case redeem:
+			if err = c.redeemLink(ctx); err != nil {
+				return ctx, err
+			}
 		case exit:
 			return ctx, ErrExit
 		}
 	}
 }
+
+func newPassphraseInput(name string) *textinput.TextInput {
+	passInput := textinput.New(name)
+	passInput.Placeholder = fmt.Sprintf("more than %d characters", minCharacters)
+	passInput.Validate = func(s string) error {
+		if len(s) < minCharacters {
+			return fmt.Errorf("at least %d more characters", minCharacters-len(s))
+		}
+
+		return nil
+	}
+	passInput.Hidden = true
+	return passInput
+}
+
+func newCodeInput() *textinput.TextInput {
+	codeInput := textinput.New(codeFieldName)
+	codeInput.Placeholder = codeFieldPlaceholder
+	return codeInput
+}
+
+// enrollTOTP shows a new TOTP secret and enables it once the user enters
+// a code of the authenticator
+func (c *CLI) enrollTOTP(ctx context.Context) error {
+	e, err := c.logic.EnrollTOTP(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	fmt.Printf("Secret: %s\nURL: %s\n\n%s\n", e.Secret, e.Url, recoveryCodesText)
+	for _, code := range e.RecoveryCodes {
+		fmt.Println(code)
+	}
+	fmt.Println()
+
+	for {
+		code, err := newCodeInput().RunPrompt()
+		if err != nil {
+			return fmt.Errorf("failed to read code: %w", err)
+		}
+
+		err = c.logic.ConfirmTOTP(ctx, trimNewlines(code))
+		if errors.Is(err, usecase.ErrInvalidCode) {
+			fmt.Println(InvalidCode)
+			continue
+		}
+		if err != nil {
+			fmt.Println(err)
+			return nil
+		}
+
+		fmt.Println("Two-factor authentication enabled")
+		return nil
+	}
+}
+
+// changePassword asks for the old and the new passphrase
+func (c *CLI) changePassword(ctx context.Context) error {
+	oldInput := textinput.New(OldPassphraseName)
+	oldInput.Hidden = true
+	oldPassword, err := oldInput.RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+
+	newInput := newPassphraseInput(NewPassphraseName)
+	newPassword, err := newInput.RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+
+	revoked, err := c.logic.ChangePassword(ctx, trimNewlines(oldPassword), trimNewlines(newPassword))
+	if errors.Is(err, usecase.ErrInvalidPassword) {
+		fmt.Println(InvalidCredentials)
+		return nil
+	}
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	fmt.Printf("Password changed, %d other sessions signed out\n", revoked)
+	return nil
+}
+
+// deleteAccount asks for confirmation and the passphrase and reports
+// whether the account was deleted
+func (c *CLI) deleteAccount(ctx context.Context) (bool, error) {
+	confirm, err := selection.New(confirmDeleteAccount, []string{confirmNo, confirmYes}).RunPrompt()
+	if err != nil {
+		return false, fmt.Errorf("failed to run prompt: %w", err)
+	}
+	if trimNewlines(confirm) != confirmYes {
+		return false, nil
+	}
+
+	passInput := textinput.New(PassphraseFieldName)
+	passInput.Hidden = true
+	password, err := passInput.RunPrompt()
+	if err != nil {
+		return false, fmt.Errorf("failed to read password: %w", err)
+	}
+	password = trimNewlines(password)
+
+	err = c.logic.DeleteAccount(ctx, password, "")
+	if errors.Is(err, usecase.ErrCodeRequired) {
+		code, promptErr := newCodeInput().RunPrompt()
+		if promptErr != nil {
+			return false, fmt.Errorf("failed to read code: %w", promptErr)
+		}
+		err = c.logic.DeleteAccount(ctx, password, trimNewlines(code))
+	}
+	if errors.Is(err, usecase.ErrInvalidPassword) {
+		fmt.Println(InvalidCredentials)
+		return false, nil
+	}
+	if err != nil {
+		fmt.Println(err)
+		return false, nil
+	}
+
+	fmt.Println("Account deleted")
+	return true, nil
+}
+
+// share shares one of the secrets of the user or revokes a share
+func (c *CLI) share(ctx context.Context) error {
+	choice, err := selection.New(chooseAction, []string{shareSecret, shareLink, revokeShare, back}).RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to run prompt: %w", err)
+	}
+
+	switch trimNewlines(choice) {
+	case shareSecret:
+		_, key, backToMenu, err := c.getOneFromList(ctx, false)
+		if err != nil || backToMenu {
+			return err
+		}
+
+		recipientInput := textinput.New(recipientFieldName)
+		recipientInput.Placeholder = UserFieldPlaceholder
+		recipient, err := recipientInput.RunPrompt()
+		if err != nil {
+			return fmt.Errorf("failed to read username: %w", err)
+		}
+
+		mode, err := selection.New(chooseAction, []string{shareRead, shareWrite}).RunPrompt()
+		if err != nil {
+			return fmt.Errorf("failed to run prompt: %w", err)
+		}
+
+		err = c.logic.ShareSecret(ctx, key, trimNewlines(recipient), trimNewlines(mode) == shareWrite)
+		if err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		fmt.Printf("Shared %s with %s\n", key, trimNewlines(recipient))
+	case shareLink:
+		return c.createLink(ctx)
+	case revokeShare:
+		secrets, err := c.logic.ListSharedByMe(ctx)
+		if err != nil {
+			fmt.Println(err)
+			return nil
+		}
+
+		names := []string{back}
+		byName := make(map[string]*server.SharedSecret, len(secrets))
+		for _, s := range secrets {
+			name := fmt.Sprintf("%s → %s (%s)", s.GetKey(), s.GetRecipient(), formatShareMode(s.GetMode()))
+			byName[name] = s
+			names = append(names, name)
+		}
+
+		msg := chooseAction
+		if len(secrets) == 0 {
+			msg = noShares
+		}
+
+		choice, err := selection.New(msg, names).RunPrompt()
+		if err != nil {
+			return fmt.Errorf("failed to run prompt: %w", err)
+		}
+		s, ok := byName[trimNewlines(choice)]
+		if !ok {
+			return nil
+		}
+
+		if _, err = c.logic.RevokeShare(ctx, s.GetKey(), s.GetRecipient()); err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		fmt.Printf("Revoked %s for %s\n", s.GetKey(), s.GetRecipient())
+	}
+	return nil
+}
+
+// files lists the files of the personal vault, pushes a local file to it,
+// pulls one from it or deletes one
+func (c *CLI) files(ctx context.Context) error {
+	if c.team != "" {
+		fmt.Println(filesOnlyPersonal)
+		return nil
+	}
+
+	choice, err := selection.New(chooseAction, []string{listFiles, pushFile, pullFile, deleteFile, back}).RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to run prompt: %w", err)
+	}
+	choice = trimNewlines(choice)
+	switch choice {
+	case back:
+		return nil
+	case listFiles:
+		infos, err := c.logic.ListFiles(ctx)
+		if err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		if len(infos) == 0 {
+			fmt.Println(noFiles)
+		}
+		for _, info := range infos {
+			fmt.Printf("%s: %d bytes, SHA-256 %s\n", info.GetKey(), info.GetSize(), info.GetSha256())
+		}
+		return nil
+	}
+
+	keyInput := textinput.New(keyFieldName)
+	keyInput.Placeholder = keyFieldPlaceholder
+	key, err := keyInput.RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+	key = trimNewlines(key)
+
+	if choice == deleteFile {
+		if err = c.logic.DeleteFile(ctx, key); err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		fmt.Printf("Deleted: %s\n", key)
+		return nil
+	}
+
+	pathInput := textinput.New(pathFieldName)
+	pathInput.Placeholder = pathFieldPlaceholder
+	path, err := pathInput.RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to read path: %w", err)
+	}
+	path = trimNewlines(path)
+
+	var info *server.FileInfo
+	if choice == pushFile {
+		info, err = c.logic.UploadFile(ctx, key, path)
+	} else {
+		info, err = c.logic.DownloadFile(ctx, key, path)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	fmt.Printf("%s: %d bytes, SHA-256 %s\n", info.GetKey(), info.GetSize(), info.GetSha256())
+	return nil
+}
+
+// createLink creates a one-time link to a secret of the personal vault
+func (c *CLI) createLink(ctx context.Context) error {
+	_, key, backToMenu, err := c.getOneFromList(ctx, false)
+	if err != nil || backToMenu {
+		return err
+	}
+
+	names := make([]string, len(linkTTLs))
+	for i, t := range linkTTLs {
+		names[i] = t.name
+	}
+	choice, err := selection.New(chooseAction, names).RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to run prompt: %w", err)
+	}
+	var ttl time.Duration
+	for _, t := range linkTTLs {
+		if t.name == trimNewlines(choice) {
+			ttl = t.ttl
+		}
+	}
+
+	viewsInput := textinput.New(viewsFieldName)
+	viewsInput.Placeholder = viewsFieldPlaceholder
+	viewsInput.Validate = func(s string) error {
+		if s == "" {
+			return nil
+		}
+		_, err := strconv.Atoi(s)
+		return err
+	}
+	views, err := viewsInput.RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to read views: %w", err)
+	}
+	maxViews, _ := strconv.Atoi(trimNewlines(views))
+
+	passphrase, err := newLinkPassphraseInput().RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to read passphrase: %w", err)
+	}
+
+	link, err := c.logic.CreateShareLink(ctx, key, ttl, maxViews, trimNewlines(passphrase))
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	fmt.Printf("Link to %s, valid for %d view(s) until %s:\n%s\n",
+		key, link.GetMaxViews(), link.GetExpiresAt().AsTime().Local().Format(time.RFC1123), link.GetToken())
+	return nil
+}
+
+// redeemLink shows the secret behind a one-time link, asking for the
+// passphrase if the link has one
+func (c *CLI) redeemLink(ctx context.Context) error {
+	token, err := textinput.New(linkFieldName).RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to read link: %w", err)
+	}
+	token = trimNewlines(token)
+
+	r, err := c.logic.RedeemShareLink(ctx, token, "")
+	if errors.Is(err, usecase.ErrInvalidPassphrase) {
+		passphrase, promptErr := newLinkPassphraseInput().RunPrompt()
+		if promptErr != nil {
+			return fmt.Errorf("failed to read passphrase: %w", promptErr)
+		}
+		r, err = c.logic.RedeemShareLink(ctx, token, trimNewlines(passphrase))
+	}
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	fmt.Printf("%s/%s: %s\n", r.GetOwner(), r.GetKey(), r.GetValue())
+	if r.GetViewsLeft() == 0 {
+		fmt.Println("The link is now destroyed")
+	} else {
+		fmt.Printf("The link can be opened %d more time(s)\n", r.GetViewsLeft())
+	}
+	return nil
+}
+
+func newLinkPassphraseInput() *textinput.TextInput {
+	input := textinput.New(linkPassphraseName)
+	input.Placeholder = linkPassphraseHint
+	input.Validate = nil
+	input.Hidden = true
+	return input
+}
+
+// switchVault switches between the personal vault and the team vaults and
+// manages the teams
+func (c *CLI) switchVault(ctx context.Context) error {
+	teams, err := c.logic.ListTeams(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	names := []string{back, personalVault}
+	byName := make(map[string]string, len(teams))
+	for _, t := range teams {
+		name := fmt.Sprintf("team: %s (%s)", t.GetTeam(), formatTeamRole(t.GetRole()))
+		byName[name] = t.GetTeam()
+		names = append(names, name)
+	}
+	names = append(names, newTeam)
+	if c.team != "" {
+		names = append(names, teamMembers, leaveTeam)
+	}
+
+	choice, err := selection.New(chooseAction, names).RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to run prompt: %w", err)
+	}
+
+	switch choice = trimNewlines(choice); choice {
+	case back:
+	case personalVault:
+		c.team = ""
+		fmt.Println("Vault: personal")
+	case newTeam:
+		teamInput := textinput.New(teamFieldName)
+		team, err := teamInput.RunPrompt()
+		if err != nil {
+			return fmt.Errorf("failed to read team: %w", err)
+		}
+		team = trimNewlines(team)
+
+		if err = c.logic.CreateTeam(ctx, team); err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		c.team = team
+		fmt.Printf("Vault: %s\n", team)
+	case teamMembers:
+		return c.manageMembers(ctx)
+	case leaveTeam:
+		if err = c.logic.RemoveTeamMember(ctx, c.team, ""); err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		fmt.Printf("Left %s\nVault: personal\n", c.team)
+		c.team = ""
+	default:
+		c.team = byName[choice]
+		fmt.Printf("Vault: %s\n", c.team)
+	}
+	return nil
+}
+
+// manageMembers lists the members of the team in use and adds, changes or
+// removes one
+func (c *CLI) manageMembers(ctx context.Context) error {
+	members, err := c.logic.GetTeamMembers(ctx, c.team)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	for _, m := range members {
+		fmt.Printf("%s (%s)\n", m.GetUsername(), formatTeamRole(m.GetRole()))
+	}
+
+	choice, err := selection.New(chooseAction, []string{setMember, removeMember, back}).RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to run prompt: %w", err)
+	}
+
+	switch trimNewlines(choice) {
+	case setMember:
+		memberInput := textinput.New(memberFieldName)
+		memberInput.Placeholder = UserFieldPlaceholder
+		member, err := memberInput.RunPrompt()
+		if err != nil {
+			return fmt.Errorf("failed to read username: %w", err)
+		}
+
+		roles := make([]string, 0, len(teamRoles))
+		for _, r := range teamRoles {
+			roles = append(roles, formatTeamRole(r))
+		}
+		role, err := selection.New(chooseAction, roles).RunPrompt()
+		if err != nil {
+			return fmt.Errorf("failed to run prompt: %w", err)
+		}
+
+		var teamRole server.TeamRole
+		for _, r := range teamRoles {
+			if formatTeamRole(r) == trimNewlines(role) {
+				teamRole = r
+			}
+		}
+
+		if err = c.logic.SetTeamMember(ctx, c.team, trimNewlines(member), teamRole); err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		fmt.Println("OK")
+	case removeMember:
+		return c.removeMember(ctx, members)
+	}
+	return nil
+}
+
+// removeMember lets the user choose one of members and removes them from
+// the team in use
+func (c *CLI) removeMember(ctx context.Context, members []*server.TeamMember) error {
+	names := []string{back}
+	for _, m := range members {
+		names = append(names, m.GetUsername())
+	}
+
+	choice, err := selection.New(chooseAction, names).RunPrompt()
+	if err != nil {
+		return fmt.Errorf("failed to run prompt: %w", err)
+	}
+	member := trimNewlines(choice)
+	if member == back {
+		return nil
+	}
+
+	if err = c.logic.RemoveTeamMember(ctx, c.team, member); err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	fmt.Printf("Removed %s\n", member)
+	return nil
+}
+
+func formatTeamRole(role server.TeamRole) string {
+	switch role {
+	case server.TeamRole_TEAM_ROLE_OWNER:
+		return "owner"
+	case server.TeamRole_TEAM_ROLE_ADMIN:
+		return "admin"
+	case server.TeamRole_TEAM_ROLE_READ_ONLY:
+		return "read-only"
+	default:
+		return "member"
+	}
+}
+
+func formatShareMode(mode server.ShareMode) string {
+	if mode == server.ShareMode_SHARE_MODE_READ_WRITE {
+		return "read-write"
+	}
+	return "read"
+}
+
+// formatUsage formats used out of limit, a zero limit means no limit
+func formatUsage(used, limit int64) string {
+	if limit == 0 {
+		return fmt.Sprintf("%d", used)
+	}
+	return fmt.Sprintf("%d of %d", used, limit)
+}
@@ -2,15 +2,25 @@ package usecase
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
 	"secret-keeper/pkg/api/server"
+	"sort"
+	"strings"
+	"time"
 )
 
 // ErrUnavailable when service is unavailable
@@ -19,6 +29,38 @@ var ErrUnavailable = errors.New("service unavailable")
 // ErrInvalidPassword when password is invalid
 var ErrInvalidPassword = errors.New("Wrong password or username!")
 
+// ErrTooManyAttempts when the login is locked out after failed attempts
+var ErrTooManyAttempts = errors.New("too many failed attempts")
+
+// ErrCodeRequired when the user has two-factor authentication enabled
+var ErrCodeRequired = errors.New("two-factor code required")
+
+// ErrInvalidCode when a two-factor code is invalid
+var ErrInvalidCode = errors.New("invalid two-factor code")
+
+// ErrInvalidArgument when the server rejects a field of the request
+var ErrInvalidArgument = errors.New("invalid input")
+
+// ErrQuotaExceeded when the user reached a storage limit
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ErrPermissionDenied when the role of the user does not allow an action
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrInvalidPassphrase when a share link needs another passphrase
+var ErrInvalidPassphrase = errors.New("invalid passphrase")
+
+// ErrWatchCompacted when a watch cannot resume because the changes after
+// its last revision are no longer kept, the secrets have to be read again
+var ErrWatchCompacted = errors.New("watch history compacted")
+
+// ErrBatchAborted when an item of a transactional batch was not set because
+// another item failed
+var ErrBatchAborted = errors.New("batch aborted")
+
+// ErrChecksumMismatch when the content of a file does not match its SHA-256
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
 // ErrUsernameExists when username exists
 var ErrUsernameExists = errors.New("username exists")
 
@@ -44,7 +86,22 @@ func New(addr string, header *metadata.MD) (*UseCase, error) {
 
 // GetSecret gets secret by key
 func (uc *UseCase) GetSecret(ctx context.Context, key string) (string, error) {
-	r, err := uc.cl.Get(ctx, &server.GetRequest{Key: key}, grpc.Header(uc.header))
+	return uc.GetSharedSecret(ctx, "", key)
+}
+
+// GetSharedSecret gets secret by key of owner shared with the user, an
+// empty owner gets own secret
+func (uc *UseCase) GetSharedSecret(ctx context.Context, owner, key string) (string, error) {
+	return uc.get(ctx, &server.GetRequest{Key: key, Owner: owner})
+}
+
+// GetTeamSecret gets secret by key from the vault of team
+func (uc *UseCase) GetTeamSecret(ctx context.Context, team, key string) (string, error) {
+	return uc.get(ctx, &server.GetRequest{Key: key, Team: team})
+}
+
+func (uc *UseCase) get(ctx context.Context, req *server.GetRequest) (string, error) {
+	r, err := uc.cl.Get(ctx, req, grpc.Header(uc.header))
 	if err != nil {
 		st, ok := status.FromError(err)
 		if !ok {
@@ -56,6 +113,9 @@ func (uc *UseCase) GetSecret(ctx context.Context, key string) (string, error) {
 		if st.Code() == codes.NotFound {
 			return "", ErrSecretNotFound
 		}
+		if st.Code() == codes.PermissionDenied {
+			return "", ErrPermissionDenied
+		}
 		return "", err
 	}
 	return r.Value, nil
@@ -63,7 +123,22 @@ func (uc *UseCase) GetSecret(ctx context.Context, key string) (string, error) {
 
 // SetSecret sets secret by key
 func (uc *UseCase) SetSecret(ctx context.Context, key, value string) error {
-	_, err := uc.cl.Set(ctx, &server.SetRequest{Key: key, Value: value}, grpc.Header(uc.header))
+	return uc.set(ctx, &server.SetRequest{Key: key, Value: value})
+}
+
+// SetTeamSecret sets secret by key in the vault of team
+func (uc *UseCase) SetTeamSecret(ctx context.Context, team, key, value string) error {
+	return uc.set(ctx, &server.SetRequest{Key: key, Value: value, Team: team})
+}
+
+// SetExpiringSecret sets secret by key in the vault of team, an empty team
+// sets own secret, so that it expires after ttl
+func (uc *UseCase) SetExpiringSecret(ctx context.Context, team, key, value string, ttl time.Duration) error {
+	return uc.set(ctx, &server.SetRequest{Key: key, Value: value, Team: team, Ttl: durationpb.New(ttl)})
+}
+
+func (uc *UseCase) set(ctx context.Context, req *server.SetRequest) error {
+	_, err := uc.cl.Set(ctx, req, grpc.Header(uc.header))
 	if err != nil {
 		st, ok := status.FromError(err)
 		if !ok {
@@ -74,14 +149,296 @@ func (uc *UseCase) SetSecret(ctx context.Context, key, value string) error {
 			return fmt.Errorf("failed to set: %w", ErrUnavailable)
 		}
 
+		if st.Code() == codes.InvalidArgument {
+			return invalidArgument(st)
+		}
+
+		if st.Code() == codes.ResourceExhausted {
+			return fmt.Errorf("%w: %s", ErrQuotaExceeded, st.Message())
+		}
+
+		if st.Code() == codes.PermissionDenied {
+			return ErrPermissionDenied
+		}
+
 		return fmt.Errorf("failed to set: %w", err)
 	}
 	return nil
 }
 
+// BatchResult is the result of a secret of a batch
+type BatchResult struct {
+	Key string
+	// Value is only set by BatchGetSecrets
+	Value string
+	Err   error
+}
+
+// BatchGetSecrets gets up to 100 secrets in one call. Every key has its own
+// result, an error is only returned when the batch failed as a whole.
+func (uc *UseCase) BatchGetSecrets(ctx context.Context, keys []string) ([]BatchResult, error) {
+	r, err := uc.cl.BatchGet(ctx, &server.BatchGetRequest{Keys: keys}, grpc.Header(uc.header))
+	if err != nil {
+		return nil, batchError("failed to get", err)
+	}
+	return batchResults(r.GetResults()), nil
+}
+
+// BatchSetSecrets sets up to 100 secrets in one call, sorted by key. If
+// transactional is set either every secret is set or none. Every secret has
+// its own result, an error is only returned when the batch failed as a
+// whole.
+func (uc *UseCase) BatchSetSecrets(ctx context.Context, secrets map[string]string, transactional bool) ([]BatchResult, error) {
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	req := &server.BatchSetRequest{Transactional: transactional}
+	for _, key := range keys {
+		req.Items = append(req.Items, &server.BatchSetItem{Key: key, Value: secrets[key]})
+	}
+
+	r, err := uc.cl.BatchSet(ctx, req, grpc.Header(uc.header))
+	if err != nil {
+		return nil, batchError("failed to set", err)
+	}
+	return batchResults(r.GetResults()), nil
+}
+
+// BatchDeleteSecrets deletes up to 100 secrets in one call. Every key has
+// its own result, an error is only returned when the batch failed as a
+// whole.
+func (uc *UseCase) BatchDeleteSecrets(ctx context.Context, keys []string) ([]BatchResult, error) {
+	r, err := uc.cl.BatchDelete(ctx, &server.BatchDeleteRequest{Keys: keys}, grpc.Header(uc.header))
+	if err != nil {
+		return nil, batchError("failed to delete", err)
+	}
+	return batchResults(r.GetResults()), nil
+}
+
+// batchError maps the status of a batch that failed as a whole
+func batchError(msg string, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+	switch st.Code() {
+	case codes.Unavailable:
+		return fmt.Errorf("%s: %w", msg, ErrUnavailable)
+	case codes.InvalidArgument:
+		return invalidArgument(st)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+func batchResults(list []*server.BatchResult) []BatchResult {
+	results := make([]BatchResult, 0, len(list))
+	for _, r := range list {
+		results = append(results, BatchResult{
+			Key:   r.GetKey(),
+			Value: r.GetValue(),
+			Err:   batchItemError(codes.Code(r.GetCode()), r.GetMessage()),
+		})
+	}
+	return results
+}
+
+// batchItemError maps the status of a secret of a batch to the errors of
+// the single secret calls
+func batchItemError(code codes.Code, msg string) error {
+	switch code {
+	case codes.OK:
+		return nil
+	case codes.NotFound:
+		return ErrSecretNotFound
+	case codes.PermissionDenied:
+		return ErrPermissionDenied
+	case codes.Aborted:
+		return ErrBatchAborted
+	case codes.Unavailable:
+		return ErrUnavailable
+	case codes.InvalidArgument:
+		return fmt.Errorf("%w: %s", ErrInvalidArgument, msg)
+	case codes.ResourceExhausted:
+		return fmt.Errorf("%w: %s", ErrQuotaExceeded, msg)
+	}
+	return errors.New(msg)
+}
+
+// uploadChunkSize is the size of the chunks a file is uploaded in
+const uploadChunkSize = 256 << 10
+
+// UploadFile uploads the file at path as key, replacing the file stored
+// under key once it is uploaded as a whole
+func (uc *UseCase) UploadFile(ctx context.Context, key, path string) (*server.FileInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// the server checks the content against the hash of the file as it is
+	// before the upload
+	hash := sha256.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	stream, err := uc.cl.Upload(ctx, grpc.Header(uc.header))
+	if err != nil {
+		return nil, fileError("failed to upload", err)
+	}
+	header := &server.UploadHeader{Key: key, Sha256: hex.EncodeToString(hash.Sum(nil))}
+	if err = stream.Send(&server.UploadRequest{Data: &server.UploadRequest_Header{Header: header}}); err != nil {
+		return nil, uploadError(stream, err)
+	}
+
+	buf := make([]byte, uploadChunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&server.UploadRequest{Data: &server.UploadRequest_Chunk{Chunk: buf[:n]}}); err != nil {
+				return nil, uploadError(stream, err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, fileError("failed to upload", err)
+	}
+	return r.GetFile(), nil
+}
+
+// uploadError returns the status of an upload the server failed, since
+// Send only reports io.EOF then
+func uploadError(stream server.SecretKeeper_UploadClient, err error) error {
+	if errors.Is(err, io.EOF) {
+		_, err = stream.CloseAndRecv()
+	}
+	return fileError("failed to upload", err)
+}
+
+// DownloadFile downloads the file key to path. The content is written to a
+// temporary file next to path that replaces it once the content matches
+// its SHA-256.
+func (uc *UseCase) DownloadFile(ctx context.Context, key, path string) (_ *server.FileInfo, err error) {
+	stream, err := uc.cl.Download(ctx, &server.DownloadRequest{Key: key}, grpc.Header(uc.header))
+	if err != nil {
+		return nil, fileError("failed to download", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.part")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		tmp.Close()
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	var info *server.FileInfo
+	hash := sha256.New()
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fileError("failed to download", err)
+		}
+
+		switch data := r.GetData().(type) {
+		case *server.DownloadResponse_File:
+			info = data.File
+		case *server.DownloadResponse_Chunk:
+			hash.Write(data.Chunk)
+			if _, err = tmp.Write(data.Chunk); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if info == nil || hex.EncodeToString(hash.Sum(nil)) != info.GetSha256() {
+		return nil, ErrChecksumMismatch
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ListFiles returns the files of the user sorted by key
+func (uc *UseCase) ListFiles(ctx context.Context) ([]*server.FileInfo, error) {
+	resp, err := uc.cl.ListFiles(ctx, &server.ListFilesRequest{}, grpc.Header(uc.header))
+	if err != nil {
+		return nil, fileError("failed to list files", err)
+	}
+	return resp.GetFiles(), nil
+}
+
+// DeleteFile deletes the file key
+func (uc *UseCase) DeleteFile(ctx context.Context, key string) error {
+	_, err := uc.cl.DeleteFile(ctx, &server.DeleteFileRequest{Key: key}, grpc.Header(uc.header))
+	return fileError("failed to delete", err)
+}
+
+// fileError maps the status codes of files to errors
+func fileError(msg string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+	switch st.Code() {
+	case codes.Unavailable:
+		return fmt.Errorf("%s: %w", msg, ErrUnavailable)
+	case codes.InvalidArgument:
+		return invalidArgument(st)
+	case codes.NotFound:
+		return ErrSecretNotFound
+	case codes.PermissionDenied:
+		return ErrPermissionDenied
+	case codes.DataLoss:
+		return ErrChecksumMismatch
+	case codes.ResourceExhausted:
+		return fmt.Errorf("%w: %s", ErrQuotaExceeded, st.Message())
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
 // DeleteSecret deletes secret by key
 func (uc *UseCase) DeleteSecret(ctx context.Context, key string) error {
-	_, err := uc.cl.Delete(ctx, &server.DeleteRequest{Key: key}, grpc.Header(uc.header))
+	return uc.delete(ctx, &server.DeleteRequest{Key: key})
+}
+
+// DeleteTeamSecret deletes secret by key from the vault of team
+func (uc *UseCase) DeleteTeamSecret(ctx context.Context, team, key string) error {
+	return uc.delete(ctx, &server.DeleteRequest{Key: key, Team: team})
+}
+
+func (uc *UseCase) delete(ctx context.Context, req *server.DeleteRequest) error {
+	key := req.GetKey()
+	_, err := uc.cl.Delete(ctx, req, grpc.Header(uc.header))
 	if err != nil {
 		st, ok := status.FromError(err)
 		if !ok {
@@ -91,6 +448,8 @@ func (uc *UseCase) DeleteSecret(ctx context.Context, key string) error {
 			return fmt.Errorf("failed to delete: %w", ErrUnavailable)
 		} else if st.Code() == codes.NotFound {
 			return fmt.Errorf("key not found: %v", key)
+		} else if st.Code() == codes.PermissionDenied {
+			return ErrPermissionDenied
 		}
 		return fmt.Errorf("failed to delete: %w", err)
 	}
@@ -99,7 +458,13 @@ func (uc *UseCase) DeleteSecret(ctx context.Context, key string) error {
 
 // GetAllNames gets all names of secrets
 func (uc *UseCase) GetAllNames(ctx context.Context) ([]string, error) {
-	getAllNames, err := uc.cl.GetAllNames(ctx, &server.GetAllNamesRequest{}, grpc.Header(uc.header))
+	return uc.GetTeamNames(ctx, "")
+}
+
+// GetTeamNames gets all names of secrets in the vault of team, an empty
+// team gets own secrets
+func (uc *UseCase) GetTeamNames(ctx context.Context, team string) ([]string, error) {
+	getAllNames, err := uc.cl.GetAllNames(ctx, &server.GetAllNamesRequest{Team: team}, grpc.Header(uc.header))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all: %w", err)
 	}
@@ -107,9 +472,68 @@ func (uc *UseCase) GetAllNames(ctx context.Context) ([]string, error) {
 	return getAllNames.Vars, nil
 }
 
+// ListTeamSecrets lists the secrets in the vault of team sorted by name
+// with their expiry, an empty team lists own secrets
+func (uc *UseCase) ListTeamSecrets(ctx context.Context, team string) ([]*server.SecretInfo, error) {
+	r, err := uc.cl.GetAllNames(ctx, &server.GetAllNamesRequest{Team: team}, grpc.Header(uc.header))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all: %w", err)
+	}
+
+	return r.GetSecrets(), nil
+}
+
+// ListSecrets gets a page of own secrets sorted by name with a prefix and
+// every one of tags. An empty pageToken gets the first page, the returned
+// token is empty on the last one.
+func (uc *UseCase) ListSecrets(ctx context.Context, prefix string, tags []string, pageSize int, pageToken string) ([]*server.SecretInfo, string, error) {
+	r, err := uc.cl.ListSecrets(ctx, &server.ListSecretsRequest{
+		Prefix:    prefix,
+		Tags:      tags,
+		PageSize:  int32(pageSize),
+		PageToken: pageToken,
+	}, grpc.Header(uc.header))
+	if err != nil {
+		st, ok := status.FromError(err)
+		if ok && st.Code() == codes.InvalidArgument {
+			return nil, "", invalidArgument(st)
+		}
+		return nil, "", fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	return r.GetSecrets(), r.GetNextPageToken(), nil
+}
+
+// SetTags replaces the tags of an own secret
+func (uc *UseCase) SetTags(ctx context.Context, key string, tags []string) error {
+	_, err := uc.cl.SetTags(ctx, &server.SetTagsRequest{Key: key, Tags: tags}, grpc.Header(uc.header))
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok {
+			return fmt.Errorf("failed to set tags: %w", err)
+		}
+		switch st.Code() {
+		case codes.InvalidArgument:
+			return invalidArgument(st)
+		case codes.NotFound:
+			return ErrSecretNotFound
+		case codes.PermissionDenied:
+			return ErrPermissionDenied
+		}
+		return fmt.Errorf("failed to set tags: %w", err)
+	}
+	return nil
+}
+
 // Auth authenticates user
 func (uc *UseCase) Auth(ctx context.Context, username, password string) (context.Context, error) {
-	_, err := uc.cl.Auth(ctx, &server.AuthRequest{Username: username, Password: password}, grpc.Header(uc.header))
+	return uc.AuthWithCode(ctx, username, password, "")
+}
+
+// AuthWithCode authenticates user with a TOTP or recovery code
+func (uc *UseCase) AuthWithCode(ctx context.Context, username, password, code string) (context.Context, error) {
+	req := &server.AuthRequest{Username: username, Password: password, Code: code}
+	_, err := uc.cl.Auth(ctx, req, grpc.Header(uc.header))
 	if err != nil {
 		st, ok := status.FromError(err)
 		if !ok {
@@ -124,12 +548,419 @@ func (uc *UseCase) Auth(ctx context.Context, username, password string) (context
 			return ctx, ErrInvalidPassword
 		}
 
+		if st.Code() == codes.Unauthenticated {
+			return ctx, ErrCodeRequired
+		}
+
+		if st.Code() == codes.ResourceExhausted {
+			if retryAfter := uc.header.Get("retry-after"); len(retryAfter) != 0 {
+				return ctx, fmt.Errorf("%w, try again in %s seconds", ErrTooManyAttempts, retryAfter[0])
+			}
+			return ctx, ErrTooManyAttempts
+		}
+
 		return ctx, fmt.Errorf("failed to auth: %w", err)
 	}
 
 	return uc.addTokenToContext(ctx)
 }
 
+// EnrollTOTP starts two-factor authentication enrollment
+func (uc *UseCase) EnrollTOTP(ctx context.Context) (*server.EnrollTOTPResponse, error) {
+	resp, err := uc.cl.EnrollTOTP(ctx, &server.EnrollTOTPRequest{})
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok {
+			return nil, fmt.Errorf("failed to enroll: %w", err)
+		}
+		if st.Code() == codes.Unavailable {
+			return nil, ErrUnavailable
+		}
+		if st.Code() == codes.FailedPrecondition {
+			return nil, errors.New(st.Message())
+		}
+		return nil, fmt.Errorf("failed to enroll: %w", err)
+	}
+	return resp, nil
+}
+
+// ConfirmTOTP enables two-factor authentication
+func (uc *UseCase) ConfirmTOTP(ctx context.Context, code string) error {
+	_, err := uc.cl.ConfirmTOTP(ctx, &server.ConfirmTOTPRequest{Code: code})
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok {
+			return fmt.Errorf("failed to confirm: %w", err)
+		}
+		if st.Code() == codes.Unavailable {
+			return ErrUnavailable
+		}
+		if st.Code() == codes.InvalidArgument {
+			return ErrInvalidCode
+		}
+		return fmt.Errorf("failed to confirm: %w", err)
+	}
+	return nil
+}
+
+// ChangePassword changes the password and returns how many other sessions
+// were revoked
+func (uc *UseCase) ChangePassword(ctx context.Context, oldPassword, newPassword string) (int, error) {
+	req := &server.ChangePasswordRequest{OldPassword: oldPassword, NewPassword: newPassword}
+	resp, err := uc.cl.ChangePassword(ctx, req)
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok {
+			return 0, fmt.Errorf("failed to change password: %w", err)
+		}
+		if st.Code() == codes.Unavailable {
+			return 0, ErrUnavailable
+		}
+		if st.Code() == codes.PermissionDenied {
+			return 0, ErrInvalidPassword
+		}
+		if st.Code() == codes.InvalidArgument {
+			return 0, invalidArgument(st)
+		}
+		if st.Code() == codes.ResourceExhausted {
+			return 0, ErrTooManyAttempts
+		}
+		return 0, fmt.Errorf("failed to change password: %w", err)
+	}
+	return int(resp.RevokedSessions), nil
+}
+
+// DeleteAccount deletes the account with all its secrets
+func (uc *UseCase) DeleteAccount(ctx context.Context, password, code string) error {
+	_, err := uc.cl.DeleteAccount(ctx, &server.DeleteAccountRequest{Password: password, Code: code})
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok {
+			return fmt.Errorf("failed to delete account: %w", err)
+		}
+		if st.Code() == codes.Unavailable {
+			return ErrUnavailable
+		}
+		if st.Code() == codes.Unauthenticated {
+			return ErrCodeRequired
+		}
+		if st.Code() == codes.PermissionDenied {
+			return ErrInvalidPassword
+		}
+		if st.Code() == codes.ResourceExhausted {
+			return ErrTooManyAttempts
+		}
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+	return nil
+}
+
+// GetUsage returns the storage used by the user and their limits
+func (uc *UseCase) GetUsage(ctx context.Context) (*server.GetUsageResponse, error) {
+	usage, err := uc.cl.GetUsage(ctx, &server.GetUsageRequest{})
+	if err != nil {
+		if status.Code(err) == codes.Unavailable {
+			return nil, ErrUnavailable
+		}
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return usage, nil
+}
+
+// ShareSecret grants recipient access to secret by key
+func (uc *UseCase) ShareSecret(ctx context.Context, key, recipient string, readWrite bool) error {
+	mode := server.ShareMode_SHARE_MODE_READ
+	if readWrite {
+		mode = server.ShareMode_SHARE_MODE_READ_WRITE
+	}
+
+	_, err := uc.cl.ShareSecret(ctx, &server.ShareSecretRequest{Key: key, Recipient: recipient, Mode: mode})
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok {
+			return fmt.Errorf("failed to share: %w", err)
+		}
+		if st.Code() == codes.Unavailable {
+			return ErrUnavailable
+		}
+		if st.Code() == codes.InvalidArgument {
+			return invalidArgument(st)
+		}
+		if st.Code() == codes.NotFound {
+			return fmt.Errorf("%w: %s", ErrSecretNotFound, st.Message())
+		}
+		return fmt.Errorf("failed to share: %w", err)
+	}
+	return nil
+}
+
+// RevokeShare revokes the access of recipient to secret by key and reports
+// whether it was shared
+func (uc *UseCase) RevokeShare(ctx context.Context, key, recipient string) (bool, error) {
+	r, err := uc.cl.RevokeShare(ctx, &server.RevokeShareRequest{Key: key, Recipient: recipient})
+	if err != nil {
+		if status.Code(err) == codes.Unavailable {
+			return false, ErrUnavailable
+		}
+		return false, fmt.Errorf("failed to revoke: %w", err)
+	}
+	return r.GetRevoked(), nil
+}
+
+// ListSharedWithMe returns the secrets other users shared with the user
+func (uc *UseCase) ListSharedWithMe(ctx context.Context) ([]*server.SharedSecret, error) {
+	r, err := uc.cl.ListSharedWithMe(ctx, &server.ListSharedWithMeRequest{})
+	if err != nil {
+		if status.Code(err) == codes.Unavailable {
+			return nil, ErrUnavailable
+		}
+		return nil, fmt.Errorf("failed to list shared: %w", err)
+	}
+	return r.GetSecrets(), nil
+}
+
+// ListSharedByMe returns the secrets the user shared with others
+func (uc *UseCase) ListSharedByMe(ctx context.Context) ([]*server.SharedSecret, error) {
+	r, err := uc.cl.ListSharedByMe(ctx, &server.ListSharedByMeRequest{})
+	if err != nil {
+		if status.Code(err) == codes.Unavailable {
+			return nil, ErrUnavailable
+		}
+		return nil, fmt.Errorf("failed to list shared: %w", err)
+	}
+	return r.GetSecrets(), nil
+}
+
+// CreateShareLink creates a one-time link to secret by key. Zero ttl and
+// maxViews leave the defaults of the server.
+func (uc *UseCase) CreateShareLink(ctx context.Context, key string, ttl time.Duration, maxViews int, passphrase string) (*server.CreateShareLinkResponse, error) {
+	req := &server.CreateShareLinkRequest{Key: key, MaxViews: int32(maxViews), Passphrase: passphrase}
+	if ttl != 0 {
+		req.Ttl = durationpb.New(ttl)
+	}
+
+	r, err := uc.cl.CreateShareLink(ctx, req)
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok {
+			return nil, fmt.Errorf("failed to create link: %w", err)
+		}
+		if st.Code() == codes.Unavailable {
+			return nil, ErrUnavailable
+		}
+		if st.Code() == codes.InvalidArgument {
+			return nil, invalidArgument(st)
+		}
+		if st.Code() == codes.NotFound {
+			return nil, ErrSecretNotFound
+		}
+		if st.Code() == codes.PermissionDenied {
+			return nil, ErrPermissionDenied
+		}
+		return nil, fmt.Errorf("failed to create link: %w", err)
+	}
+	return r, nil
+}
+
+// RedeemShareLink returns the secret behind a share link. It works without
+// being authenticated.
+func (uc *UseCase) RedeemShareLink(ctx context.Context, token, passphrase string) (*server.RedeemShareLinkResponse, error) {
+	var header metadata.MD
+	r, err := uc.cl.RedeemShareLink(ctx, &server.RedeemShareLinkRequest{Token: token, Passphrase: passphrase}, grpc.Header(&header))
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok {
+			return nil, fmt.Errorf("failed to redeem link: %w", err)
+		}
+		if st.Code() == codes.Unavailable {
+			return nil, ErrUnavailable
+		}
+		if st.Code() == codes.NotFound {
+			return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, st.Message())
+		}
+		if st.Code() == codes.PermissionDenied {
+			return nil, ErrInvalidPassphrase
+		}
+		if st.Code() == codes.ResourceExhausted {
+			if retryAfter := header.Get("retry-after"); len(retryAfter) != 0 {
+				return nil, fmt.Errorf("%w, try again in %s seconds", ErrTooManyAttempts, retryAfter[0])
+			}
+			return nil, ErrTooManyAttempts
+		}
+		return nil, fmt.Errorf("failed to redeem link: %w", err)
+	}
+	return r, nil
+}
+
+// CreateTeam creates a team owned by the user
+func (uc *UseCase) CreateTeam(ctx context.Context, team string) error {
+	_, err := uc.cl.CreateTeam(ctx, &server.CreateTeamRequest{Team: team})
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok {
+			return fmt.Errorf("failed to create team: %w", err)
+		}
+		if st.Code() == codes.Unavailable {
+			return ErrUnavailable
+		}
+		if st.Code() == codes.InvalidArgument {
+			return invalidArgument(st)
+		}
+		if st.Code() == codes.AlreadyExists {
+			return fmt.Errorf("team %s already exists", team)
+		}
+		return fmt.Errorf("failed to create team: %w", err)
+	}
+	return nil
+}
+
+// DeleteTeam deletes a team owned by the user with its vault
+func (uc *UseCase) DeleteTeam(ctx context.Context, team string) error {
+	_, err := uc.cl.DeleteTeam(ctx, &server.DeleteTeamRequest{Team: team})
+	return teamError("failed to delete team", err)
+}
+
+// ListTeams returns the teams of the user with their role
+func (uc *UseCase) ListTeams(ctx context.Context) ([]*server.ListTeamsResponse_Team, error) {
+	r, err := uc.cl.ListTeams(ctx, &server.ListTeamsRequest{})
+	if err != nil {
+		return nil, teamError("failed to list teams", err)
+	}
+	return r.GetTeams(), nil
+}
+
+// GetTeamMembers returns the members of team
+func (uc *UseCase) GetTeamMembers(ctx context.Context, team string) ([]*server.TeamMember, error) {
+	r, err := uc.cl.GetTeam(ctx, &server.GetTeamRequest{Team: team})
+	if err != nil {
+		return nil, teamError("failed to get team", err)
+	}
+	return r.GetMembers(), nil
+}
+
+// SetTeamMember adds username to team or changes their role
+func (uc *UseCase) SetTeamMember(ctx context.Context, team, username string, role server.TeamRole) error {
+	_, err := uc.cl.SetTeamMember(ctx, &server.SetTeamMemberRequest{Team: team, Username: username, Role: role})
+	if st, ok := status.FromError(err); ok && st.Code() == codes.InvalidArgument {
+		return invalidArgument(st)
+	}
+	return teamError("failed to set member", err)
+}
+
+// RemoveTeamMember removes username from team, an empty username leaves it
+func (uc *UseCase) RemoveTeamMember(ctx context.Context, team, username string) error {
+	_, err := uc.cl.RemoveTeamMember(ctx, &server.RemoveTeamMemberRequest{Team: team, Username: username})
+	return teamError("failed to remove member", err)
+}
+
+// CheckPermission tells whether the user may use capability (read, write,
+// delete, list or share) on key, with the reason
+func (uc *UseCase) CheckPermission(ctx context.Context, key, capability string) (*server.CheckPermissionResponse, error) {
+	r, err := uc.cl.CheckPermission(ctx, &server.CheckPermissionRequest{Key: key, Capability: capability})
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok {
+			return nil, fmt.Errorf("failed to check permission: %w", err)
+		}
+		if st.Code() == codes.Unavailable {
+			return nil, ErrUnavailable
+		}
+		if st.Code() == codes.InvalidArgument {
+			return nil, invalidArgument(st)
+		}
+		return nil, fmt.Errorf("failed to check permission: %w", err)
+	}
+	return r, nil
+}
+
+// watchRetryDelay is how long Watch waits before it reconnects
+const watchRetryDelay = time.Second
+
+// Watch calls onEvent with the changes of key, or with prefix set of every
+// key starting with it, after the revision after until ctx is done or
+// onEvent fails. A zero after watches new changes only. When the stream
+// breaks it reconnects and resumes after the last event received.
+func (uc *UseCase) Watch(ctx context.Context, key string, prefix bool, after uint64, onEvent func(*server.WatchEvent) error) error {
+	for {
+		stream, err := uc.cl.Watch(ctx, &server.WatchRequest{Key: key, Prefix: prefix, AfterRevision: after})
+		for err == nil {
+			var e *server.WatchEvent
+			if e, err = stream.Recv(); err != nil {
+				break
+			}
+			after = e.GetRevision()
+			if err = onEvent(e); err != nil {
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		st, ok := status.FromError(err)
+		if !ok {
+			return fmt.Errorf("failed to watch: %w", err)
+		}
+		switch st.Code() {
+		case codes.Unavailable, codes.Aborted:
+			// the server is going away or we fell behind, resume below
+		case codes.OutOfRange:
+			return ErrWatchCompacted
+		case codes.PermissionDenied:
+			return ErrPermissionDenied
+		default:
+			return fmt.Errorf("failed to watch: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(watchRetryDelay):
+		}
+	}
+}
+
+// teamError maps the status codes of team management to errors
+func teamError(msg string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+	switch st.Code() {
+	case codes.Unavailable:
+		return ErrUnavailable
+	case codes.PermissionDenied:
+		return ErrPermissionDenied
+	case codes.NotFound, codes.FailedPrecondition:
+		return fmt.Errorf("%s: %s", msg, st.Message())
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// invalidArgument describes the fields rejected by the server
+func invalidArgument(st *status.Status) error {
+	var fields []string
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				fields = append(fields, v.GetField()+" "+v.GetDescription())
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidArgument, st.Message())
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidArgument, strings.Join(fields, "; "))
+}
+
 func (uc *UseCase) addTokenToContext(ctx context.Context) (context.Context, error) {
 	tokens := uc.header.Get("token")
 	if len(tokens) == 0 {
@@ -160,6 +991,10 @@ func (uc *UseCase) Register(ctx context.Context, username, password string) (con
 		if st.Code() == codes.AlreadyExists {
 			return ctx, ErrUsernameExists
 		}
+
+		if st.Code() == codes.InvalidArgument {
+			return ctx, invalidArgument(st)
+		}
 		return ctx, fmt.Errorf("failed to auth: %w", err)
 	}
 	return uc.addTokenToContext(ctx)
@@ -21,9 +21,8 @@ func upServer() (stop func(), err error) {
 	if err != nil {
 		log.Fatalf("failed to initialize storage: %v", err)
 	}
-	defer store.Close()
 
-	logic, err := usecase.New(store)
+	logic, err := usecase.New(store, pkg.NewNop())
 	if err != nil {
 		log.Fatalf("failed to initialize logic: %v", err)
 	}
@@ -31,7 +30,7 @@ func upServer() (stop func(), err error) {
 	host := "127.0.0.1:8080"
 	log.Printf("Server is running on grpc://%s\n", host)
 	grpcServer := grpc.NewServer()
-	ghandler := grpchandler.New(logic)
+	ghandler := grpchandler.New(logic, pkg.NewNop())
 
 	lis, err := net.Listen("tcp", host)
 	if err != nil {
@@ -51,6 +50,7 @@ func upServer() (stop func(), err error) {
 		<-ch
 		grpcServer.Stop()
 		lis.Close()
+		store.Close()
 	}()
 
 	return func() {
@@ -0,0 +1,371 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionGet      = "get"
+	ActionSet      = "set"
+	ActionDelete   = "delete"
+	ActionAuth     = "auth"
+	ActionRegister = "register"
+	ActionUnlock   = "unlock"
+
+	ActionEnrollTOTP  = "enroll_totp"
+	ActionConfirmTOTP = "confirm_totp"
+
+	ActionChangePassword = "change_password"
+	ActionDeleteAccount  = "delete_account"
+
+	ActionShare       = "share"
+	ActionRevokeShare = "revoke_share"
+
+	ActionCreateTeam       = "create_team"
+	ActionDeleteTeam       = "delete_team"
+	ActionSetTeamMember    = "set_team_member"
+	ActionRemoveTeamMember = "remove_team_member"
+
+	ActionPutPolicy    = "put_policy"
+	ActionDeletePolicy = "delete_policy"
+
+	ActionCreateShareLink = "create_share_link"
+	ActionRedeemShareLink = "redeem_share_link"
+
+	ActionExpire = "expire"
+
+	ActionSetTags = "set_tags"
+
+	ActionUpload     = "upload"
+	ActionDownload   = "download"
+	ActionDeleteFile = "delete_file"
+)
+
+// OutcomeOK is the outcome of a successful action
+const OutcomeOK = "ok"
+
+// Event is an action to record
+type Event struct {
+	User   string
+	Action string
+	Key    string
+	// Owner is the owner of Key when it is not User
+	Owner string
+	// Target is the user or the policy an action applies to
+	Target string
+	// Team is the team whose vault or membership the action is about
+	Team    string
+	Outcome string
+	Peer    string
+}
+
+// Entry is a line of the audit log. Every entry contains the hash of the
+// previous one, so editing or removing an entry breaks the chain.
+type Entry struct {
+	Seq      uint64    `json:"seq"`
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`
+	Action   string    `json:"action"`
+	Key      string    `json:"key,omitempty"`
+	Owner    string    `json:"owner,omitempty"`
+	Target   string    `json:"target,omitempty"`
+	Team     string    `json:"team,omitempty"`
+	Outcome  string    `json:"outcome"`
+	Peer     string    `json:"peer,omitempty"`
+	PrevHash string    `json:"prev_hash"`
+	Hash     string    `json:"hash"`
+}
+
+// Head is the sequence number and hash of the last entry. It is kept next to
+// the log so that removing entries from the end can be detected.
+type Head struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// ErrTampered is returned when the audit log does not match its hash chain
+var ErrTampered = errors.New("audit log has been tampered with")
+
+// maxLineSize limits the size of a single entry
+const maxLineSize = 1 << 20
+
+// Log is an append-only audit log stored in a file
+type Log struct {
+	mu   sync.Mutex
+	path string
+	key  []byte
+	file *os.File
+	head Head
+	// size is the length of the entries written as a whole
+	size int64
+	// err is the error of the last failed write, cleared by the next
+	// successful Record or by Ready
+	err error
+
+	now func() time.Time
+}
+
+// HeadPath returns the path of the head file of the log at path
+func HeadPath(path string) string {
+	return path + ".head"
+}
+
+// Open opens the audit log at path, creating it if needed. If key is not
+// empty entries are chained with HMAC-SHA256, otherwise with SHA-256.
+// Open fails if the existing log does not verify.
+func Open(path string, key []byte) (*Log, error) {
+	head, err := ReadHead(HeadPath(path))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := Verify(file, key, head)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Log{
+		path: path,
+		key:  key,
+		file: file,
+		head: last,
+		size: info.Size(),
+		now:  time.Now,
+	}, nil
+}
+
+// Record appends the event to the log
+func (l *Log) Record(e Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := Entry{
+		Seq:      l.head.Seq + 1,
+		Time:     l.now().UTC(),
+		User:     e.User,
+		Action:   e.Action,
+		Key:      e.Key,
+		Owner:    e.Owner,
+		Target:   e.Target,
+		Team:     e.Team,
+		Outcome:  e.Outcome,
+		Peer:     e.Peer,
+		PrevHash: l.head.Hash,
+	}
+	entry.Hash = entry.digest(l.key)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	line = append(line, '\n')
+	head := Head{Seq: entry.Seq, Hash: entry.Hash}
+	if err = l.write(line, head); err != nil {
+		// an entry the head does not point to would break the chain for
+		// the next ones
+		if truncErr := l.file.Truncate(l.size); truncErr != nil {
+			err = fmt.Errorf("%w, truncate: %v", err, truncErr)
+		}
+		l.err = err
+		return err
+	}
+	l.head = head
+	l.size += int64(len(line))
+	l.err = nil
+
+	return nil
+}
+
+func (l *Log) write(line []byte, head Head) error {
+	if _, err := l.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	return writeHead(HeadPath(l.path), head)
+}
+
+// Ready returns nil if the log can take entries. After a failed write it
+// checks that the log and its head can be written again.
+func (l *Log) Ready() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err == nil {
+		return nil
+	}
+
+	if err := l.file.Truncate(l.size); err != nil {
+		return fmt.Errorf("failed to truncate audit log: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	if err := writeHead(HeadPath(l.path), l.head); err != nil {
+		return err
+	}
+	l.err = nil
+
+	return nil
+}
+
+// Entries returns up to limit most recent entries of user, oldest first,
+// including accesses of others to the secrets of user. A limit of zero
+// returns all of them. The log is read from its own file, so Record is not
+// held up while it is scanned.
+func (l *Log) Entries(user string, limit int) ([]Entry, error) {
+	l.mu.Lock()
+	size := l.size
+	l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	err = scan(io.LimitReader(file, size), func(_ int, e Entry) error {
+		if e.User != user && e.Owner != user {
+			return nil
+		}
+		entries = append(entries, e)
+		if limit > 0 && len(entries) > limit {
+			entries = entries[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Close closes the log
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// Verify checks the hash chain of the log read from r and returns its last
+// entry. If head is not nil the log must contain the entry it points to.
+func Verify(r io.Reader, key []byte, head *Head) (Head, error) {
+	var last Head
+	var headFound bool
+
+	err := scan(r, func(line int, e Entry) error {
+		if e.Seq != last.Seq+1 {
+			return fmt.Errorf("%w: line %d: sequence number %d, want %d", ErrTampered, line, e.Seq, last.Seq+1)
+		}
+		if e.PrevHash != last.Hash {
+			return fmt.Errorf("%w: line %d: previous hash does not match", ErrTampered, line)
+		}
+		if !hmac.Equal([]byte(e.Hash), []byte(e.digest(key))) {
+			return fmt.Errorf("%w: line %d: hash does not match", ErrTampered, line)
+		}
+
+		if head != nil && e.Seq == head.Seq {
+			if e.Hash != head.Hash {
+				return fmt.Errorf("%w: line %d: hash does not match the head", ErrTampered, line)
+			}
+			headFound = true
+		}
+
+		last = Head{Seq: e.Seq, Hash: e.Hash}
+		return nil
+	})
+	if err != nil {
+		return Head{}, err
+	}
+
+	if head != nil && head.Seq != 0 && !headFound {
+		return Head{}, fmt.Errorf("%w: log ends at entry %d, head is at entry %d", ErrTampered, last.Seq, head.Seq)
+	}
+
+	return last, nil
+}
+
+// ReadHead reads the head file at path
+func ReadHead(path string) (*Head, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var head Head
+	if err = json.Unmarshal(data, &head); err != nil {
+		return nil, fmt.Errorf("failed to parse audit log head: %w", err)
+	}
+	return &head, nil
+}
+
+func writeHead(path string, head Head) error {
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write audit log head: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func scan(r io.Reader, fn func(line int, e Entry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrTampered, line, err)
+		}
+		if err := fn(line, e); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (e Entry) digest(key []byte) string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+
+	h := sha256.New()
+	if len(key) != 0 {
+		h = hmac.New(sha256.New, key)
+	}
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
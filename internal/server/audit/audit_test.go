@@ -0,0 +1,249 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestLog(t *testing.T, key []byte) (*Log, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	events := []Event{
+		{User: "alice", Action: ActionRegister, Outcome: OutcomeOK},
+		{User: "alice", Action: ActionSet, Key: "db", Outcome: OutcomeOK},
+		{User: "bob", Action: ActionAuth, Outcome: "invalid_password"},
+		{User: "alice", Action: ActionGet, Key: "db", Outcome: OutcomeOK},
+	}
+	for _, e := range events {
+		if err = l.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return l, path
+}
+
+func verifyFile(path string, key []byte) error {
+	head, err := ReadHead(HeadPath(path))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = Verify(f, key, head)
+	return err
+}
+
+func TestLog_Verify(t *testing.T) {
+	key := []byte("secret")
+
+	tests := []struct {
+		name    string
+		key     []byte
+		tamper  func(lines []string) []string
+		wantErr bool
+	}{
+		{
+			name: "untouched",
+			key:  key,
+		},
+		{
+			name: "edited",
+			key:  key,
+			tamper: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"key":"db"`, `"key":"other"`, 1)
+				return lines
+			},
+			wantErr: true,
+		},
+		{
+			name: "removedFromMiddle",
+			key:  key,
+			tamper: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			wantErr: true,
+		},
+		{
+			name: "truncated",
+			key:  key,
+			tamper: func(lines []string) []string {
+				return lines[:len(lines)-1]
+			},
+			wantErr: true,
+		},
+		{
+			name:    "wrongKey",
+			key:     []byte("other"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, path := openTestLog(t, key)
+
+			if tt.tamper != nil {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines := tt.tamper(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+				if err = os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := verifyFile(path, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrTampered) {
+				t.Errorf("Verify() error = %v, want %v", err, ErrTampered)
+			}
+		})
+	}
+}
+
+func TestLog_Entries(t *testing.T) {
+	l, _ := openTestLog(t, nil)
+
+	entries, err := l.Entries("alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Entries() got %d entries, want 3", len(entries))
+	}
+
+	entries, err = l.Entries("alice", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != ActionSet || entries[1].Action != ActionGet {
+		t.Errorf("Entries() got %+v, want the last set and get", entries)
+	}
+
+	entries, err = l.Entries("carol", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Entries() got %+v, want none", entries)
+	}
+}
+
+func TestLog_Entries_owner(t *testing.T) {
+	l, _ := openTestLog(t, nil)
+
+	if err := l.Record(Event{User: "bob", Action: ActionGet, Key: "db", Owner: "alice", Outcome: OutcomeOK}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := l.Entries("alice", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].User != "bob" || entries[0].Owner != "alice" {
+		t.Errorf("Entries() got %+v, want the get of bob", entries)
+	}
+}
+
+func TestLog_Ready(t *testing.T) {
+	l, path := openTestLog(t, []byte("key"))
+
+	if err := l.Ready(); err != nil {
+		t.Fatalf("Ready() error = %v, want nil", err)
+	}
+
+	// the head cannot be replaced while a directory takes its temporary name
+	tmp := HeadPath(path) + ".tmp"
+	if err := os.Mkdir(tmp, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(Event{User: "alice", Action: ActionSet, Key: "db", Outcome: OutcomeOK}); err == nil {
+		t.Fatal("Record() error = nil, want the failed head write")
+	}
+	if err := l.Ready(); err == nil {
+		t.Error("Ready() error = nil after a failed write")
+	}
+
+	if err := os.Remove(tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Ready(); err != nil {
+		t.Fatalf("Ready() error = %v, want nil once the head can be written", err)
+	}
+	if err := l.Record(Event{User: "alice", Action: ActionDelete, Key: "db", Outcome: OutcomeOK}); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyFile(path, []byte("key")); err != nil {
+		t.Errorf("Verify() error = %v, want the failed entry dropped from the chain", err)
+	}
+
+	entries, err := l.Entries("alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || entries[3].Action != ActionDelete {
+		t.Errorf("Entries() got %+v, want the delete after the first three", entries)
+	}
+}
+
+func TestOpen_continuesChain(t *testing.T) {
+	l, path := openTestLog(t, nil)
+	l.Close()
+
+	l, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err = l.Record(Event{User: "alice", Action: ActionDelete, Key: "db", Outcome: OutcomeOK}); err != nil {
+		t.Fatal(err)
+	}
+	if err = verifyFile(path, nil); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != 5 {
+		t.Errorf("log has %d entries, want 5", n)
+	}
+}
+
+func TestOpen_tampered(t *testing.T) {
+	l, path := openTestLog(t, nil)
+	l.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path, bytes.Replace(data, []byte("bob"), []byte("eve"), 1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = Open(path, nil); !errors.Is(err, ErrTampered) {
+		t.Errorf("Open() error = %v, want %v", err, ErrTampered)
+	}
+}
@@ -1,45 +1,459 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
 	"secret-keeper/internal/server/storage"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Config of the server.
+//
+// Every setting is resolved from, in increasing order of precedence, its
+// default, the JSON config file, the SECRET_KEEPER_<JSON NAME> environment
+// variable and the command line flag. Settings tagged with secret:"true"
+// are redacted by Print.
 type Config struct {
-	Host     string
-	DBConfig storage.Config
-}
+	Host            string        `json:"server_address" flag:"a" usage:"address of the gRPC server"`
+	URI             string        `json:"uri" flag:"u" usage:"address of itisadb"`
+	ShutdownTimeout time.Duration `json:"shutdown_timeout" flag:"shutdown-timeout" usage:"time to wait for in-flight requests on shutdown"`
 
-// Flag struct for parsing from env and cmd args.
-type Flag struct {
-	Host *string `json:"server_address,omitempty"`
-	URI  *string `json:"uri,omitempty"`
-}
+	HealthCheckInterval time.Duration `json:"health_check_interval" usage:"how often to ping itisadb for the health service"`
+	Reflection          bool          `json:"reflection" usage:"enable gRPC server reflection"`
+
+	MetricsAddress string `json:"metrics_address" usage:"address of the HTTP /metrics endpoint, empty to disable"`
+	GatewayAddress string `json:"gateway_address" usage:"address of the REST/JSON gateway, empty to disable"`
+	VaultAddress   string `json:"vault_address" usage:"address of the Vault KV v2 compatible API, empty to disable"`
+	VaultMount     string `json:"vault_mount" usage:"mount path of the secrets in the Vault compatible API"`
+
+	LogLevel  string `json:"log_level" usage:"log level: debug, info, warn or error"`
+	LogFormat string `json:"log_format" usage:"log format: json or console"`
+
+	AuditLog string `json:"audit_log" usage:"path of the audit log, empty to disable"`
+	AuditKey string `json:"audit_key" secret:"true" usage:"HMAC key of the audit log hash chain, required with audit_log"`
+
+	LoginMaxAttempts int           `json:"login_max_attempts" usage:"failed logins of a username or IP before it is locked out"`
+	LoginBackoff     time.Duration `json:"login_backoff" usage:"delay after the first failed login, doubled after every next one"`
+	LoginLockout     time.Duration `json:"login_lockout" usage:"how long a username or IP stays locked out"`
+
+	AdminToken string `json:"admin_token" secret:"true" usage:"token of admin calls, empty to disable them"`
+
+	UsernamePattern   string `json:"username_pattern" usage:"regular expression a new username has to match"`
+	PasswordMinLength int    `json:"password_min_length" usage:"minimum number of characters of a password"`
+	PasswordMaxLength int    `json:"password_max_length" usage:"maximum number of characters of a password, 0 for no limit"`
+	KeyMaxLength      int    `json:"key_max_length" usage:"maximum number of characters of a secret name, 0 for no limit"`
+	ValueMaxSize      int    `json:"value_max_size" usage:"maximum size of a secret in bytes, 0 for no limit"`
+
+	MaxSecretsPerUser int `json:"max_secrets_per_user" usage:"maximum number of secrets of a user, 0 for no limit"`
+	MaxBytesPerUser   int `json:"max_bytes_per_user" usage:"maximum total size of names and values of the secrets of a user, 0 for no limit"`
+	MaxFileSize       int `json:"max_file_size" usage:"maximum size of an uploaded file in bytes, 0 for no limit"`
+
+	SweepInterval time.Duration `json:"sweep_interval" usage:"how often to delete expired secrets and share links"`
+	ExpiryWarning time.Duration `json:"expiry_warning" usage:"how long before their expiry secrets are flagged as expiring soon"`
 
-var f Flag
+	WatchHistory int `json:"watch_history" usage:"how many recent changes are kept for watches to resume from"`
 
-func init() {
-	f.Host = flag.String("a", defaults["Host"], "-a=host")
-	f.URI = flag.String("u", defaults["URI"], "-u=uri")
+	// PrintConfig is set by the -print-config flag.
+	PrintConfig bool           `json:"-"`
+	DBConfig    storage.Config `json:"-"`
 }
 
 const (
-	defaultHost = "127.0.0.1:8080"
-	defaultURI  = "127.0.0.1:800"
+	defaultHost            = "127.0.0.1:8080"
+	defaultURI             = "127.0.0.1:800"
+	defaultShutdownTimeout = 10 * time.Second
+
+	defaultHealthCheckInterval = 5 * time.Second
+
+	defaultMetricsAddress = "127.0.0.1:9090"
+
+	defaultVaultMount = "secret"
+
+	defaultLogLevel  = "info"
+	defaultLogFormat = "json"
+
+	defaultLoginMaxAttempts = 5
+	defaultLoginBackoff     = time.Second
+	defaultLoginLockout     = 15 * time.Minute
+
+	defaultUsernamePattern   = `^[a-zA-Z0-9_.@-]{3,64}$`
+	defaultPasswordMinLength = 8
+	defaultPasswordMaxLength = 128
+	defaultKeyMaxLength      = 256
+	defaultValueMaxSize      = 64 << 10
+
+	defaultMaxSecretsPerUser = 1000
+	defaultMaxBytesPerUser   = 10 << 20
+	defaultMaxFileSize       = 32 << 20
+
+	defaultSweepInterval = time.Minute
+	defaultExpiryWarning = 24 * time.Hour
+
+	defaultWatchHistory = 1000
 )
 
-var defaults = map[string]string{
-	"Host": defaultHost,
-	"URI":  defaultURI,
+func defaults() Config {
+	return Config{
+		Host:            defaultHost,
+		URI:             defaultURI,
+		ShutdownTimeout: defaultShutdownTimeout,
+
+		HealthCheckInterval: defaultHealthCheckInterval,
+
+		MetricsAddress: defaultMetricsAddress,
+		VaultMount:     defaultVaultMount,
+
+		LogLevel:  defaultLogLevel,
+		LogFormat: defaultLogFormat,
+
+		LoginMaxAttempts: defaultLoginMaxAttempts,
+		LoginBackoff:     defaultLoginBackoff,
+		LoginLockout:     defaultLoginLockout,
+
+		UsernamePattern:   defaultUsernamePattern,
+		PasswordMinLength: defaultPasswordMinLength,
+		PasswordMaxLength: defaultPasswordMaxLength,
+		KeyMaxLength:      defaultKeyMaxLength,
+		ValueMaxSize:      defaultValueMaxSize,
+
+		MaxSecretsPerUser: defaultMaxSecretsPerUser,
+		MaxBytesPerUser:   defaultMaxBytesPerUser,
+		MaxFileSize:       defaultMaxFileSize,
+
+		SweepInterval: defaultSweepInterval,
+		ExpiryWarning: defaultExpiryWarning,
+
+		WatchHistory: defaultWatchHistory,
+	}
 }
 
+// envPrefix is prepended to the upper-cased JSON name of a setting.
+const envPrefix = "SECRET_KEEPER_"
+
+// envConfigFile holds the path to the config file when -c is not set.
+const envConfigFile = envPrefix + "CONFIG"
+
+const redacted = "[REDACTED]"
+
+// New loads the config from the config file, environment and command line.
 func New() (*Config, error) {
-	flag.Parse()
-
-	return &Config{
-		Host: *f.Host,
-		DBConfig: storage.Config{
-			URI: *f.URI,
-		},
-	}, nil
+	cfg, err := load(os.Args[0], os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	return cfg, err
+}
+
+func load(name string, args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := defaults()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("c", "", "path to a JSON config file (env "+envConfigFile+")")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the effective config and exit")
+
+	fields := settings(&cfg)
+	flagValues := make(map[string]string)
+	for _, s := range fields {
+		fs.Var(rawFlag{setting: s, values: flagValues}, s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile == "" {
+		*configFile, _ = lookupEnv(envConfigFile)
+	}
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile, fields); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range fields {
+		if v, ok := lookupEnv(s.env); ok {
+			if err := s.set(v); err != nil {
+				return nil, fmt.Errorf("env %s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, s := range fields {
+		if v, ok := flagValues[s.name]; ok {
+			if err := s.set(v); err != nil {
+				return nil, fmt.Errorf("flag -%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	cfg.DBConfig = storage.Config{
+		URI: cfg.URI,
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) loadFile(path string, fields []setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	byName := make(map[string]setting, len(fields))
+	for _, s := range fields {
+		byName[s.name] = s
+	}
+
+	for name, value := range raw {
+		s, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("config file %s: unknown setting %q", path, name)
+		}
+
+		var str string
+		if err = json.Unmarshal(value, &str); err != nil {
+			var list []string
+			if json.Unmarshal(value, &list) == nil {
+				str = strings.Join(list, ",")
+			} else {
+				// numbers and booleans
+				str = string(value)
+			}
+		}
+
+		if err = s.set(str); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, name, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the config is usable.
+func (c *Config) Validate() error {
+	var problems []string
+
+	if _, _, err := net.SplitHostPort(c.Host); err != nil {
+		problems = append(problems, fmt.Sprintf("server_address: %v", err))
+	}
+	if c.URI == "" {
+		problems = append(problems, "uri: must not be empty")
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, "shutdown_timeout: must be positive")
+	}
+	if c.HealthCheckInterval <= 0 {
+		problems = append(problems, "health_check_interval: must be positive")
+	}
+	if c.MetricsAddress != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddress); err != nil {
+			problems = append(problems, fmt.Sprintf("metrics_address: %v", err))
+		}
+	}
+	if c.GatewayAddress != "" {
+		if _, _, err := net.SplitHostPort(c.GatewayAddress); err != nil {
+			problems = append(problems, fmt.Sprintf("gateway_address: %v", err))
+		}
+	}
+	if c.VaultAddress != "" {
+		if _, _, err := net.SplitHostPort(c.VaultAddress); err != nil {
+			problems = append(problems, fmt.Sprintf("vault_address: %v", err))
+		}
+	}
+	if mount := strings.Trim(c.VaultMount, "/"); mount == "" || mount == "sys" || mount == "auth" || strings.Contains(mount, "/") {
+		problems = append(problems, "vault_mount: must be a single path segment other than sys and auth")
+	}
+	if c.AuditLog != "" && c.AuditKey == "" {
+		// without a key anyone able to write the log can rebuild the chain
+		problems = append(problems, "audit_key: must be set with audit_log")
+	}
+	if c.LoginMaxAttempts <= 0 {
+		problems = append(problems, "login_max_attempts: must be positive")
+	}
+	if c.LoginBackoff <= 0 {
+		problems = append(problems, "login_backoff: must be positive")
+	}
+	if c.LoginLockout < c.LoginBackoff {
+		problems = append(problems, "login_lockout: must not be less than login_backoff")
+	}
+	if _, err := regexp.Compile(c.UsernamePattern); err != nil {
+		problems = append(problems, fmt.Sprintf("username_pattern: %v", err))
+	}
+	if c.PasswordMinLength < 0 || c.PasswordMaxLength < 0 || c.KeyMaxLength < 0 || c.ValueMaxSize < 0 {
+		problems = append(problems, "password_min_length, password_max_length, key_max_length, value_max_size: must not be negative")
+	}
+	if c.MaxSecretsPerUser < 0 || c.MaxBytesPerUser < 0 || c.MaxFileSize < 0 {
+		problems = append(problems, "max_secrets_per_user, max_bytes_per_user, max_file_size: must not be negative")
+	}
+	if c.SweepInterval <= 0 {
+		problems = append(problems, "sweep_interval: must be positive")
+	}
+	if c.ExpiryWarning < 0 {
+		problems = append(problems, "expiry_warning: must not be negative")
+	}
+	if c.WatchHistory < 0 {
+		problems = append(problems, "watch_history: must not be negative")
+	}
+	if c.PasswordMaxLength != 0 && c.PasswordMaxLength < c.PasswordMinLength {
+		problems = append(problems, "password_max_length: must not be less than password_min_length")
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("log_level: unknown level %q", c.LogLevel))
+	}
+	switch c.LogFormat {
+	case "json", "console":
+	default:
+		problems = append(problems, fmt.Sprintf("log_format: unknown format %q", c.LogFormat))
+	}
+
+	if len(problems) != 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Print writes the effective config as JSON with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	out := make(map[string]interface{})
+	for _, s := range settings(c) {
+		out[s.name] = s.print()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// setting is a single configurable field of Config.
+type setting struct {
+	name   string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+func settings(c *Config) []setting {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	var list []setting
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		flagName := f.Tag.Get("flag")
+		if flagName == "" {
+			flagName = strings.ReplaceAll(name, "_", "-")
+		}
+
+		list = append(list, setting{
+			name:   name,
+			env:    envPrefix + strings.ToUpper(name),
+			flag:   flagName,
+			usage:  f.Tag.Get("usage"),
+			secret: f.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// rawFlag remembers the command line value of a setting so that it can be
+// applied after the config file and environment.
+type rawFlag struct {
+	setting
+	values map[string]string
+}
+
+func (f rawFlag) String() string {
+	return ""
+}
+
+func (f rawFlag) Set(v string) error {
+	f.values[f.name] = v
+	return nil
+}
+
+func (f rawFlag) IsBoolFlag() bool {
+	return f.value.IsValid() && f.value.Kind() == reflect.Bool
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (s setting) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.Int || s.value.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(n)
+	case s.value.Kind() == reflect.Slice && s.value.Type().Elem().Kind() == reflect.String:
+		var list []string
+		for _, el := range strings.Split(raw, ",") {
+			if el = strings.TrimSpace(el); el != "" {
+				list = append(list, el)
+			}
+		}
+		s.value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+
+	return nil
+}
+
+func (s setting) print() interface{} {
+	if s.secret && !s.value.IsZero() {
+		return redacted
+	}
+	if s.value.Type() == durationType {
+		return time.Duration(s.value.Int()).String()
+	}
+	return s.value.Interface()
 }
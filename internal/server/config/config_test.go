@@ -0,0 +1,242 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_load(t *testing.T) {
+	file := writeConfigFile(t, `{"server_address": "0.0.0.0:9000", "uri": "db:800", "shutdown_timeout": "30s"}`)
+
+	fromFile := func(c *Config) {
+		c.Host = "0.0.0.0:9000"
+		c.URI = "db:800"
+		c.ShutdownTimeout = 30 * time.Second
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		want    func(c *Config)
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name: "file",
+			args: []string{"-c", file},
+			want: fromFile,
+		},
+		{
+			name: "fileFromEnv",
+			env:  map[string]string{"SECRET_KEEPER_CONFIG": file},
+			want: fromFile,
+		},
+		{
+			name: "envOverridesFile",
+			args: []string{"-c", file},
+			env:  map[string]string{"SECRET_KEEPER_URI": "env:800"},
+			want: func(c *Config) {
+				fromFile(c)
+				c.URI = "env:800"
+			},
+		},
+		{
+			name: "flagOverridesEnv",
+			args: []string{"-c", file, "-u", "flag:800", "-shutdown-timeout", "1s"},
+			env:  map[string]string{"SECRET_KEEPER_URI": "env:800"},
+			want: func(c *Config) {
+				fromFile(c)
+				c.URI = "flag:800"
+				c.ShutdownTimeout = time.Second
+			},
+		},
+		{
+			name: "boolFlag",
+			args: []string{"-reflection", "-health-check-interval", "1m"},
+			want: func(c *Config) {
+				c.Reflection = true
+				c.HealthCheckInterval = time.Minute
+			},
+		},
+		{
+			name: "disableMetrics",
+			env:  map[string]string{"SECRET_KEEPER_METRICS_ADDRESS": ""},
+			want: func(c *Config) {
+				c.MetricsAddress = ""
+			},
+		},
+		{
+			name: "enableGateway",
+			args: []string{"-gateway-address", "127.0.0.1:8081"},
+			want: func(c *Config) {
+				c.GatewayAddress = "127.0.0.1:8081"
+			},
+		},
+		{
+			name: "enableVault",
+			args: []string{"-vault-address", "127.0.0.1:8200", "-vault-mount", "kv"},
+			want: func(c *Config) {
+				c.VaultAddress = "127.0.0.1:8200"
+				c.VaultMount = "kv"
+			},
+		},
+		{
+			name:    "invalidVaultMount",
+			args:    []string{"-vault-mount", "sys"},
+			wantErr: true,
+		},
+		{
+			name:    "invalidGatewayAddress",
+			env:     map[string]string{"SECRET_KEEPER_GATEWAY_ADDRESS": "8081"},
+			wantErr: true,
+		},
+		{
+			name: "enableAudit",
+			args: []string{"-audit-log", "audit.log"},
+			env:  map[string]string{"SECRET_KEEPER_AUDIT_KEY": "hmac-key"},
+			want: func(c *Config) {
+				c.AuditLog = "audit.log"
+				c.AuditKey = "hmac-key"
+			},
+		},
+		{
+			name:    "auditWithoutKey",
+			args:    []string{"-audit-log", "audit.log"},
+			wantErr: true,
+		},
+		{
+			name: "printConfig",
+			args: []string{"-print-config"},
+			want: func(c *Config) {
+				c.PrintConfig = true
+			},
+		},
+		{
+			name:    "invalidEnv",
+			env:     map[string]string{"SECRET_KEEPER_SHUTDOWN_TIMEOUT": "soon"},
+			wantErr: true,
+		},
+		{
+			name:    "invalidLogLevel",
+			args:    []string{"-log-level", "verbose"},
+			wantErr: true,
+		},
+		{
+			name:    "lockoutShorterThanBackoff",
+			args:    []string{"-login-backoff", "1m", "-login-lockout", "30s"},
+			wantErr: true,
+		},
+		{
+			name:    "invalidUsernamePattern",
+			args:    []string{"-username-pattern", "("},
+			wantErr: true,
+		},
+		{
+			name:    "invalidHost",
+			args:    []string{"-a", "localhost"},
+			wantErr: true,
+		},
+		{
+			name:    "unknownSetting",
+			args:    []string{"-c", writeConfigFile(t, `{"no_such_setting": 1}`)},
+			wantErr: true,
+		},
+		{
+			name:    "missingFile",
+			args:    []string{"-c", filepath.Join(t.TempDir(), "missing.json")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookupEnv := func(key string) (string, bool) {
+				v, ok := tt.env[key]
+				return v, ok
+			}
+
+			got, err := load("server", tt.args, lookupEnv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want := defaults()
+			if tt.want != nil {
+				tt.want(&want)
+			}
+			want.DBConfig.URI = want.URI
+
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("load() got = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestConfig_Print(t *testing.T) {
+	cfg := defaults()
+	cfg.AuditKey = "hmac-key"
+
+	var buf bytes.Buffer
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`"server_address": "127.0.0.1:8080"`, `"shutdown_timeout": "10s"`, `"audit_key": "[REDACTED]"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Print() = %s, want it to contain %s", buf.String(), want)
+		}
+	}
+}
+
+func Test_settingPrint(t *testing.T) {
+	password := "hunter2"
+	empty := ""
+
+	tests := []struct {
+		name string
+		s    setting
+		want interface{}
+	}{
+		{
+			name: "secret",
+			s:    setting{secret: true, value: reflect.ValueOf(&password).Elem()},
+			want: redacted,
+		},
+		{
+			name: "emptySecret",
+			s:    setting{secret: true, value: reflect.ValueOf(&empty).Elem()},
+			want: "",
+		},
+		{
+			name: "plain",
+			s:    setting{value: reflect.ValueOf(&password).Elem()},
+			want: password,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.print(); got != tt.want {
+				t.Errorf("print() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
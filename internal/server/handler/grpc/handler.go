@@ -3,26 +3,54 @@ package grpchandler
 import (
 	"context"
 	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"secret-keeper/internal/server/policy"
 	"secret-keeper/internal/server/storage"
 	"secret-keeper/internal/server/usecase"
+	"secret-keeper/internal/server/validate"
+	"secret-keeper/internal/server/watch"
+	"secret-keeper/pkg"
 	"secret-keeper/pkg/api/server"
+	"sort"
+	"strconv"
+	"time"
 )
 
+// maxChunkSize limits the chunks of an upload
+const maxChunkSize = 1 << 20
+
+// retryAfterKey is the metadata key telling how many seconds to wait
+// before the next login
+const retryAfterKey = "retry-after"
+
 type Handler struct {
-	logic usecase.IUseCase
+	logic  usecase.IUseCase
+	logger pkg.Logger
 	server.UnimplementedSecretKeeperServer
 }
 
-func New(logic usecase.IUseCase) *Handler {
-	return &Handler{logic: logic}
+func New(logic usecase.IUseCase, logger pkg.Logger) *Handler {
+	return &Handler{logic: logic, logger: logger}
 }
 
 func (h *Handler) Auth(ctx context.Context, req *server.AuthRequest) (*server.AuthResponse, error) {
-	_, err := h.logic.Auth(ctx, req.GetUsername(), req.GetPassword())
+	_, err := h.logic.Auth(ctx, req.GetUsername(), req.GetPassword(), req.GetCode())
 	if err != nil {
-		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, usecase.ErrInvalidPassword) {
+		var locked *usecase.LockedError
+		if errors.As(err, &locked) {
+			return nil, lockedError(ctx, locked)
+		}
+		if errors.Is(err, usecase.ErrTOTPRequired) {
+			return nil, codeRequired(err)
+		}
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, usecase.ErrInvalidPassword) ||
+			errors.Is(err, usecase.ErrInvalidCode) {
 			return nil, status.Error(codes.NotFound, err.Error())
 		}
 		return nil, err
@@ -33,6 +61,10 @@ func (h *Handler) Auth(ctx context.Context, req *server.AuthRequest) (*server.Au
 func (h *Handler) Register(ctx context.Context, req *server.RegisterRequest) (*server.RegisterResponse, error) {
 	_, err := h.logic.Register(ctx, req.GetUsername(), req.GetPassword())
 	if err != nil {
+		var invalid *validate.Error
+		if errors.As(err, &invalid) {
+			return nil, invalidArgument(invalid)
+		}
 		if errors.Is(err, storage.ErrAlreadyExists) {
 			return nil, status.Error(codes.AlreadyExists, err.Error())
 		}
@@ -42,39 +74,861 @@ func (h *Handler) Register(ctx context.Context, req *server.RegisterRequest) (*s
 }
 
 func (h *Handler) Get(ctx context.Context, req *server.GetRequest) (*server.GetResponse, error) {
-	v, err := h.logic.Get(ctx, req.GetKey())
+	if req.GetOwner() != "" && req.GetTeam() != "" {
+		return nil, status.Error(codes.InvalidArgument, "owner and team are exclusive")
+	}
+
+	var v string
+	var err error
+	switch {
+	case req.GetOwner() != "":
+		v, err = h.logic.GetShared(ctx, req.GetOwner(), req.GetKey())
+	case req.GetTeam() != "":
+		v, err = h.logic.TeamGet(ctx, req.GetTeam(), req.GetKey())
+	default:
+		v, err = h.logic.Get(ctx, req.GetKey())
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			return nil, status.Error(codes.NotFound, err.Error())
 		}
+		if errors.Is(err, usecase.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
 		return nil, err
 	}
 	return &server.GetResponse{Value: v}, nil
 }
 
 func (h *Handler) Set(ctx context.Context, req *server.SetRequest) (*server.SetResponse, error) {
-	err := h.logic.Set(ctx, req.GetKey(), req.GetValue())
+	if req.GetOwner() != "" && req.GetTeam() != "" {
+		return nil, status.Error(codes.InvalidArgument, "owner and team are exclusive")
+	}
+
+	expiresAt, err := expiryFromProto(req.GetTtl(), req.GetExpiresAt())
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.GetOwner() != "":
+		err = h.logic.SetShared(ctx, req.GetOwner(), req.GetKey(), req.GetValue(), expiresAt)
+	case req.GetTeam() != "":
+		err = h.logic.TeamSet(ctx, req.GetTeam(), req.GetKey(), req.GetValue(), expiresAt)
+	default:
+		err = h.logic.Set(ctx, req.GetKey(), req.GetValue(), expiresAt)
+	}
 	if err != nil {
+		var invalid *validate.Error
+		if errors.As(err, &invalid) {
+			return nil, invalidArgument(invalid)
+		}
+		var quota *usecase.QuotaError
+		if errors.As(err, &quota) {
+			return nil, quotaExceeded(quota)
+		}
+		if errors.Is(err, usecase.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
 		return nil, err
 	}
 	return &server.SetResponse{}, nil
 }
 
-func (h *Handler) GetAllNames(ctx context.Context, _ *server.GetAllNamesRequest) (*server.GetAllNamesResponse, error) {
-	keys, err := h.logic.GetAllNames(ctx)
+// expiryFromProto returns when a secret set with ttl or expiresAt expires
+func expiryFromProto(ttl *durationpb.Duration, expiresAt *timestamppb.Timestamp) (time.Time, error) {
+	switch {
+	case ttl != nil && expiresAt != nil:
+		return time.Time{}, status.Error(codes.InvalidArgument, "ttl and expires_at are exclusive")
+	case ttl != nil:
+		return time.Now().Add(ttl.AsDuration()), nil
+	case expiresAt != nil:
+		return expiresAt.AsTime(), nil
+	}
+	return time.Time{}, nil
+}
+
+func (h *Handler) BatchGet(ctx context.Context, req *server.BatchGetRequest) (*server.BatchGetResponse, error) {
+	results, err := h.logic.BatchGet(ctx, req.GetKeys())
+	if err != nil {
+		return nil, batchError(err)
+	}
+	return &server.BatchGetResponse{Results: batchResultsToProto(results)}, nil
+}
+
+func (h *Handler) BatchSet(ctx context.Context, req *server.BatchSetRequest) (*server.BatchSetResponse, error) {
+	items := make([]usecase.BatchItem, 0, len(req.GetItems()))
+	for _, item := range req.GetItems() {
+		expiresAt, err := expiryFromProto(item.GetTtl(), item.GetExpiresAt())
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, usecase.BatchItem{Key: item.GetKey(), Value: item.GetValue(), ExpiresAt: expiresAt})
+	}
+
+	results, err := h.logic.BatchSet(ctx, items, req.GetTransactional())
+	if err != nil {
+		return nil, batchError(err)
+	}
+	return &server.BatchSetResponse{Results: batchResultsToProto(results)}, nil
+}
+
+func (h *Handler) BatchDelete(ctx context.Context, req *server.BatchDeleteRequest) (*server.BatchDeleteResponse, error) {
+	results, err := h.logic.BatchDelete(ctx, req.GetKeys())
 	if err != nil {
+		return nil, batchError(err)
+	}
+	return &server.BatchDeleteResponse{Results: batchResultsToProto(results)}, nil
+}
+
+// batchError maps the error of a batch rejected as a whole
+func batchError(err error) error {
+	var invalid *validate.Error
+	if errors.As(err, &invalid) {
+		return invalidArgument(invalid)
+	}
+	return err
+}
+
+func batchResultsToProto(results []usecase.BatchResult) []*server.BatchResult {
+	list := make([]*server.BatchResult, 0, len(results))
+	for _, r := range results {
+		st := batchItemStatus(r.Err)
+		list = append(list, &server.BatchResult{
+			Key:     r.Key,
+			Value:   r.Value,
+			Code:    int32(st.Code()),
+			Message: st.Message(),
+		})
+	}
+	return list
+}
+
+// batchItemStatus maps the error of an item of a batch to a status the way
+// the single item calls do
+func batchItemStatus(err error) *status.Status {
+	var invalid *validate.Error
+	var quota *usecase.QuotaError
+	switch {
+	case err == nil:
+		return status.New(codes.OK, "")
+	case errors.As(err, &invalid):
+		return status.New(codes.InvalidArgument, err.Error())
+	case errors.As(err, &quota):
+		return status.New(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, usecase.ErrBatchAborted):
+		return status.New(codes.Aborted, err.Error())
+	case errors.Is(err, usecase.ErrPermissionDenied):
+		return status.New(codes.PermissionDenied, err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		return status.New(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrUnavailable):
+		return status.New(codes.Unavailable, err.Error())
+	}
+	return status.New(codes.Unknown, err.Error())
+}
+
+func (h *Handler) GetAllNames(ctx context.Context, req *server.GetAllNamesRequest) (*server.GetAllNamesResponse, error) {
+	var infos []usecase.SecretInfo
+	var err error
+	if req.GetTeam() != "" {
+		infos, err = h.logic.TeamGetAllNames(ctx, req.GetTeam())
+	} else {
+		infos, err = h.logic.GetAllNames(ctx)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
 		return nil, err
 	}
-	return &server.GetAllNamesResponse{Vars: keys}, nil
+
+	resp := &server.GetAllNamesResponse{
+		Vars:    make([]string, 0, len(infos)),
+		Secrets: make([]*server.SecretInfo, 0, len(infos)),
+	}
+	for _, info := range infos {
+		resp.Vars = append(resp.Vars, info.Name)
+		resp.Secrets = append(resp.Secrets, secretInfoToProto(info))
+	}
+	return resp, nil
+}
+
+func (h *Handler) ListSecrets(ctx context.Context, req *server.ListSecretsRequest) (*server.ListSecretsResponse, error) {
+	page, err := h.logic.ListSecrets(ctx, usecase.ListOptions{
+		Prefix:    req.GetPrefix(),
+		Tags:      req.GetTags(),
+		PageSize:  int(req.GetPageSize()),
+		PageToken: req.GetPageToken(),
+	})
+	if err != nil {
+		var invalid *validate.Error
+		if errors.As(err, &invalid) {
+			return nil, invalidArgument(invalid)
+		}
+		return nil, err
+	}
+
+	resp := &server.ListSecretsResponse{
+		Secrets:       make([]*server.SecretInfo, 0, len(page.Secrets)),
+		NextPageToken: page.NextPageToken,
+	}
+	for _, info := range page.Secrets {
+		resp.Secrets = append(resp.Secrets, secretInfoToProto(info))
+	}
+	return resp, nil
+}
+
+func (h *Handler) SetTags(ctx context.Context, req *server.SetTagsRequest) (*server.SetTagsResponse, error) {
+	err := h.logic.SetTags(ctx, req.GetKey(), req.GetTags())
+	if err != nil {
+		var invalid *validate.Error
+		if errors.As(err, &invalid) {
+			return nil, invalidArgument(invalid)
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		if errors.Is(err, usecase.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, err
+	}
+	return &server.SetTagsResponse{}, nil
+}
+
+func secretInfoToProto(info usecase.SecretInfo) *server.SecretInfo {
+	s := &server.SecretInfo{Name: info.Name, ExpiringSoon: info.ExpiringSoon, Tags: info.Tags}
+	if !info.ExpiresAt.IsZero() {
+		s.ExpiresAt = timestamppb.New(info.ExpiresAt)
+	}
+	return s
 }
 
 func (h *Handler) Delete(ctx context.Context, req *server.DeleteRequest) (*server.DeleteResponse, error) {
-	err := h.logic.Delete(ctx, req.GetKey())
+	var err error
+	if req.GetTeam() != "" {
+		err = h.logic.TeamDelete(ctx, req.GetTeam(), req.GetKey())
+	} else {
+		err = h.logic.Delete(ctx, req.GetKey())
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			return nil, status.Error(codes.NotFound, err.Error())
 		}
+		if errors.Is(err, usecase.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
 		return nil, err
 	}
 	return &server.DeleteResponse{}, nil
 }
+
+func (h *Handler) AuditLog(ctx context.Context, req *server.AuditLogRequest) (*server.AuditLogResponse, error) {
+	if req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+
+	entries, err := h.logic.AuditLog(ctx, int(req.GetLimit()))
+	if err != nil {
+		if errors.Is(err, usecase.ErrAuditDisabled) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, err
+	}
+
+	resp := &server.AuditLogResponse{Entries: make([]*server.AuditEntry, 0, len(entries))}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, &server.AuditEntry{
+			Seq:     e.Seq,
+			Time:    timestamppb.New(e.Time),
+			Action:  e.Action,
+			Key:     e.Key,
+			Outcome: e.Outcome,
+			Peer:    e.Peer,
+			Hash:    e.Hash,
+			Owner:   e.Owner,
+			Target:  e.Target,
+			Team:    e.Team,
+		})
+	}
+	return resp, nil
+}
+
+func (h *Handler) Unlock(ctx context.Context, req *server.UnlockRequest) (*server.UnlockResponse, error) {
+	if req.GetUsername() == "" && req.GetPeer() == "" {
+		return nil, status.Error(codes.InvalidArgument, "username or peer is required")
+	}
+
+	unlocked, err := h.logic.Unlock(ctx, req.GetUsername(), req.GetPeer())
+	if err != nil {
+		if errors.Is(err, usecase.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, err
+	}
+	return &server.UnlockResponse{Unlocked: unlocked}, nil
+}
+
+func (h *Handler) EnrollTOTP(ctx context.Context, _ *server.EnrollTOTPRequest) (*server.EnrollTOTPResponse, error) {
+	e, err := h.logic.EnrollTOTP(ctx)
+	if err != nil {
+		if errors.Is(err, usecase.ErrTOTPEnabled) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, err
+	}
+	return &server.EnrollTOTPResponse{Secret: e.Secret, Url: e.URL, RecoveryCodes: e.RecoveryCodes}, nil
+}
+
+func (h *Handler) ConfirmTOTP(ctx context.Context, req *server.ConfirmTOTPRequest) (*server.ConfirmTOTPResponse, error) {
+	err := h.logic.ConfirmTOTP(ctx, req.GetCode())
+	if err != nil {
+		if errors.Is(err, usecase.ErrTOTPNotEnrolled) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if errors.Is(err, usecase.ErrInvalidCode) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, err
+	}
+	return &server.ConfirmTOTPResponse{}, nil
+}
+
+func (h *Handler) ChangePassword(ctx context.Context, req *server.ChangePasswordRequest) (*server.ChangePasswordResponse, error) {
+	revoked, err := h.logic.ChangePassword(ctx, req.GetOldPassword(), req.GetNewPassword())
+	if err != nil {
+		var invalid *validate.Error
+		if errors.As(err, &invalid) {
+			return nil, invalidArgument(invalid)
+		}
+		var locked *usecase.LockedError
+		if errors.As(err, &locked) {
+			return nil, lockedError(ctx, locked)
+		}
+		if errors.Is(err, usecase.ErrInvalidPassword) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, err
+	}
+	return &server.ChangePasswordResponse{RevokedSessions: int32(revoked)}, nil
+}
+
+func (h *Handler) DeleteAccount(ctx context.Context, req *server.DeleteAccountRequest) (*server.DeleteAccountResponse, error) {
+	err := h.logic.DeleteAccount(ctx, req.GetPassword(), req.GetCode())
+	if err != nil {
+		var locked *usecase.LockedError
+		if errors.As(err, &locked) {
+			return nil, lockedError(ctx, locked)
+		}
+		if errors.Is(err, usecase.ErrTOTPRequired) {
+			return nil, codeRequired(err)
+		}
+		if errors.Is(err, usecase.ErrInvalidPassword) || errors.Is(err, usecase.ErrInvalidCode) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		if errors.Is(err, usecase.ErrLastOwner) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, err
+	}
+	return &server.DeleteAccountResponse{}, nil
+}
+
+func (h *Handler) GetUsage(ctx context.Context, _ *server.GetUsageRequest) (*server.GetUsageResponse, error) {
+	usage, err := h.logic.GetUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &server.GetUsageResponse{
+		Secrets:    int64(usage.Secrets),
+		Files:      int64(usage.Files),
+		Bytes:      int64(usage.Bytes),
+		MaxSecrets: int64(usage.MaxSecrets),
+		MaxBytes:   int64(usage.MaxBytes),
+	}, nil
+}
+
+func (h *Handler) ShareSecret(ctx context.Context, req *server.ShareSecretRequest) (*server.ShareSecretResponse, error) {
+	err := h.logic.ShareSecret(ctx, req.GetKey(), req.GetRecipient(), shareModeFromProto(req.GetMode()))
+	if err != nil {
+		var invalid *validate.Error
+		if errors.As(err, &invalid) {
+			return nil, invalidArgument(invalid)
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		if errors.Is(err, usecase.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, err
+	}
+	return &server.ShareSecretResponse{}, nil
+}
+
+func (h *Handler) RevokeShare(ctx context.Context, req *server.RevokeShareRequest) (*server.RevokeShareResponse, error) {
+	revoked, err := h.logic.RevokeShare(ctx, req.GetKey(), req.GetRecipient())
+	if err != nil {
+		if errors.Is(err, usecase.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, err
+	}
+	return &server.RevokeShareResponse{Revoked: revoked}, nil
+}
+
+func (h *Handler) ListSharedWithMe(ctx context.Context, _ *server.ListSharedWithMeRequest) (*server.ListSharedWithMeResponse, error) {
+	shares, err := h.logic.ListSharedWithMe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &server.ListSharedWithMeResponse{Secrets: sharesToProto(shares)}, nil
+}
+
+func (h *Handler) ListSharedByMe(ctx context.Context, _ *server.ListSharedByMeRequest) (*server.ListSharedByMeResponse, error) {
+	shares, err := h.logic.ListSharedByMe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &server.ListSharedByMeResponse{Secrets: sharesToProto(shares)}, nil
+}
+
+func (h *Handler) CreateTeam(ctx context.Context, req *server.CreateTeamRequest) (*server.CreateTeamResponse, error) {
+	err := h.logic.CreateTeam(ctx, req.GetTeam())
+	if err != nil {
+		var invalid *validate.Error
+		if errors.As(err, &invalid) {
+			return nil, invalidArgument(invalid)
+		}
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		return nil, err
+	}
+	return &server.CreateTeamResponse{}, nil
+}
+
+func (h *Handler) DeleteTeam(ctx context.Context, req *server.DeleteTeamRequest) (*server.DeleteTeamResponse, error) {
+	if err := h.logic.DeleteTeam(ctx, req.GetTeam()); err != nil {
+		return nil, teamError(err)
+	}
+	return &server.DeleteTeamResponse{}, nil
+}
+
+func (h *Handler) ListTeams(ctx context.Context, _ *server.ListTeamsRequest) (*server.ListTeamsResponse, error) {
+	memberships, err := h.logic.ListTeams(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &server.ListTeamsResponse{Teams: make([]*server.ListTeamsResponse_Team, 0, len(memberships))}
+	for _, m := range memberships {
+		resp.Teams = append(resp.Teams, &server.ListTeamsResponse_Team{Team: m.Team, Role: teamRoleToProto(m.Role)})
+	}
+	return resp, nil
+}
+
+func (h *Handler) GetTeam(ctx context.Context, req *server.GetTeamRequest) (*server.GetTeamResponse, error) {
+	team, err := h.logic.GetTeam(ctx, req.GetTeam())
+	if err != nil {
+		return nil, teamError(err)
+	}
+
+	resp := &server.GetTeamResponse{Team: team.Name, Members: make([]*server.TeamMember, 0, len(team.Members))}
+	for username, role := range team.Members {
+		resp.Members = append(resp.Members, &server.TeamMember{Username: username, Role: teamRoleToProto(role)})
+	}
+	sort.Slice(resp.Members, func(i, j int) bool {
+		return resp.Members[i].Username < resp.Members[j].Username
+	})
+	return resp, nil
+}
+
+func (h *Handler) SetTeamMember(ctx context.Context, req *server.SetTeamMemberRequest) (*server.SetTeamMemberResponse, error) {
+	err := h.logic.SetTeamMember(ctx, req.GetTeam(), req.GetUsername(), teamRoleFromProto(req.GetRole()))
+	if err != nil {
+		var invalid *validate.Error
+		if errors.As(err, &invalid) {
+			return nil, invalidArgument(invalid)
+		}
+		return nil, teamError(err)
+	}
+	return &server.SetTeamMemberResponse{}, nil
+}
+
+func (h *Handler) RemoveTeamMember(ctx context.Context, req *server.RemoveTeamMemberRequest) (*server.RemoveTeamMemberResponse, error) {
+	if err := h.logic.RemoveTeamMember(ctx, req.GetTeam(), req.GetUsername()); err != nil {
+		return nil, teamError(err)
+	}
+	return &server.RemoveTeamMemberResponse{}, nil
+}
+
+func (h *Handler) PutPolicy(ctx context.Context, req *server.PutPolicyRequest) (*server.PutPolicyResponse, error) {
+	err := h.logic.PutPolicy(ctx, policyFromProto(req.GetPolicy()))
+	if err != nil {
+		var invalid *validate.Error
+		if errors.As(err, &invalid) {
+			return nil, invalidArgument(invalid)
+		}
+		return nil, policyError(err)
+	}
+	return &server.PutPolicyResponse{}, nil
+}
+
+func (h *Handler) GetPolicy(ctx context.Context, req *server.GetPolicyRequest) (*server.GetPolicyResponse, error) {
+	p, err := h.logic.GetPolicy(ctx, req.GetName())
+	if err != nil {
+		return nil, policyError(err)
+	}
+	return &server.GetPolicyResponse{Policy: policyToProto(p)}, nil
+}
+
+func (h *Handler) DeletePolicy(ctx context.Context, req *server.DeletePolicyRequest) (*server.DeletePolicyResponse, error) {
+	if err := h.logic.DeletePolicy(ctx, req.GetName()); err != nil {
+		return nil, policyError(err)
+	}
+	return &server.DeletePolicyResponse{}, nil
+}
+
+func (h *Handler) ListPolicies(ctx context.Context, _ *server.ListPoliciesRequest) (*server.ListPoliciesResponse, error) {
+	policies, err := h.logic.ListPolicies(ctx)
+	if err != nil {
+		return nil, policyError(err)
+	}
+
+	resp := &server.ListPoliciesResponse{Policies: make([]*server.Policy, 0, len(policies))}
+	for _, p := range policies {
+		resp.Policies = append(resp.Policies, policyToProto(p))
+	}
+	return resp, nil
+}
+
+func (h *Handler) CheckPermission(ctx context.Context, req *server.CheckPermissionRequest) (*server.CheckPermissionResponse, error) {
+	d, err := h.logic.CheckPermission(ctx, req.GetUsername(), req.GetKey(), req.GetCapability())
+	if err != nil {
+		var invalid *validate.Error
+		if errors.As(err, &invalid) {
+			return nil, invalidArgument(invalid)
+		}
+		return nil, policyError(err)
+	}
+	return &server.CheckPermissionResponse{Allowed: d.Allowed, Policy: d.Policy, Reason: d.Reason}, nil
+}
+
+func (h *Handler) CreateShareLink(ctx context.Context, req *server.CreateShareLinkRequest) (*server.CreateShareLinkResponse, error) {
+	link, err := h.logic.CreateShareLink(ctx, req.GetKey(), req.GetTtl().AsDuration(), int(req.GetMaxViews()), req.GetPassphrase())
+	if err != nil {
+		var invalid *validate.Error
+		if errors.As(err, &invalid) {
+			return nil, invalidArgument(invalid)
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		if errors.Is(err, usecase.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, err
+	}
+	return &server.CreateShareLinkResponse{
+		Token:     link.Token,
+		ExpiresAt: timestamppb.New(link.ExpiresAt),
+		MaxViews:  int32(link.MaxViews),
+	}, nil
+}
+
+func (h *Handler) RedeemShareLink(ctx context.Context, req *server.RedeemShareLinkRequest) (*server.RedeemShareLinkResponse, error) {
+	redeemed, err := h.logic.RedeemShareLink(ctx, req.GetToken(), req.GetPassphrase())
+	if err != nil {
+		var locked *usecase.LockedError
+		if errors.As(err, &locked) {
+			return nil, lockedError(ctx, locked)
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "share link not found or expired")
+		}
+		if errors.Is(err, usecase.ErrInvalidPassphrase) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, err
+	}
+	return &server.RedeemShareLinkResponse{
+		Owner:     redeemed.Owner,
+		Key:       redeemed.Key,
+		Value:     redeemed.Value,
+		ViewsLeft: int32(redeemed.ViewsLeft),
+	}, nil
+}
+
+func (h *Handler) Upload(stream server.SecretKeeper_UploadServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	header := first.GetHeader()
+	if header == nil {
+		return status.Error(codes.InvalidArgument, "the first message must be the header")
+	}
+
+	next := func() ([]byte, error) {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		chunk, ok := req.GetData().(*server.UploadRequest_Chunk)
+		if !ok {
+			return nil, status.Error(codes.InvalidArgument, "only the first message may be the header")
+		}
+		if len(chunk.Chunk) > maxChunkSize {
+			return nil, status.Errorf(codes.InvalidArgument, "chunks must be at most %d bytes", maxChunkSize)
+		}
+		return chunk.Chunk, nil
+	}
+
+	info, err := h.logic.Upload(stream.Context(), header.GetKey(), header.GetSha256(), next)
+	if err != nil {
+		return fileError(err)
+	}
+	return stream.SendAndClose(&server.UploadResponse{File: fileInfoToProto(info)})
+}
+
+func (h *Handler) Download(req *server.DownloadRequest, stream server.SecretKeeper_DownloadServer) error {
+	err := h.logic.Download(stream.Context(), req.GetKey(), func(info usecase.FileInfo) error {
+		return stream.Send(&server.DownloadResponse{Data: &server.DownloadResponse_File{File: fileInfoToProto(info)}})
+	}, func(chunk []byte) error {
+		return stream.Send(&server.DownloadResponse{Data: &server.DownloadResponse_Chunk{Chunk: chunk}})
+	})
+	return fileError(err)
+}
+
+func (h *Handler) ListFiles(ctx context.Context, _ *server.ListFilesRequest) (*server.ListFilesResponse, error) {
+	infos, err := h.logic.ListFiles(ctx)
+	if err != nil {
+		return nil, fileError(err)
+	}
+
+	resp := &server.ListFilesResponse{Files: make([]*server.FileInfo, 0, len(infos))}
+	for _, info := range infos {
+		resp.Files = append(resp.Files, fileInfoToProto(info))
+	}
+	return resp, nil
+}
+
+func (h *Handler) DeleteFile(ctx context.Context, req *server.DeleteFileRequest) (*server.DeleteFileResponse, error) {
+	if err := h.logic.DeleteFile(ctx, req.GetKey()); err != nil {
+		return nil, fileError(err)
+	}
+	return &server.DeleteFileResponse{}, nil
+}
+
+// fileError maps the errors of files to status codes
+func fileError(err error) error {
+	var invalid *validate.Error
+	var quota *usecase.QuotaError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &invalid):
+		return invalidArgument(invalid)
+	case errors.As(err, &quota):
+		return quotaExceeded(quota)
+	case errors.Is(err, usecase.ErrChecksumMismatch):
+		return status.Error(codes.DataLoss, err.Error())
+	case errors.Is(err, usecase.ErrFileChanged):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, usecase.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Error(codes.NotFound, "file not found")
+	}
+	return err
+}
+
+func fileInfoToProto(info usecase.FileInfo) *server.FileInfo {
+	return &server.FileInfo{Key: info.Key, Size: info.Size, Sha256: info.SHA256}
+}
+
+func (h *Handler) Watch(req *server.WatchRequest, stream server.SecretKeeper_WatchServer) error {
+	err := h.logic.Watch(stream.Context(), req.GetKey(), req.GetPrefix(), req.GetAfterRevision(), func(e watch.Event) error {
+		return stream.Send(&server.WatchEvent{
+			Revision: e.Revision,
+			Key:      e.Key,
+			Type:     watchEventTypeToProto(e.Type),
+			Time:     timestamppb.New(e.Time),
+		})
+	})
+	switch {
+	case errors.Is(err, watch.ErrCompacted):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, watch.ErrLagged):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, watch.ErrClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, usecase.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	return err
+}
+
+func watchEventTypeToProto(change string) server.WatchEventType {
+	switch change {
+	case watch.Created:
+		return server.WatchEventType_WATCH_EVENT_TYPE_CREATED
+	case watch.Updated:
+		return server.WatchEventType_WATCH_EVENT_TYPE_UPDATED
+	case watch.Deleted:
+		return server.WatchEventType_WATCH_EVENT_TYPE_DELETED
+	}
+	return server.WatchEventType_WATCH_EVENT_TYPE_UNSPECIFIED
+}
+
+// policyError maps the errors of policy management to status codes
+func policyError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, usecase.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return err
+}
+
+func policyFromProto(p *server.Policy) policy.Policy {
+	rules := make([]policy.Rule, 0, len(p.GetRules()))
+	for _, r := range p.GetRules() {
+		rules = append(rules, policy.Rule{Path: r.GetPath(), Capabilities: r.GetCapabilities(), Deny: r.GetDeny()})
+	}
+	return policy.Policy{Name: p.GetName(), Rules: rules, Users: p.GetUsers(), Teams: p.GetTeams()}
+}
+
+func policyToProto(p policy.Policy) *server.Policy {
+	rules := make([]*server.PolicyRule, 0, len(p.Rules))
+	for _, r := range p.Rules {
+		rules = append(rules, &server.PolicyRule{Path: r.Path, Capabilities: r.Capabilities, Deny: r.Deny})
+	}
+	return &server.Policy{Name: p.Name, Rules: rules, Users: p.Users, Teams: p.Teams}
+}
+
+// teamError maps the errors of team management to status codes
+func teamError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, usecase.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, usecase.ErrLastOwner):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return err
+}
+
+var teamRoles = map[server.TeamRole]string{
+	server.TeamRole_TEAM_ROLE_OWNER:     usecase.RoleOwner,
+	server.TeamRole_TEAM_ROLE_ADMIN:     usecase.RoleAdmin,
+	server.TeamRole_TEAM_ROLE_MEMBER:    usecase.RoleMember,
+	server.TeamRole_TEAM_ROLE_READ_ONLY: usecase.RoleReadOnly,
+}
+
+// teamRoleFromProto returns the usecase role, empty if unspecified
+func teamRoleFromProto(role server.TeamRole) string {
+	return teamRoles[role]
+}
+
+func teamRoleToProto(role string) server.TeamRole {
+	for r, name := range teamRoles {
+		if name == role {
+			return r
+		}
+	}
+	return server.TeamRole_TEAM_ROLE_UNSPECIFIED
+}
+
+// shareModeFromProto returns the usecase share mode, read if unspecified
+func shareModeFromProto(mode server.ShareMode) string {
+	switch mode {
+	case server.ShareMode_SHARE_MODE_UNSPECIFIED, server.ShareMode_SHARE_MODE_READ:
+		return usecase.ShareRead
+	case server.ShareMode_SHARE_MODE_READ_WRITE:
+		return usecase.ShareReadWrite
+	default:
+		return mode.String()
+	}
+}
+
+func sharesToProto(shares []storage.Share) []*server.SharedSecret {
+	secrets := make([]*server.SharedSecret, 0, len(shares))
+	for _, sh := range shares {
+		mode := server.ShareMode_SHARE_MODE_READ
+		if sh.Mode == usecase.ShareReadWrite {
+			mode = server.ShareMode_SHARE_MODE_READ_WRITE
+		}
+		secrets = append(secrets, &server.SharedSecret{
+			Owner:     sh.Owner,
+			Key:       sh.Key,
+			Recipient: sh.Recipient,
+			Mode:      mode,
+		})
+	}
+	return secrets
+}
+
+// quotaExceeded returns ResourceExhausted with the limit in a QuotaFailure
+// detail
+func quotaExceeded(quota *usecase.QuotaError) error {
+	st := status.New(codes.ResourceExhausted, quota.Error())
+
+	qf := &errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+		Subject:     quota.Subject,
+		Description: quota.Error(),
+	}}}
+	if withDetails, err := st.WithDetails(qf); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// lockedError sends the retry-after header and returns ResourceExhausted
+func lockedError(ctx context.Context, locked *usecase.LockedError) error {
+	seconds := int64((locked.RetryAfter + time.Second - 1) / time.Second)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(retryAfterKey, strconv.FormatInt(seconds, 10))); err != nil {
+		return err
+	}
+	return status.Error(codes.ResourceExhausted, locked.Error())
+}
+
+// codeRequired returns Unauthenticated with an ErrorInfo detail, so that
+// clients tell a missing two-factor code from an invalid token
+func codeRequired(err error) error {
+	st := status.New(codes.Unauthenticated, err.Error())
+
+	info := &errdetails.ErrorInfo{Reason: server.ReasonCodeRequired, Domain: server.ErrorDomain}
+	if withDetails, err := st.WithDetails(info); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// invalidArgument returns InvalidArgument with the violated fields in a
+// BadRequest detail
+func invalidArgument(invalid *validate.Error) error {
+	st := status.New(codes.InvalidArgument, invalid.Error())
+
+	br := &errdetails.BadRequest{}
+	for _, v := range invalid.Violations {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+
+	if withDetails, err := st.WithDetails(br); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
@@ -0,0 +1,60 @@
+package grpchandler
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"secret-keeper/internal/server/usecase"
+	"secret-keeper/internal/server/validate"
+	"secret-keeper/pkg/api/server"
+	"testing"
+)
+
+func Test_invalidArgument(t *testing.T) {
+	err := invalidArgument(&validate.Error{Violations: []validate.Violation{
+		{Field: "key", Description: "must not be empty"},
+		{Field: "value", Description: "must be at most 4 bytes"},
+	}})
+
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("code = %v, want %v", st.Code(), codes.InvalidArgument)
+	}
+
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("got %d details, want 1", len(details))
+	}
+	br, ok := details[0].(*errdetails.BadRequest)
+	if !ok {
+		t.Fatalf("detail = %T, want *errdetails.BadRequest", details[0])
+	}
+	if len(br.FieldViolations) != 2 || br.FieldViolations[0].Field != "key" || br.FieldViolations[1].Field != "value" {
+		t.Errorf("field violations = %v, want key and value", br.FieldViolations)
+	}
+}
+
+func Test_codeRequired(t *testing.T) {
+	st := status.Convert(codeRequired(usecase.ErrTOTPRequired))
+	if st.Code() != codes.Unauthenticated {
+		t.Fatalf("code = %v, want %v", st.Code(), codes.Unauthenticated)
+	}
+
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("got %d details, want 1", len(details))
+	}
+	info, ok := details[0].(*errdetails.ErrorInfo)
+	if !ok || info.Reason != server.ReasonCodeRequired || info.Domain != server.ErrorDomain {
+		t.Errorf("detail = %v, want reason %s", details[0], server.ReasonCodeRequired)
+	}
+}
+
+func Test_teamRole(t *testing.T) {
+	for role := range server.TeamRole_name {
+		r := server.TeamRole(role)
+		if got := teamRoleToProto(teamRoleFromProto(r)); got != r {
+			t.Errorf("teamRoleToProto(teamRoleFromProto(%v)) = %v", r, got)
+		}
+	}
+}
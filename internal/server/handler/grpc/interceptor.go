@@ -0,0 +1,126 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"secret-keeper/pkg"
+	"strings"
+	"time"
+)
+
+// requestIDKey is the metadata key of the request ID. A request ID sent by
+// the client is reused, otherwise a new one is generated. It is returned to
+// the client in the response header.
+const requestIDKey = "x-request-id"
+
+// UnaryLoggingInterceptor attaches a request logger to the context and logs
+// the result of every unary RPC.
+func (h *Handler) UnaryLoggingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, requestID := h.requestContext(ctx, info.FullMethod)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, requestID))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logResult(ctx, h.logger, time.Since(start), err)
+
+		return resp, err
+	}
+}
+
+// StreamLoggingInterceptor attaches a request logger to the context and logs
+// the result of every streaming RPC.
+func (h *Handler) StreamLoggingInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, requestID := h.requestContext(ss.Context(), info.FullMethod)
+		_ = ss.SetHeader(metadata.Pairs(requestIDKey, requestID))
+
+		start := time.Now()
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		logResult(ctx, h.logger, time.Since(start), err)
+
+		return err
+	}
+}
+
+// UnaryAuditInterceptor fails unary RPCs closed while the audit log cannot
+// take entries. The health and reflection services of gRPC are left out.
+func (h *Handler) UnaryAuditInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := h.auditReady(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuditInterceptor fails streaming RPCs closed while the audit log
+// cannot take entries
+func (h *Handler) StreamAuditInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := h.auditReady(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (h *Handler) auditReady(ctx context.Context, method string) error {
+	if strings.HasPrefix(method, "/grpc.") {
+		return nil
+	}
+	if err := h.logic.AuditReady(); err != nil {
+		pkg.LoggerFromContext(ctx, h.logger).Error("audit log unavailable", pkg.Err(err))
+		return status.Error(codes.Unavailable, "audit log unavailable")
+	}
+	return nil
+}
+
+func (h *Handler) requestContext(ctx context.Context, method string) (context.Context, string) {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDKey); len(values) != 0 {
+			requestID = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+
+	logger := h.logger
+	if logger == nil {
+		logger = pkg.NewNop()
+	}
+	logger = logger.With(pkg.String("method", method), pkg.String("request_id", requestID))
+
+	return pkg.ContextWithLogger(ctx, logger), requestID
+}
+
+func logResult(ctx context.Context, fallback pkg.Logger, duration time.Duration, err error) {
+	logger := pkg.LoggerFromContext(ctx, fallback)
+	code := status.Code(err)
+	fields := []pkg.Field{pkg.String("code", code.String()), pkg.Duration("duration", duration)}
+
+	switch code {
+	case codes.OK:
+		logger.Info("request handled", fields...)
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss:
+		logger.Error("request failed", append(fields, pkg.Err(err))...)
+	default:
+		logger.Info("request rejected", append(fields, pkg.Err(err))...)
+	}
+}
+
+// contextStream overrides the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,115 @@
+package grpchandler
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"secret-keeper/internal/server/usecase"
+	"secret-keeper/pkg"
+	"testing"
+)
+
+func TestHandler_UnaryLoggingInterceptor(t *testing.T) {
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		err           error
+		wantMessage   string
+		wantRequestID string
+	}{
+		{
+			name:        "ok",
+			ctx:         context.Background(),
+			wantMessage: "request handled",
+		},
+		{
+			name:          "requestIDFromClient",
+			ctx:           metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDKey, "req-1")),
+			wantMessage:   "request handled",
+			wantRequestID: "req-1",
+		},
+		{
+			name:        "rejected",
+			ctx:         context.Background(),
+			err:         status.Error(codes.NotFound, "not found"),
+			wantMessage: "request rejected",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.DebugLevel)
+			h := New(nil, pkg.New(zap.New(core)))
+
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				pkg.AddLoggerFields(ctx, pkg.String("user", "alice"))
+				return nil, tt.err
+			}
+
+			info := &grpc.UnaryServerInfo{FullMethod: "/api.SecretKeeper/Get"}
+			if _, err := h.UnaryLoggingInterceptor()(tt.ctx, nil, info, handler); err != tt.err {
+				t.Fatalf("interceptor error = %v, want %v", err, tt.err)
+			}
+
+			entries := logs.All()
+			if len(entries) != 1 {
+				t.Fatalf("got %d log entries, want 1", len(entries))
+			}
+
+			entry := entries[0]
+			if entry.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", entry.Message, tt.wantMessage)
+			}
+
+			fields := entry.ContextMap()
+			if fields["method"] != info.FullMethod {
+				t.Errorf("method = %v, want %v", fields["method"], info.FullMethod)
+			}
+			if fields["user"] != "alice" {
+				t.Errorf("user = %v, want alice", fields["user"])
+			}
+			if fields["code"] != status.Code(tt.err).String() {
+				t.Errorf("code = %v, want %v", fields["code"], status.Code(tt.err))
+			}
+
+			requestID, _ := fields["request_id"].(string)
+			if requestID == "" || (tt.wantRequestID != "" && requestID != tt.wantRequestID) {
+				t.Errorf("request_id = %q, want %q", requestID, tt.wantRequestID)
+			}
+		})
+	}
+}
+
+// auditUseCase reports err from AuditReady
+type auditUseCase struct {
+	usecase.IUseCase
+	err error
+}
+
+func (u *auditUseCase) AuditReady() error {
+	return u.err
+}
+
+func TestHandler_UnaryAuditInterceptor(t *testing.T) {
+	h := New(&auditUseCase{err: errors.New("disk full")}, pkg.NewNop())
+
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.SecretKeeper/Get"}
+	if _, err := h.UnaryAuditInterceptor()(context.Background(), nil, info, handler); status.Code(err) != codes.Unavailable || called {
+		t.Errorf("interceptor error = %v, called = %v, want %v before the handler", err, called, codes.Unavailable)
+	}
+
+	info = &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	if _, err := h.UnaryAuditInterceptor()(context.Background(), nil, info, handler); err != nil || !called {
+		t.Errorf("interceptor error = %v, called = %v, want health checks to pass", err, called)
+	}
+}
@@ -0,0 +1,669 @@
+// Package vaulthandler serves the personal vault of a user as the KV version
+// 2 secrets engine of HashiCorp Vault, so that tools reading Vault can read
+// secret-keeper unchanged.
+//
+// A secret is a single version of a Vault secret: writing it replaces it,
+// and deleting any version deletes it for good. Its data is the JSON object
+// stored as its value, or {"value": value} for values that are not JSON
+// objects, and writing {"value": value} stores the value as it is.
+package vaulthandler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"net/http"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/usecase"
+	"secret-keeper/internal/server/validate"
+	"secret-keeper/pkg"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBodySize limits the size of a request body
+const maxBodySize = 1 << 20
+
+// version is the only version of a secret
+const version = 1
+
+// createdTime is reported as the creation time of every secret, which is
+// not tracked
+var createdTime = time.Unix(0, 0).UTC().Format(time.RFC3339Nano)
+
+// errCASMismatch is returned when the cas option of a write does not match
+// the version of the secret
+var errCASMismatch = errors.New("check-and-set parameter did not match the current version")
+
+// service is the gRPC service the requests are reported as by interceptors
+const service = "vault"
+
+type Handler struct {
+	logic       usecase.IUseCase
+	logger      pkg.Logger
+	mount       string
+	interceptor grpc.UnaryServerInterceptor
+}
+
+// serveFunc serves a request of an operation
+type serveFunc func(ctx context.Context, w http.ResponseWriter, r *http.Request)
+
+// New returns a handler serving the secrets of users under mount, such as
+// secret for paths like /v1/secret/data/db. Every request goes through
+// interceptor, if any, as a call of /vault/<operation> such as /vault/Read,
+// so that it is logged and measured like a gRPC call.
+func New(logic usecase.IUseCase, logger pkg.Logger, mount string, interceptor grpc.UnaryServerInterceptor) *Handler {
+	return &Handler{logic: logic, logger: logger, mount: strings.Trim(mount, "/"), interceptor: interceptor}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	if path == r.URL.Path {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	method := r.Method
+	if method == http.MethodGet && isList(r) {
+		method = "LIST"
+	}
+
+	op, serve := h.route(method, path)
+	if serve == nil {
+		writeError(w, http.StatusNotFound, "no handler for route "+strconv.Quote(path))
+		return
+	}
+
+	info := &grpc.UnaryServerInfo{Server: h, FullMethod: "/" + service + "/" + op}
+	sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		serve(ctx, sw, r)
+		return nil, sw.err()
+	}
+
+	ctx := h.context(r, info.FullMethod, w.Header())
+	if h.interceptor == nil {
+		_, _ = handler(ctx, r)
+		return
+	}
+	// an interceptor may fail the request before it is served
+	if _, err := h.interceptor(ctx, r, info, handler); err != nil && !sw.written {
+		code := http.StatusInternalServerError
+		if status.Code(err) == codes.Unavailable {
+			code = http.StatusServiceUnavailable
+		}
+		writeError(w, code, status.Convert(err).Message())
+	}
+}
+
+// route returns the operation serving method on path, nil if there is none
+func (h *Handler) route(method, path string) (string, serveFunc) {
+	switch {
+	case path == "sys/health":
+		return "Health", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"initialized": true, "sealed": false, "standby": false})
+		}
+	case strings.HasPrefix(path, "sys/internal/ui/mounts/"):
+		return "MountInfo", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			h.mountInfo(w, strings.TrimPrefix(path, "sys/internal/ui/mounts/"))
+		}
+	case strings.HasPrefix(path, "auth/userpass/login/") && (method == http.MethodPost || method == http.MethodPut):
+		return "Login", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			h.login(ctx, w, r, strings.TrimPrefix(path, "auth/userpass/login/"))
+		}
+	case path == "auth/token/lookup-self" && method == http.MethodGet:
+		return "LookupSelf", h.lookupSelf
+	case strings.HasPrefix(path, h.mount+"/"):
+		op, serve := h.kv(method, strings.TrimPrefix(path, h.mount+"/"))
+		return op, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			if err := h.authenticate(ctx, r); err != nil {
+				h.writeUseCaseError(ctx, w, err)
+				return
+			}
+			serve(ctx, w, r)
+		}
+	}
+	return "", nil
+}
+
+// kv returns the operation serving method on a path of the engine below its
+// mount
+func (h *Handler) kv(method, path string) (string, serveFunc) {
+	endpoint, key, _ := strings.Cut(path, "/")
+	switch {
+	case endpoint == "data" && method == http.MethodGet:
+		return "Read", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			h.read(ctx, w, r, key)
+		}
+	case endpoint == "data" && (method == http.MethodPost || method == http.MethodPut):
+		return "Write", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			h.write(ctx, w, r, key)
+		}
+	case endpoint == "data" && method == http.MethodPatch:
+		return "Patch", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			h.patch(ctx, w, r, key)
+		}
+	case endpoint == "data" && method == http.MethodDelete,
+		endpoint == "metadata" && method == http.MethodDelete,
+		// deleting or destroying the only version deletes the secret
+		endpoint == "delete" && (method == http.MethodPost || method == http.MethodPut),
+		endpoint == "destroy" && (method == http.MethodPost || method == http.MethodPut):
+		return "Delete", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			h.delete(ctx, w, key)
+		}
+	case endpoint == "metadata" && method == "LIST":
+		return "List", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			h.list(ctx, w, key)
+		}
+	case endpoint == "metadata" && method == http.MethodGet:
+		return "Metadata", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			h.metadata(ctx, w, key)
+		}
+	case endpoint == "undelete":
+		return "Undelete", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			writeError(w, http.StatusBadRequest, "deleted secrets cannot be restored")
+		}
+	}
+	return "Unsupported", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, "unsupported operation")
+	}
+}
+
+func (h *Handler) read(ctx context.Context, w http.ResponseWriter, r *http.Request, key string) {
+	if v := r.URL.Query().Get("version"); v != "" && v != "0" && v != strconv.Itoa(version) {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	value, err := h.logic.Get(ctx, key)
+	if err != nil {
+		h.writeUseCaseError(ctx, w, err)
+		return
+	}
+
+	writeResponse(w, map[string]interface{}{
+		"data":     decodeData(value),
+		"metadata": versionMetadata(),
+	})
+}
+
+func (h *Handler) write(ctx context.Context, w http.ResponseWriter, r *http.Request, key string) {
+	var req struct {
+		Options struct {
+			CAS *int `json:"cas"`
+		} `json:"options"`
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := decodeBody(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Data == nil {
+		writeError(w, http.StatusBadRequest, "no data provided")
+		return
+	}
+
+	if req.Options.CAS != nil {
+		if err := h.checkCAS(ctx, key, *req.Options.CAS); err != nil {
+			h.writeUseCaseError(ctx, w, err)
+			return
+		}
+	}
+
+	h.set(ctx, w, key, req.Data)
+}
+
+// patch merges the data of the body into the secret, null values delete
+// their keys
+func (h *Handler) patch(ctx context.Context, w http.ResponseWriter, r *http.Request, key string) {
+	var req struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := decodeBody(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	value, err := h.logic.Get(ctx, key)
+	if err != nil {
+		h.writeUseCaseError(ctx, w, err)
+		return
+	}
+
+	data := decodeData(value)
+	for k, v := range req.Data {
+		if v == nil {
+			delete(data, k)
+		} else {
+			data[k] = v
+		}
+	}
+	h.set(ctx, w, key, data)
+}
+
+func (h *Handler) set(ctx context.Context, w http.ResponseWriter, key string, data map[string]interface{}) {
+	value, err := encodeData(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = h.logic.Set(ctx, key, value, time.Time{}); err != nil {
+		h.writeUseCaseError(ctx, w, err)
+		return
+	}
+	writeResponse(w, versionMetadata())
+}
+
+// checkCAS checks cas against the version of key, zero meaning that it
+// must not exist. The check and the write that follows are not atomic.
+func (h *Handler) checkCAS(ctx context.Context, key string, cas int) error {
+	_, err := h.logic.Get(ctx, key)
+	exists := err == nil
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	if (cas == 0 && exists) || (cas == version && !exists) || (cas != 0 && cas != version) {
+		return errCASMismatch
+	}
+	return nil
+}
+
+func (h *Handler) delete(ctx context.Context, w http.ResponseWriter, key string) {
+	if err := h.logic.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		h.writeUseCaseError(ctx, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// list returns the keys directly below path, with a trailing slash for the
+// ones having keys below them
+func (h *Handler) list(ctx context.Context, w http.ResponseWriter, path string) {
+	prefix := path
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	opts := usecase.ListOptions{Prefix: prefix, PageSize: usecase.MaxPageSize}
+	for {
+		page, err := h.logic.ListSecrets(ctx, opts)
+		if err != nil {
+			h.writeUseCaseError(ctx, w, err)
+			return
+		}
+
+		for _, secret := range page.Secrets {
+			name := strings.TrimPrefix(secret.Name, prefix)
+			if dir, _, ok := strings.Cut(name, "/"); ok {
+				name = dir + "/"
+			}
+			if name != "" && !seen[name] {
+				seen[name] = true
+				keys = append(keys, name)
+			}
+		}
+
+		if page.NextPageToken == "" {
+			break
+		}
+		opts.PageToken = page.NextPageToken
+	}
+
+	if len(keys) == 0 {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+	sort.Strings(keys)
+	writeResponse(w, map[string]interface{}{"keys": keys})
+}
+
+func (h *Handler) metadata(ctx context.Context, w http.ResponseWriter, key string) {
+	page, err := h.logic.ListSecrets(ctx, usecase.ListOptions{Prefix: key, PageSize: usecase.MaxPageSize})
+	if err != nil {
+		h.writeUseCaseError(ctx, w, err)
+		return
+	}
+
+	var secret *usecase.SecretInfo
+	for i := range page.Secrets {
+		if page.Secrets[i].Name == key {
+			secret = &page.Secrets[i]
+		}
+	}
+	if secret == nil {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	custom := map[string]string{}
+	if !secret.ExpiresAt.IsZero() {
+		custom["expires_at"] = secret.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if len(secret.Tags) != 0 {
+		custom["tags"] = strings.Join(secret.Tags, ",")
+	}
+
+	writeResponse(w, map[string]interface{}{
+		"cas_required":         false,
+		"created_time":         createdTime,
+		"current_version":      version,
+		"custom_metadata":      custom,
+		"delete_version_after": "0s",
+		"max_versions":         version,
+		"oldest_version":       version,
+		"updated_time":         createdTime,
+		"versions": map[string]interface{}{
+			strconv.Itoa(version): map[string]interface{}{
+				"created_time":  createdTime,
+				"deletion_time": "",
+				"destroyed":     false,
+			},
+		},
+	})
+}
+
+// mountInfo tells the Vault CLI that path is in a KV version 2 engine
+func (h *Handler) mountInfo(w http.ResponseWriter, path string) {
+	if path != h.mount && !strings.HasPrefix(path, h.mount+"/") {
+		writeError(w, http.StatusBadRequest, "no mount found for path "+strconv.Quote(path))
+		return
+	}
+
+	writeResponse(w, map[string]interface{}{
+		"path":    h.mount + "/",
+		"type":    "kv",
+		"options": map[string]string{"version": "2"},
+	})
+}
+
+// login issues a token for a user like the userpass auth method
+func (h *Handler) login(ctx context.Context, w http.ResponseWriter, r *http.Request, username string) {
+	var req struct {
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+	}
+	if err := decodeBody(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	token, err := h.logic.Auth(ctx, username, req.Password, req.TOTP)
+	if err != nil {
+		var locked *usecase.LockedError
+		switch {
+		case errors.As(err, &locked):
+			w.Header().Set("Retry-After", strconv.Itoa(int(locked.RetryAfter.Round(time.Second).Seconds())))
+			writeError(w, http.StatusTooManyRequests, err.Error())
+		case errors.Is(err, storage.ErrNotFound) || errors.Is(err, usecase.ErrInvalidPassword) ||
+			errors.Is(err, usecase.ErrInvalidCode) || errors.Is(err, usecase.ErrTOTPRequired):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			h.writeUseCaseError(ctx, w, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope(nil, map[string]interface{}{
+		"client_token":   token,
+		"accessor":       "",
+		"policies":       []string{"default"},
+		"token_policies": []string{"default"},
+		"metadata":       map[string]string{"username": username},
+		"lease_duration": 0,
+		"renewable":      false,
+	}))
+}
+
+// lookupSelf describes the token of the request
+func (h *Handler) lookupSelf(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if err := h.authenticate(ctx, r); err != nil {
+		h.writeUseCaseError(ctx, w, err)
+		return
+	}
+
+	writeResponse(w, map[string]interface{}{
+		"id":        token(r),
+		"policies":  []string{"default"},
+		"ttl":       0,
+		"renewable": false,
+		"type":      "service",
+	})
+}
+
+// authenticate checks the token of r
+func (h *Handler) authenticate(ctx context.Context, r *http.Request) error {
+	if token(r) == "" {
+		return usecase.ErrInvalidToken
+	}
+
+	_, err := h.logic.CurrentUser(ctx)
+	return err
+}
+
+// context returns the context of r for the usecase, with the token as it
+// comes from gRPC clients. The request ID the interceptors send as a header
+// is set in header.
+func (h *Handler) context(r *http.Request, method string, header http.Header) context.Context {
+	md := metadata.MD{}
+	if t := token(r); t != "" {
+		md.Set("token", t)
+	}
+	if v := r.Header.Get("X-Request-Id"); v != "" {
+		md.Set("x-request-id", v)
+	}
+
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	ctx = grpc.NewContextWithServerTransportStream(ctx, &headerStream{method: method, header: header})
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+	return ctx
+}
+
+func (h *Handler) writeUseCaseError(ctx context.Context, w http.ResponseWriter, err error) {
+	var invalid *validate.Error
+	var quota *usecase.QuotaError
+	switch {
+	case errors.As(err, &invalid):
+		messages := make([]string, len(invalid.Violations))
+		for i, v := range invalid.Violations {
+			messages[i] = v.Field + ": " + v.Description
+		}
+		writeError(w, http.StatusBadRequest, messages...)
+	case errors.As(err, &quota):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, errCASMismatch):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		writeError(w, http.StatusNotFound)
+	case errors.Is(err, usecase.ErrInvalidToken), errors.Is(err, usecase.ErrPermissionDenied):
+		writeError(w, http.StatusForbidden, "permission denied")
+	case errors.Is(err, storage.ErrUnavailable):
+		writeError(w, http.StatusServiceUnavailable, "storage unavailable")
+	default:
+		pkg.LoggerFromContext(ctx, h.logger).Warn("vault request failed", pkg.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+// token returns the Vault token of r
+func token(r *http.Request) string {
+	if t := r.Header.Get("X-Vault-Token"); t != "" {
+		return t
+	}
+	auth := r.Header.Get("Authorization")
+	if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(auth[len("Bearer "):])
+	}
+	return ""
+}
+
+func isList(r *http.Request) bool {
+	list, _ := strconv.ParseBool(r.URL.Query().Get("list"))
+	return list
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse JSON input: %w", err)
+	}
+	return nil
+}
+
+// decodeData returns the data of a secret with value
+func decodeData(value string) map[string]interface{} {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &data); err != nil || data == nil {
+		return map[string]interface{}{"value": value}
+	}
+	return data
+}
+
+// encodeData returns the value of a secret with data
+func encodeData(data map[string]interface{}) (string, error) {
+	if v, ok := data["value"].(string); ok && len(data) == 1 {
+		// unless it would be read back as another object
+		if d := decodeData(v); len(d) == 1 && d["value"] == v {
+			return v, nil
+		}
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode data: %w", err)
+	}
+	return string(b), nil
+}
+
+func versionMetadata() map[string]interface{} {
+	return map[string]interface{}{
+		"created_time":    createdTime,
+		"custom_metadata": nil,
+		"deletion_time":   "",
+		"destroyed":       false,
+		"version":         version,
+	}
+}
+
+// envelope wraps data and auth in the fields of every Vault response
+func envelope(data, auth interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"request_id":     uuid.NewString(),
+		"lease_id":       "",
+		"renewable":      false,
+		"lease_duration": 0,
+		"data":           data,
+		"wrap_info":      nil,
+		"warnings":       nil,
+		"auth":           auth,
+	}
+}
+
+func writeResponse(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, envelope(data, nil))
+}
+
+func writeError(w http.ResponseWriter, code int, messages ...string) {
+	if messages == nil {
+		messages = []string{}
+	}
+	writeJSON(w, code, map[string]interface{}{"errors": messages})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// headerStream takes the headers the usecase and the interceptors send.
+// Only the request ID reaches the response: Auth sends the token it issues
+// as a header, which Vault clients expect in the body.
+type headerStream struct {
+	method string
+	header http.Header
+}
+
+func (s *headerStream) Method() string {
+	return s.method
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	if s.header == nil {
+		return nil
+	}
+	for _, v := range md.Get("x-request-id") {
+		s.header.Set("X-Request-Id", v)
+	}
+	return nil
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error {
+	return nil
+}
+
+func (s *headerStream) SetTrailer(md metadata.MD) error {
+	return nil
+}
+
+// statusWriter records the status of a response, so that interceptors see
+// failed requests as failed calls
+type statusWriter struct {
+	http.ResponseWriter
+	code    int
+	written bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code, w.written = code, true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// err returns the error of the status with the gRPC code closest to it, nil
+// for a success
+func (w *statusWriter) err() error {
+	if w.code < http.StatusBadRequest {
+		return nil
+	}
+
+	code := codes.Unknown
+	switch w.code {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusMethodNotAllowed:
+		code = codes.Unimplemented
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusInternalServerError:
+		code = codes.Internal
+	}
+	return status.Error(code, http.StatusText(w.code))
+}
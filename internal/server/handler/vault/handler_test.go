@@ -0,0 +1,186 @@
+package vaulthandler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/usecase"
+	"secret-keeper/pkg"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logic, err := usecase.New(store, pkg.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(logic, pkg.NewNop(), "secret", nil)
+
+	username := "vault-" + uuid.NewString()
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), &headerStream{})
+	if _, err = logic.Register(ctx, username, "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, path, token, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("X-Vault-Token", token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		var resp map[string]interface{}
+		if rec.Body.Len() != 0 {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+		}
+		return rec.Code, resp
+	}
+
+	code, resp := do(http.MethodPost, "/v1/auth/userpass/login/"+username, "", `{"password":"password"}`)
+	if code != http.StatusOK {
+		t.Fatalf("login status = %d, want %d: %v", code, http.StatusOK, resp)
+	}
+	token, _ := resp["auth"].(map[string]interface{})["client_token"].(string)
+
+	if code, _ = do(http.MethodGet, "/v1/secret/metadata/?list=true", token, ""); code != http.StatusNotFound {
+		t.Errorf("list status = %d, want %d without secrets", code, http.StatusNotFound)
+	}
+
+	code, resp = do(http.MethodPost, "/v1/secret/data/app/db", token, `{"data":{"user":"admin","password":"hunter2"}}`)
+	if code != http.StatusOK || resp["data"].(map[string]interface{})["version"] != float64(1) {
+		t.Fatalf("write = %d, %v, want version 1", code, resp)
+	}
+	if code, _ = do(http.MethodPost, "/v1/secret/data/app/db", token, `{"options":{"cas":0},"data":{"user":"other"}}`); code != http.StatusBadRequest {
+		t.Errorf("write with cas 0 status = %d, want %d for an existing secret", code, http.StatusBadRequest)
+	}
+	if code, _ = do(http.MethodPut, "/v1/secret/data/app/api", token, `{"data":{"value":"token"}}`); code != http.StatusOK {
+		t.Errorf("write status = %d, want %d", code, http.StatusOK)
+	}
+
+	code, resp = do(http.MethodGet, "/v1/secret/data/app/db", token, "")
+	want := map[string]interface{}{"user": "admin", "password": "hunter2"}
+	if code != http.StatusOK || !reflect.DeepEqual(resp["data"].(map[string]interface{})["data"], want) {
+		t.Errorf("read = %d, %v, want %v", code, resp, want)
+	}
+	if got, err := logic.Get(setToken(token), "app/api"); err != nil || got != "token" {
+		t.Errorf("Get() = %q, %v, want the value written as it is", got, err)
+	}
+
+	if code, _ = do(http.MethodPatch, "/v1/secret/data/app/db", token, `{"data":{"password":null,"port":"5432"}}`); code != http.StatusOK {
+		t.Errorf("patch status = %d, want %d", code, http.StatusOK)
+	}
+	code, resp = do(http.MethodGet, "/v1/secret/data/app/db", token, "")
+	want = map[string]interface{}{"user": "admin", "port": "5432"}
+	if code != http.StatusOK || !reflect.DeepEqual(resp["data"].(map[string]interface{})["data"], want) {
+		t.Errorf("read after patch = %d, %v, want %v", code, resp, want)
+	}
+
+	code, resp = do("LIST", "/v1/secret/metadata/", token, "")
+	if keys := resp["data"].(map[string]interface{})["keys"]; code != http.StatusOK || !reflect.DeepEqual(keys, []interface{}{"app/"}) {
+		t.Errorf("list = %d, %v, want app/", code, resp)
+	}
+	code, resp = do(http.MethodGet, "/v1/secret/metadata/app?list=true", token, "")
+	if keys := resp["data"].(map[string]interface{})["keys"]; code != http.StatusOK || !reflect.DeepEqual(keys, []interface{}{"api", "db"}) {
+		t.Errorf("list app = %d, %v, want api and db", code, resp)
+	}
+
+	if code, _ = do(http.MethodDelete, "/v1/secret/data/app/db", "not-a-token", ""); code != http.StatusForbidden {
+		t.Errorf("delete status = %d, want %d with an unknown token", code, http.StatusForbidden)
+	}
+	if code, _ = do(http.MethodDelete, "/v1/secret/data/app/db", token, ""); code != http.StatusNoContent {
+		t.Errorf("delete status = %d, want %d", code, http.StatusNoContent)
+	}
+	if code, _ = do(http.MethodGet, "/v1/secret/data/app/db", token, ""); code != http.StatusNotFound {
+		t.Errorf("read status = %d, want %d once deleted", code, http.StatusNotFound)
+	}
+
+	if code, _ = do(http.MethodGet, "/v1/secret/data/app/api", "", ""); code != http.StatusForbidden {
+		t.Errorf("read status = %d, want %d without a token", code, http.StatusForbidden)
+	}
+	if code, _ = do(http.MethodGet, "/v1/sys/internal/ui/mounts/secret/app/api", token, ""); code != http.StatusOK {
+		t.Errorf("mount status = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestHandler_interceptor(t *testing.T) {
+	var calls []string
+	interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", "request", "token", "issued"))
+		resp, err := handler(ctx, req)
+		calls = append(calls, info.FullMethod+" "+status.Code(err).String())
+		return resp, err
+	}
+	h := New(nil, pkg.NewNop(), "secret", interceptor)
+
+	for _, path := range []string{"/v1/sys/health", "/v1/secret/data/app/db"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := rec.Header().Get("X-Request-Id"); got != "request" {
+			t.Errorf("%s: X-Request-Id = %q, want the one of the interceptor", path, got)
+		}
+		if got := rec.Header().Get("Token"); got != "" {
+			t.Errorf("%s: Token = %q, want no other header", path, got)
+		}
+	}
+
+	want := []string{"/vault/Health OK", "/vault/Read PermissionDenied"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	// a request the interceptor fails is not served
+	h = New(nil, pkg.NewNop(), "secret", func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return nil, status.Error(codes.Unavailable, "audit log unavailable")
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/sys/health", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func Test_encodeData(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		want string
+	}{
+		{name: "value", data: map[string]interface{}{"value": "hunter2"}, want: "hunter2"},
+		{name: "objectValue", data: map[string]interface{}{"value": `{"a":"b"}`}, want: `{"value":"{\"a\":\"b\"}"}`},
+		{name: "object", data: map[string]interface{}{"user": "admin", "port": float64(5432)}, want: `{"port":5432,"user":"admin"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeData(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("encodeData() = %s, want %s", got, tt.want)
+			}
+			if back := decodeData(got); !reflect.DeepEqual(back, tt.data) {
+				t.Errorf("decodeData() = %v, want %v", back, tt.data)
+			}
+		})
+	}
+}
+
+func setToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("token", token))
+}
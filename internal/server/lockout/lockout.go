@@ -0,0 +1,164 @@
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// Config of the Limiter
+type Config struct {
+	// MaxAttempts is the number of consecutive failures after which a key
+	// is locked out.
+	MaxAttempts int
+	// Backoff is the delay after the first failure. It doubles after every
+	// following failure.
+	Backoff time.Duration
+	// Lockout is how long a key stays locked after MaxAttempts failures.
+	// Failures are forgotten after Lockout without new ones.
+	Lockout time.Duration
+}
+
+// Limiter counts failed attempts per key and delays the following attempts
+// exponentially. A nil Limiter allows every attempt.
+type Limiter struct {
+	mu        sync.Mutex
+	cfg       Config
+	attempts  map[string]*attempts
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+type attempts struct {
+	failures    int
+	lastFailure time.Time
+	// until is the time before which no attempt is allowed
+	until time.Time
+}
+
+// New creates a Limiter
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:      cfg,
+		attempts: make(map[string]*attempts),
+		now:      time.Now,
+	}
+}
+
+// Wait returns how long the caller has to wait before the next attempt for
+// keys. Zero means that the attempt is allowed.
+func (l *Limiter) Wait(keys ...string) time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	var wait time.Duration
+	for _, key := range keys {
+		if a := l.get(key, now); a != nil && a.until.After(now) {
+			if d := a.until.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// Fail records a failed attempt for every key and returns how long the
+// caller has to wait before the next one.
+func (l *Limiter) Fail(keys ...string) time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	var wait time.Duration
+	for _, key := range keys {
+		a := l.get(key, now)
+		if a == nil {
+			a = &attempts{}
+			l.attempts[key] = a
+		}
+
+		a.failures++
+		a.lastFailure = now
+		a.until = now.Add(l.delay(a.failures))
+
+		if d := a.until.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// Reset forgets the failed attempts of key and reports whether there were
+// any.
+func (l *Limiter) Reset(key string) bool {
+	if l == nil {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.attempts[key]
+	delete(l.attempts, key)
+	return ok
+}
+
+// delay returns the delay after the n-th consecutive failure
+func (l *Limiter) delay(n int) time.Duration {
+	if n >= l.cfg.MaxAttempts {
+		return l.cfg.Lockout
+	}
+
+	d := l.cfg.Backoff
+	for i := 1; i < n && d < l.cfg.Lockout; i++ {
+		d *= 2
+	}
+	if d > l.cfg.Lockout {
+		d = l.cfg.Lockout
+	}
+	return d
+}
+
+// get returns the attempts of key, dropping them if they are expired
+func (l *Limiter) get(key string, now time.Time) *attempts {
+	a, ok := l.attempts[key]
+	if !ok {
+		return nil
+	}
+	if l.expired(a, now) {
+		delete(l.attempts, key)
+		return nil
+	}
+	return a
+}
+
+func (l *Limiter) expired(a *attempts, now time.Time) bool {
+	return !a.until.After(now) && now.Sub(a.lastFailure) >= l.cfg.Lockout
+}
+
+// sweep drops expired attempts at most once per Lockout, so that keys
+// which are never tried again do not pile up.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.cfg.Lockout {
+		return
+	}
+	l.lastSweep = now
+
+	for key, a := range l.attempts {
+		if l.expired(a, now) {
+			delete(l.attempts, key)
+		}
+	}
+}
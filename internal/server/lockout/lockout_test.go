@@ -0,0 +1,92 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(Config{MaxAttempts: 4, Backoff: time.Second, Lockout: time.Minute})
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiter_Fail(t *testing.T) {
+	l, _ := newTestLimiter()
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, time.Minute, time.Minute}
+	for i, w := range want {
+		if got := l.Fail("user:alice"); got != w {
+			t.Errorf("Fail() #%d = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	l, now := newTestLimiter()
+
+	if got := l.Wait("user:alice", "peer:10.0.0.1"); got != 0 {
+		t.Fatalf("Wait() = %v, want 0 before any failure", got)
+	}
+
+	l.Fail("user:alice", "peer:10.0.0.1")
+	l.Fail("peer:10.0.0.1")
+
+	if got := l.Wait("user:alice"); got != time.Second {
+		t.Errorf("Wait(user) = %v, want %v", got, time.Second)
+	}
+	if got := l.Wait("user:alice", "peer:10.0.0.1"); got != 2*time.Second {
+		t.Errorf("Wait(user, peer) = %v, want the longest wait %v", got, 2*time.Second)
+	}
+	if got := l.Wait("user:bob"); got != 0 {
+		t.Errorf("Wait(other user) = %v, want 0", got)
+	}
+
+	*now = now.Add(2 * time.Second)
+	if got := l.Wait("user:alice", "peer:10.0.0.1"); got != 0 {
+		t.Errorf("Wait() = %v, want 0 after the backoff", got)
+	}
+
+	// failures are counted until they expire
+	if got := l.Fail("peer:10.0.0.1"); got != 4*time.Second {
+		t.Errorf("Fail() = %v, want %v", got, 4*time.Second)
+	}
+
+	*now = now.Add(time.Minute)
+	if got := l.Fail("peer:10.0.0.1"); got != time.Second {
+		t.Errorf("Fail() = %v, want %v after the failures expired", got, time.Second)
+	}
+}
+
+func TestLimiter_Reset(t *testing.T) {
+	l, _ := newTestLimiter()
+
+	for i := 0; i < 4; i++ {
+		l.Fail("user:alice")
+	}
+	if got := l.Wait("user:alice"); got != time.Minute {
+		t.Fatalf("Wait() = %v, want locked for %v", got, time.Minute)
+	}
+
+	if !l.Reset("user:alice") {
+		t.Error("Reset() = false, want true")
+	}
+	if got := l.Wait("user:alice"); got != 0 {
+		t.Errorf("Wait() = %v, want 0 after Reset", got)
+	}
+	if l.Reset("user:alice") {
+		t.Error("Reset() = true, want false for an unknown key")
+	}
+}
+
+func TestLimiter_nil(t *testing.T) {
+	var l *Limiter
+
+	if got := l.Fail("user:alice"); got != 0 {
+		t.Errorf("Fail() = %v, want 0", got)
+	}
+	if got := l.Wait("user:alice"); got != 0 {
+		t.Errorf("Wait() = %v, want 0", got)
+	}
+}
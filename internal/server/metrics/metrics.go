@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"secret-keeper/internal/server/storage"
+	"strings"
+	"time"
+)
+
+const namespace = "secret_keeper"
+
+// authMethod is the full name of the RPC whose failures are counted as
+// authentication failures.
+const authMethod = "/api.SecretKeeper/Auth"
+
+// Metrics collects server metrics and exposes them in the Prometheus text
+// format.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	authFailures    *prometheus.CounterVec
+	storageDuration *prometheus.HistogramVec
+	storageErrors   *prometheus.CounterVec
+}
+
+// New creates metrics registered in their own registry
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "grpc_requests_total",
+			Help:      "Number of handled gRPC requests by method and status code.",
+		}, []string{"service", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "grpc_request_duration_seconds",
+			Help:      "Latency of gRPC requests by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "method"}),
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_failures_total",
+			Help:      "Number of failed Auth requests by status code.",
+		}, []string{"code"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Latency of storage operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "storage_errors_total",
+			Help:      "Number of failed storage operations by error class.",
+		}, []string{"operation", "error"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.authFailures,
+		m.storageDuration,
+		m.storageErrors,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterIssuedTokens exposes the number of issued session tokens returned
+// by count.
+//
+// There is no metric of active sessions: tokens neither expire nor record
+// when they were last used, so every session not revoked is counted rather
+// than the ones in use. A failed count leaves the metric out of the scrape
+// instead of exposing a made-up value; count is expected to report its
+// error itself, as Storage.CountTokens does in storage_errors_total.
+func (m *Metrics) RegisterIssuedTokens(count func(ctx context.Context) (uint64, error)) {
+	m.registry.MustRegister(&countCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "issued_tokens"),
+			"Number of issued session tokens not revoked.",
+			nil, nil,
+		),
+		count: count,
+	})
+}
+
+// countCollector exposes the result of count as a gauge, leaving it out when
+// count fails
+type countCollector struct {
+	desc  *prometheus.Desc
+	count func(ctx context.Context) (uint64, error)
+}
+
+func (c *countCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *countCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	n, err := c.count(ctx)
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n))
+}
+
+// UnaryServerInterceptor records metrics of unary RPCs
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observeRequest(info.FullMethod, time.Since(start), err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records metrics of streaming RPCs
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.observeRequest(info.FullMethod, time.Since(start), err)
+		return err
+	}
+}
+
+func (m *Metrics) observeRequest(fullMethod string, duration time.Duration, err error) {
+	service, method := splitMethod(fullMethod)
+	code := status.Code(err).String()
+
+	m.requests.WithLabelValues(service, method, code).Inc()
+	m.requestDuration.WithLabelValues(service, method).Observe(duration.Seconds())
+
+	if fullMethod == authMethod && err != nil {
+		m.authFailures.WithLabelValues(code).Inc()
+	}
+}
+
+// ObserveStorage implements storage.Observer
+func (m *Metrics) ObserveStorage(op string, duration time.Duration, err error) {
+	m.storageDuration.WithLabelValues(op).Observe(duration.Seconds())
+	if err != nil {
+		m.storageErrors.WithLabelValues(op, errorClass(err)).Inc()
+	}
+}
+
+// errorClass maps a storage error to a label value
+func errorClass(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return "already_exists"
+	case errors.Is(err, storage.ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, storage.ErrUnknown):
+		return "unknown"
+	default:
+		return "other"
+	}
+}
+
+// splitMethod splits "/package.Service/Method" into service and method
+func splitMethod(fullMethod string) (service, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http/httptest"
+	"secret-keeper/internal/server/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, err := io.ReadAll(rec.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestMetrics_UnaryServerInterceptor(t *testing.T) {
+	m := New()
+	interceptor := m.UnaryServerInterceptor()
+
+	calls := []struct {
+		method string
+		err    error
+	}{
+		{method: "/api.SecretKeeper/Get"},
+		{method: "/api.SecretKeeper/Get", err: status.Error(codes.NotFound, "not found")},
+		{method: "/api.SecretKeeper/Auth", err: status.Error(codes.NotFound, "invalid password")},
+		{method: "/api.SecretKeeper/Auth"},
+	}
+	for _, c := range calls {
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, c.err
+		}
+		_, _ = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: c.method}, handler)
+	}
+
+	got := scrape(t, m)
+	for _, want := range []string{
+		`secret_keeper_grpc_requests_total{code="OK",method="Get",service="api.SecretKeeper"} 1`,
+		`secret_keeper_grpc_requests_total{code="NotFound",method="Get",service="api.SecretKeeper"} 1`,
+		`secret_keeper_grpc_request_duration_seconds_count{method="Auth",service="api.SecretKeeper"} 2`,
+		`secret_keeper_auth_failures_total{code="NotFound"} 1`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics do not contain %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestMetrics_ObserveStorage(t *testing.T) {
+	m := New()
+
+	m.ObserveStorage("Get", time.Millisecond, nil)
+	m.ObserveStorage("Get", time.Millisecond, storage.ErrNotFound)
+	m.ObserveStorage("Get", time.Millisecond, fmt.Errorf("wrapped: %w", storage.ErrUnavailable))
+	m.ObserveStorage("Set", time.Millisecond, storage.ErrUnknown)
+	m.ObserveStorage("Set", time.Millisecond, errors.New("something else"))
+
+	got := scrape(t, m)
+	for _, want := range []string{
+		`secret_keeper_storage_operation_duration_seconds_count{operation="Get"} 3`,
+		`secret_keeper_storage_errors_total{error="not_found",operation="Get"} 1`,
+		`secret_keeper_storage_errors_total{error="unavailable",operation="Get"} 1`,
+		`secret_keeper_storage_errors_total{error="unknown",operation="Set"} 1`,
+		`secret_keeper_storage_errors_total{error="other",operation="Set"} 1`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics do not contain %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestMetrics_RegisterIssuedTokens(t *testing.T) {
+	m := New()
+	m.RegisterIssuedTokens(func(ctx context.Context) (uint64, error) {
+		return 42, nil
+	})
+
+	if got := scrape(t, m); !strings.Contains(got, "secret_keeper_issued_tokens 42") {
+		t.Errorf("metrics do not contain issued tokens:\n%s", got)
+	}
+}
+
+func TestMetrics_RegisterIssuedTokens_failed(t *testing.T) {
+	m := New()
+	m.RegisterIssuedTokens(func(ctx context.Context) (uint64, error) {
+		return 0, storage.ErrUnavailable
+	})
+
+	if got := scrape(t, m); strings.Contains(got, "secret_keeper_issued_tokens") {
+		t.Errorf("metrics contain issued tokens after a failed count:\n%s", got)
+	}
+}
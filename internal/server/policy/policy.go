@@ -0,0 +1,189 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"secret-keeper/internal/server/validate"
+	"strings"
+)
+
+// Capabilities a rule can grant or deny
+const (
+	Read   = "read"
+	Write  = "write"
+	Delete = "delete"
+	List   = "list"
+	Share  = "share"
+)
+
+var capabilities = map[string]bool{Read: true, Write: true, Delete: true, List: true, Share: true}
+
+// Rule grants, or with Deny denies, Capabilities on the secrets whose path
+// matches Path. Path is a glob of path.Match where "**" as a whole segment
+// also matches any number of segments, so "prod/*" matches "prod/db" and
+// "prod/**" matches "prod/eu/db" too.
+type Rule struct {
+	Path         string   `json:"path"`
+	Capabilities []string `json:"capabilities"`
+	Deny         bool     `json:"deny,omitempty"`
+}
+
+// Policy is a set of rules attached to users and teams
+type Policy struct {
+	Name  string   `json:"name"`
+	Rules []Rule   `json:"rules"`
+	Users []string `json:"users,omitempty"`
+	Teams []string `json:"teams,omitempty"`
+}
+
+// Subject is who an operation is evaluated for
+type Subject struct {
+	User  string
+	Teams []string
+}
+
+// Decision is the result of an evaluation
+type Decision struct {
+	Allowed bool
+	// Policy is the name of the deciding policy, empty when no policy is
+	// attached to the subject
+	Policy string
+	Reason string
+}
+
+// CheckCapability checks that capability is known
+func CheckCapability(capability string) error {
+	if capabilities[capability] {
+		return nil
+	}
+	return &validate.Error{Violations: []validate.Violation{{
+		Field:       "capability",
+		Description: "must be read, write, delete, list or share",
+	}}}
+}
+
+// Validate checks the name and the rules of p
+func (p Policy) Validate() error {
+	var violations []validate.Violation
+	if p.Name == "" {
+		violations = append(violations, validate.Violation{Field: "name", Description: "must not be empty"})
+	}
+	if len(p.Rules) == 0 {
+		violations = append(violations, validate.Violation{Field: "rules", Description: "must not be empty"})
+	}
+
+	for i, r := range p.Rules {
+		field := fmt.Sprintf("rules[%d]", i)
+		if r.Path == "" {
+			violations = append(violations, validate.Violation{Field: field + ".path", Description: "must not be empty"})
+		} else if _, err := path.Match(r.Path, ""); err != nil {
+			violations = append(violations, validate.Violation{Field: field + ".path", Description: err.Error()})
+		}
+
+		if len(r.Capabilities) == 0 {
+			violations = append(violations, validate.Violation{Field: field + ".capabilities", Description: "must not be empty"})
+		}
+		for _, c := range r.Capabilities {
+			if !capabilities[c] {
+				violations = append(violations, validate.Violation{
+					Field:       field + ".capabilities",
+					Description: fmt.Sprintf("unknown capability %q", c),
+				})
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return &validate.Error{Violations: violations}
+}
+
+// Evaluate decides whether subject has capability on the secret at key.
+// A subject without policies is allowed everything, so that policies can
+// be introduced gradually. Otherwise a matching deny rule wins over the
+// allow rules, and without a matching allow rule the capability is denied.
+func Evaluate(policies []Policy, subject Subject, key, capability string) Decision {
+	var attached bool
+	var allowedBy string
+
+	for _, p := range policies {
+		if !p.appliesTo(subject) {
+			continue
+		}
+		attached = true
+
+		for _, r := range p.Rules {
+			if !r.grants(capability) || !Match(r.Path, key) {
+				continue
+			}
+			if r.Deny {
+				return Decision{Policy: p.Name, Reason: fmt.Sprintf("%s on %s denied by %s", capability, r.Path, p.Name)}
+			}
+			if allowedBy == "" {
+				allowedBy = p.Name
+			}
+		}
+	}
+
+	switch {
+	case !attached:
+		return Decision{Allowed: true, Reason: "no policy attached"}
+	case allowedBy != "":
+		return Decision{Allowed: true, Policy: allowedBy, Reason: fmt.Sprintf("%s allowed by %s", capability, allowedBy)}
+	default:
+		return Decision{Reason: fmt.Sprintf("no policy allows %s on %s", capability, key)}
+	}
+}
+
+// Match reports whether key matches the glob pattern of a rule
+func Match(pattern, key string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(key, "/"))
+}
+
+func matchSegments(pattern, key []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(key); i++ {
+				if matchSegments(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(key) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], key[0]); err != nil || !ok {
+			return false
+		}
+		pattern, key = pattern[1:], key[1:]
+	}
+	return len(key) == 0
+}
+
+func (p Policy) appliesTo(subject Subject) bool {
+	for _, u := range p.Users {
+		if u == subject.User {
+			return true
+		}
+	}
+	for _, t := range p.Teams {
+		for _, st := range subject.Teams {
+			if t == st {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r Rule) grants(capability string) bool {
+	for _, c := range r.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
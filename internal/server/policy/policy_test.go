@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"errors"
+	"secret-keeper/internal/server/validate"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{pattern: "prod/*", key: "prod/db", want: true},
+		{pattern: "prod/*", key: "prod/eu/db", want: false},
+		{pattern: "prod/*", key: "prod", want: false},
+		{pattern: "prod/**", key: "prod/eu/db", want: true},
+		{pattern: "prod/**", key: "prod", want: true},
+		{pattern: "**/db", key: "prod/eu/db", want: true},
+		{pattern: "**", key: "anything/at/all", want: true},
+		{pattern: "db-?", key: "db-1", want: true},
+		{pattern: "db", key: "db2", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+"_"+tt.key, func(t *testing.T) {
+			if got := Match(tt.pattern, tt.key); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	policies := []Policy{
+		{
+			Name:  "prod-readers",
+			Rules: []Rule{{Path: "prod/*", Capabilities: []string{Read, List}}},
+			Users: []string{"alice"},
+		},
+		{
+			Name: "team-dev",
+			Rules: []Rule{
+				{Path: "**", Capabilities: []string{Read, Write, Delete, List, Share}},
+				{Path: "prod/**", Capabilities: []string{Write, Delete}, Deny: true},
+			},
+			Teams: []string{"dev"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		subject    Subject
+		key        string
+		capability string
+		want       bool
+		wantPolicy string
+	}{
+		{name: "noPolicy", subject: Subject{User: "carol"}, key: "prod/db", capability: Write, want: true},
+		{name: "allowed", subject: Subject{User: "alice"}, key: "prod/db", capability: Read, want: true, wantPolicy: "prod-readers"},
+		{name: "notGranted", subject: Subject{User: "alice"}, key: "prod/db", capability: Write, want: false},
+		{name: "outsidePath", subject: Subject{User: "alice"}, key: "dev/db", capability: Read, want: false},
+		{name: "team", subject: Subject{User: "bob", Teams: []string{"dev"}}, key: "dev/db", capability: Write, want: true, wantPolicy: "team-dev"},
+		{name: "denyWins", subject: Subject{User: "alice", Teams: []string{"dev"}}, key: "prod/db", capability: Write, want: false, wantPolicy: "team-dev"},
+		{name: "denyOtherCapability", subject: Subject{User: "bob", Teams: []string{"dev"}}, key: "prod/db", capability: Read, want: true, wantPolicy: "team-dev"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Evaluate(policies, tt.subject, tt.key, tt.capability)
+			if got.Allowed != tt.want || got.Policy != tt.wantPolicy {
+				t.Errorf("Evaluate() = %+v, want allowed %v by %q", got, tt.want, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{name: "ok", policy: Policy{Name: "p", Rules: []Rule{{Path: "prod/*", Capabilities: []string{Read}}}}},
+		{name: "noName", policy: Policy{Rules: []Rule{{Path: "*", Capabilities: []string{Read}}}}, wantErr: true},
+		{name: "noRules", policy: Policy{Name: "p"}, wantErr: true},
+		{name: "badPath", policy: Policy{Name: "p", Rules: []Rule{{Path: "[", Capabilities: []string{Read}}}}, wantErr: true},
+		{name: "unknownCapability", policy: Policy{Name: "p", Rules: []Rule{{Path: "*", Capabilities: []string{"admin"}}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, validate.ErrInvalid)) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
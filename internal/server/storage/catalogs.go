@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"secret-keeper/pkg"
+	"sort"
+	"time"
+)
+
+// Entry is a secret of a user listed without its value
+type Entry struct {
+	Name string
+	Tags []string
+}
+
+// List returns the secrets of user sorted by name. Only the catalog of the
+// user is read, not the values of their secrets.
+func (s *Storage) List(ctx context.Context, username string) (_ []Entry, err error) {
+	defer s.observe("List", time.Now(), &err)
+
+	if s.closed.Load() {
+		return nil, ErrUnavailable
+	}
+
+	if err = s.migrate(ctx, username); err != nil {
+		return nil, err
+	}
+
+	vals, err := s.getAllSecrets(ctx, s.catalogs, username)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(vals))
+	for name, val := range vals {
+		var tags []string
+		if err = json.Unmarshal([]byte(val), &tags); err != nil {
+			s.log(ctx).Warn("Storage.List() failed", pkg.Err(err))
+			return nil, ErrUnknown
+		}
+		entries = append(entries, Entry{Name: name, Tags: tags})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// SetTags replaces the tags of key of user
+func (s *Storage) SetTags(ctx context.Context, username, key string, tags []string) (err error) {
+	defer s.observe("SetTags", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	if err = s.migrate(ctx, username); err != nil {
+		return err
+	}
+
+	// the entry is checked and written back, a delete must not come between
+	unlock := s.catalogLocks.lock(username)
+	defer unlock()
+
+	if _, err = s.getSecret(ctx, "SetTags", s.catalogs, username, key); err != nil {
+		return err
+	}
+
+	val, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+	return s.setSecret(ctx, "SetTags", s.catalogs, username, key, string(val))
+}
+
+// addToCatalog lists key of user, keeping its tags if it is listed already.
+// It is called before the value is set, so that a secret is never stored
+// without being listed.
+func (s *Storage) addToCatalog(ctx context.Context, username, key string) error {
+	return s.addSecret(ctx, "addToCatalog", s.catalogs, username, key, "null")
+}
+
+// removeFromCatalog stops listing key of user
+func (s *Storage) removeFromCatalog(ctx context.Context, username, key string) error {
+	unlock := s.catalogLocks.lock(username)
+	defer unlock()
+
+	err := s.deleteSecret(ctx, "removeFromCatalog", s.catalogs, username, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// deleteCatalog forgets the catalog of user
+func (s *Storage) deleteCatalog(ctx context.Context, username string) error {
+	unlock := s.catalogLocks.lock(username)
+	defer unlock()
+
+	return s.deleteIndex(ctx, "deleteCatalog", s.catalogs, username)
+}
+
+// buildCatalog lists the secrets of a user stored before catalogs existed.
+// It is called by migrate, so the user has no secret named like the legacy
+// password key yet.
+func (s *Storage) buildCatalog(ctx context.Context, username string) error {
+	keyValues, err := s.getAllSecrets(ctx, s.users, username)
+	if err != nil {
+		return err
+	}
+
+	for name := range keyValues {
+		if name == legacyPasswordKey {
+			continue
+		}
+		if err = s.addToCatalog(ctx, username, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestStorage_List(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+	username := "catalog-" + uuid.NewString()
+
+	// the catalog of secrets stored before catalogs existed is built once
+	addLegacyUser(t, s, username, "password", map[string]string{"b": "value", "a": "value"})
+
+	if err := s.Set(ctx, username, "c", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetTags(ctx, username, "c", []string{"prod"}); err != nil {
+		t.Fatal(err)
+	}
+	// setting it again keeps its tags
+	if err := s.Set(ctx, username, "c", "rotated"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, username, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// listing reads the catalog, not the secrets
+	index, err := s.users.Index(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = index.Set(ctx, "unlisted", "value", false); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.List(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name != "b" || entries[1].Name != "c" || len(entries[1].Tags) != 1 {
+		t.Errorf("List() = %+v, want b and c tagged prod", entries)
+	}
+
+	if err = s.SetTags(ctx, username, "a", []string{"prod"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetTags() error = %v, want %v for a deleted secret", err, ErrNotFound)
+	}
+}
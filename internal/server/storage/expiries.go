@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/egorgasay/itisadb-go-sdk"
+	"secret-keeper/pkg"
+	"strings"
+	"time"
+)
+
+// Vault is the personal vault of User or, if Team is set, the vault of Team
+type Vault struct {
+	User string
+	Team string
+}
+
+func (v Vault) id() string {
+	if v.Team != "" {
+		return "team:" + v.Team
+	}
+	return "user:" + v.User
+}
+
+func parseVault(id string) (Vault, bool) {
+	kind, name, ok := strings.Cut(id, ":")
+	switch {
+	case !ok:
+		return Vault{}, false
+	case kind == "team":
+		return Vault{Team: name}, true
+	case kind == "user":
+		return Vault{User: name}, true
+	default:
+		return Vault{}, false
+	}
+}
+
+// SetExpiry makes key of vault expire at expiresAt, a zero expiresAt makes
+// it never expire
+func (s *Storage) SetExpiry(ctx context.Context, vault Vault, key string, expiresAt time.Time) (err error) {
+	defer s.observe("SetExpiry", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	id := vault.id()
+	unlock := s.expiryLocks.lock(id + "/" + key)
+	defer unlock()
+
+	if expiresAt.IsZero() {
+		err = s.deleteSecret(ctx, "SetExpiry", s.expiries, id, key)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	// itisadb cannot list the indexes inside expiries, so the vault is
+	// listed in expiring_vaults for RemoveExpired
+	err = s.expiringVaults.Set(ctx, id, "", true)
+	if err != nil && !errors.Is(err, itisadb.ErrUniqueConstraint) {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.SetExpiry() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+	return s.setSecret(ctx, "SetExpiry", s.expiries, id, key, expiresAt.UTC().Format(time.RFC3339Nano))
+}
+
+// Expiries returns when the expiring keys of vault expire
+func (s *Storage) Expiries(ctx context.Context, vault Vault) (_ map[string]time.Time, err error) {
+	defer s.observe("Expiries", time.Now(), &err)
+
+	if s.closed.Load() {
+		return nil, ErrUnavailable
+	}
+
+	return s.getExpiries(ctx, vault.id())
+}
+
+// Expiry returns when key of vault expires, a zero time if it never does
+func (s *Storage) Expiry(ctx context.Context, vault Vault, key string) (_ time.Time, err error) {
+	defer s.observe("Expiry", time.Now(), &err)
+
+	if s.closed.Load() {
+		return time.Time{}, ErrUnavailable
+	}
+
+	at, err := s.getExpiry(ctx, vault.id(), key)
+	if errors.Is(err, ErrNotFound) {
+		return time.Time{}, nil
+	}
+	return at, err
+}
+
+// RemoveExpired calls remove for every key that expired at now and forgets
+// its expiry unless remove fails. It reports how many keys were removed.
+// Every key is locked while it is removed, so a key set again with a new
+// expiry is never removed for the old one.
+func (s *Storage) RemoveExpired(ctx context.Context, now time.Time, remove func(Vault, string) error) (_ int, err error) {
+	defer s.observe("RemoveExpired", time.Now(), &err)
+
+	if s.closed.Load() {
+		return 0, ErrUnavailable
+	}
+
+	ids, err := s.expiringVaults.GetIndex(ctx)
+	if err != nil {
+		return 0, s.handleIndexError(ctx, err)
+	}
+
+	var removed int
+	for id := range ids {
+		vault, ok := parseVault(id)
+		if !ok {
+			continue
+		}
+
+		expiries, err := s.getExpiries(ctx, id)
+		if err != nil {
+			return removed, err
+		}
+
+		for key, at := range expiries {
+			if now.Before(at) {
+				continue
+			}
+
+			ok, err := s.removeExpired(ctx, vault, key, now, remove)
+			if err != nil {
+				return removed, err
+			}
+			if ok {
+				removed++
+			}
+		}
+	}
+	return removed, nil
+}
+
+// removeExpired calls remove for key of vault and forgets its expiry if it
+// is still expired at now, reporting whether it was
+func (s *Storage) removeExpired(ctx context.Context, vault Vault, key string, now time.Time, remove func(Vault, string) error) (bool, error) {
+	id := vault.id()
+	unlock := s.expiryLocks.lock(id + "/" + key)
+	defer unlock()
+
+	at, err := s.getExpiry(ctx, id, key)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if now.Before(at) {
+		return false, nil
+	}
+
+	if err = remove(vault, key); err != nil {
+		return false, err
+	}
+
+	err = s.deleteSecret(ctx, "removeExpired", s.expiries, id, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	return true, nil
+}
+
+// deleteExpiries forgets the expiries of the vault with id
+func (s *Storage) deleteExpiries(ctx context.Context, id string) error {
+	if err := s.deleteIndex(ctx, "deleteExpiries", s.expiries, id); err != nil {
+		return err
+	}
+
+	err := s.expiringVaults.DeleteAttr(ctx, id)
+	if err == nil || errors.Is(err, itisadb.ErrNotFound) {
+		return nil
+	}
+	if errors.Is(err, itisadb.ErrUnavailable) {
+		return ErrUnavailable
+	}
+
+	s.log(ctx).Warn("Storage.deleteExpiries() failed", pkg.Err(err))
+	return ErrUnknown
+}
+
+func (s *Storage) getExpiries(ctx context.Context, id string) (map[string]time.Time, error) {
+	vals, err := s.getAllSecrets(ctx, s.expiries, id)
+	if err != nil {
+		return nil, err
+	}
+
+	expiries := make(map[string]time.Time, len(vals))
+	for key, val := range vals {
+		at, err := time.Parse(time.RFC3339Nano, val)
+		if err != nil {
+			s.log(ctx).Warn("Storage.getExpiries() failed", pkg.Err(err))
+			return nil, ErrUnknown
+		}
+		expiries[key] = at
+	}
+	return expiries, nil
+}
+
+func (s *Storage) getExpiry(ctx context.Context, id, key string) (time.Time, error) {
+	val, err := s.getSecret(ctx, "getExpiry", s.expiries, id, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, val)
+	if err != nil {
+		s.log(ctx).Warn("Storage.getExpiry() failed", pkg.Err(err))
+		return time.Time{}, ErrUnknown
+	}
+	return at, nil
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestStorage_Expiry(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+	vault := Vault{User: "expiry-" + uuid.NewString()}
+
+	if at, err := s.Expiry(ctx, vault, "key"); err != nil || !at.IsZero() {
+		t.Errorf("Expiry() = %v, %v, want a zero time without an expiry", at, err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour).Round(0)
+	if err := s.SetExpiry(ctx, vault, "key", expiresAt); err != nil {
+		t.Fatal(err)
+	}
+	if at, err := s.Expiry(ctx, vault, "key"); err != nil || !at.Equal(expiresAt) {
+		t.Errorf("Expiry() = %v, %v, want %v", at, err, expiresAt)
+	}
+	if at, err := s.Expiry(ctx, vault, "other"); err != nil || !at.IsZero() {
+		t.Errorf("Expiry() = %v, %v, want a zero time for another key", at, err)
+	}
+}
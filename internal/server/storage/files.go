@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/egorgasay/itisadb-go-sdk"
+	"secret-keeper/pkg"
+	"strconv"
+	"time"
+)
+
+// File is a binary secret of a user. Its content is kept in chunks apart
+// from the string secrets, under a blob name that is new for every upload
+// so that a file is replaced at once.
+type File struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Chunks int    `json:"chunks"`
+	Blob   string `json:"blob"`
+}
+
+// PutChunk stores chunk n of blob of user
+func (s *Storage) PutChunk(ctx context.Context, username, blob string, n int, chunk []byte) (err error) {
+	defer s.observe("PutChunk", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	return s.setSecret(ctx, "PutChunk", s.blobs, username, chunkKey(blob, n), base64.StdEncoding.EncodeToString(chunk))
+}
+
+// GetChunk returns chunk n of blob of user
+func (s *Storage) GetChunk(ctx context.Context, username, blob string, n int) (_ []byte, err error) {
+	defer s.observe("GetChunk", time.Now(), &err)
+
+	if s.closed.Load() {
+		return nil, ErrUnavailable
+	}
+
+	val, err := s.getSecret(ctx, "GetChunk", s.blobs, username, chunkKey(blob, n))
+	if err != nil {
+		return nil, err
+	}
+
+	chunk, err := base64.StdEncoding.DecodeString(val)
+	if err != nil {
+		s.log(ctx).Warn("Storage.GetChunk() failed", pkg.Err(err))
+		return nil, ErrUnknown
+	}
+	return chunk, nil
+}
+
+// DeleteChunks deletes the first chunks of blob of user, as left behind by
+// a failed upload
+func (s *Storage) DeleteChunks(ctx context.Context, username, blob string, chunks int) (err error) {
+	defer s.observe("DeleteChunks", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	return s.deleteChunks(ctx, username, blob, chunks)
+}
+
+// CommitFile makes key of user the file whose chunks are stored already and
+// deletes the chunks of the file it replaces
+func (s *Storage) CommitFile(ctx context.Context, username, key string, file File) (err error) {
+	defer s.observe("CommitFile", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	unlock := s.fileLocks.lock(username)
+	defer unlock()
+
+	old, err := s.getFile(ctx, username, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	val, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if err = s.setSecret(ctx, "CommitFile", s.files, username, key, string(val)); err != nil {
+		return err
+	}
+
+	if old.Blob != "" {
+		// the new file is in place, a failure only leaves chunks behind
+		if err := s.deleteChunks(ctx, username, old.Blob, old.Chunks); err != nil {
+			s.log(ctx).Warn("Storage.CommitFile() failed to delete replaced chunks", pkg.Err(err))
+		}
+	}
+	return nil
+}
+
+// GetFile returns the file key of user
+func (s *Storage) GetFile(ctx context.Context, username, key string) (_ File, err error) {
+	defer s.observe("GetFile", time.Now(), &err)
+
+	if s.closed.Load() {
+		return File{}, ErrUnavailable
+	}
+
+	return s.getFile(ctx, username, key)
+}
+
+// DeleteFile deletes the file key of user with its chunks
+func (s *Storage) DeleteFile(ctx context.Context, username, key string) (err error) {
+	defer s.observe("DeleteFile", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	unlock := s.fileLocks.lock(username)
+	defer unlock()
+
+	file, err := s.getFile(ctx, username, key)
+	if err != nil {
+		return err
+	}
+
+	if err = s.deleteSecret(ctx, "DeleteFile", s.files, username, key); err != nil {
+		return err
+	}
+	return s.deleteChunks(ctx, username, file.Blob, file.Chunks)
+}
+
+// ListFiles returns the files of user by key
+func (s *Storage) ListFiles(ctx context.Context, username string) (_ map[string]File, err error) {
+	defer s.observe("ListFiles", time.Now(), &err)
+
+	if s.closed.Load() {
+		return nil, ErrUnavailable
+	}
+
+	vals, err := s.getAllSecrets(ctx, s.files, username)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]File, len(vals))
+	for key, val := range vals {
+		var file File
+		if err = json.Unmarshal([]byte(val), &file); err != nil {
+			s.log(ctx).Warn("Storage.ListFiles() failed", pkg.Err(err))
+			return nil, ErrUnknown
+		}
+		files[key] = file
+	}
+	return files, nil
+}
+
+func (s *Storage) getFile(ctx context.Context, username, key string) (File, error) {
+	val, err := s.getSecret(ctx, "GetFile", s.files, username, key)
+	if err != nil {
+		return File{}, err
+	}
+
+	var file File
+	if err = json.Unmarshal([]byte(val), &file); err != nil {
+		s.log(ctx).Warn("Storage.GetFile() failed", pkg.Err(err))
+		return File{}, ErrUnknown
+	}
+	return file, nil
+}
+
+func (s *Storage) deleteChunks(ctx context.Context, username, blob string, chunks int) error {
+	for n := 0; n < chunks; n++ {
+		err := s.deleteSecret(ctx, "DeleteChunks", s.blobs, username, chunkKey(blob, n))
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteFiles deletes the files of user with their chunks
+func (s *Storage) deleteFiles(ctx context.Context, username string) error {
+	unlock := s.fileLocks.lock(username)
+	defer unlock()
+
+	for _, parent := range []*itisadb.Index{s.files, s.blobs} {
+		if err := s.deleteIndex(ctx, "deleteFiles", parent, username); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func chunkKey(blob string, n int) string {
+	return blob + "/" + strconv.Itoa(n)
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/egorgasay/itisadb-go-sdk"
+	"secret-keeper/pkg"
+	"time"
+)
+
+// Link is a one-time share link to a snapshot of the secret Key of Owner
+type Link struct {
+	Owner string `json:"owner"`
+	Key   string `json:"key"`
+	// Sealed is the snapshot of the value, sealed with the link token and
+	// the passphrase, so it cannot be read from storage alone
+	Sealed     string    `json:"sealed"`
+	Passphrase bool      `json:"passphrase,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	ViewsLeft  int       `json:"views_left"`
+}
+
+// AddLink saves link with id, failing with ErrAlreadyExists if id is taken
+func (s *Storage) AddLink(ctx context.Context, id string, link Link) (err error) {
+	defer s.observe("AddLink", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	val, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	err = s.links.Set(ctx, id, string(val), true)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrUniqueConstraint) {
+			return ErrAlreadyExists
+		}
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.AddLink() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+	return nil
+}
+
+// RedeemLink uses up a view of the link with id if open accepts it and
+// returns the link as it was before. The link is deleted with its last view
+// or, without a view, once it expired at now; expired links are reported as
+// not found.
+func (s *Storage) RedeemLink(ctx context.Context, id string, now time.Time, open func(Link) error) (_ Link, err error) {
+	defer s.observe("RedeemLink", time.Now(), &err)
+
+	if s.closed.Load() {
+		return Link{}, ErrUnavailable
+	}
+
+	// views are read, counted down and written back
+	unlock := s.linkLocks.lock(id)
+	defer unlock()
+
+	link, err := s.getLink(ctx, id)
+	if err != nil {
+		return Link{}, err
+	}
+
+	if !now.Before(link.ExpiresAt) {
+		if err = s.deleteLink(ctx, id); err != nil {
+			return Link{}, err
+		}
+		return Link{}, ErrNotFound
+	}
+
+	if err = open(link); err != nil {
+		return Link{}, err
+	}
+
+	if link.ViewsLeft <= 1 {
+		return link, s.deleteLink(ctx, id)
+	}
+
+	next := link
+	next.ViewsLeft--
+	val, err := json.Marshal(next)
+	if err != nil {
+		return Link{}, err
+	}
+	if err = s.links.Set(ctx, id, string(val), false); err != nil {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return Link{}, ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.RedeemLink() failed", pkg.Err(err))
+		return Link{}, ErrUnknown
+	}
+	return link, nil
+}
+
+// RemoveLinks deletes the links matching keep returning false and reports
+// how many there were
+func (s *Storage) RemoveLinks(ctx context.Context, keep func(Link) bool) (_ int, err error) {
+	defer s.observe("RemoveLinks", time.Now(), &err)
+
+	if s.closed.Load() {
+		return 0, ErrUnavailable
+	}
+
+	docs, err := s.links.GetIndex(ctx)
+	if err != nil {
+		return 0, s.handleIndexError(ctx, err)
+	}
+
+	var removed int
+	for id := range docs {
+		ok, err := s.removeLink(ctx, id, keep)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// removeLink deletes the link with id if keep returns false for it. The
+// link is read again under its lock, as it may have been redeemed since it
+// was listed.
+func (s *Storage) removeLink(ctx context.Context, id string, keep func(Link) bool) (bool, error) {
+	unlock := s.linkLocks.lock(id)
+	defer unlock()
+
+	link, err := s.getLink(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if keep(link) {
+		return false, nil
+	}
+
+	if err = s.deleteLink(ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Storage) getLink(ctx context.Context, id string) (Link, error) {
+	val, err := s.links.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrNotFound) {
+			return Link{}, ErrNotFound
+		}
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return Link{}, ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.getLink() failed", pkg.Err(err))
+		return Link{}, ErrUnknown
+	}
+
+	var link Link
+	if err = json.Unmarshal([]byte(val), &link); err != nil {
+		s.log(ctx).Warn("Storage.getLink() failed", pkg.Err(err))
+		return Link{}, ErrUnknown
+	}
+	return link, nil
+}
+
+func (s *Storage) deleteLink(ctx context.Context, id string) error {
+	err := s.links.DeleteAttr(ctx, id)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrNotFound) {
+			return ErrNotFound
+		}
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.deleteLink() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+	return nil
+}
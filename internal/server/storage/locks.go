@@ -0,0 +1,42 @@
+package storage
+
+import "sync"
+
+// keyedMutex locks by key, so that changes of different users, teams, links
+// or expiring keys do not wait for each other. The zero value is ready to use.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mu sync.Mutex
+	// refs counts the holders and waiters, the lock is dropped with the last
+	refs int
+}
+
+// lock locks key and returns the func unlocking it
+func (m *keyedMutex) lock(key string) (unlock func()) {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyedLock)
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(m.locks, key)
+		}
+	}
+}
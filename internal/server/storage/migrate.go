@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/egorgasay/itisadb-go-sdk"
+	"secret-keeper/pkg"
+)
+
+// legacyPasswordKey is where the password was kept before account data was
+// moved out of the secrets index
+const legacyPasswordKey = "password"
+
+// migrate moves the password of a user created before accounts existed out
+// of their secrets index and lists their secrets in their catalog. Users are migrated on first access, since itisadb
+// cannot list the indexes inside users.
+func (s *Storage) migrate(ctx context.Context, username string) error {
+	if _, ok := s.migrated.Load(username); ok {
+		return nil
+	}
+
+	_, err := s.accounts.Get(ctx, username)
+	if err == nil {
+		s.migrated.Store(username, struct{}{})
+		return nil
+	}
+	if !errors.Is(err, itisadb.ErrNotFound) {
+		return s.handleMigrateError(ctx, err)
+	}
+
+	index, err := s.users.Index(ctx, username)
+	if err != nil {
+		return s.handleIndexError(ctx, err)
+	}
+
+	password, err := index.Get(ctx, legacyPasswordKey)
+	if errors.Is(err, itisadb.ErrNotFound) {
+		// no such user yet
+		return nil
+	} else if err != nil {
+		return s.handleMigrateError(ctx, err)
+	}
+
+	// the catalog goes first, the account marks the user migrated
+	if err = s.buildCatalog(ctx, username); err != nil {
+		return err
+	}
+
+	// a concurrent migration may have stored it already
+	err = s.accounts.Set(ctx, username, password, true)
+	if err != nil && !errors.Is(err, itisadb.ErrUniqueConstraint) {
+		return s.handleMigrateError(ctx, err)
+	}
+
+	err = index.DeleteAttr(ctx, legacyPasswordKey)
+	if err != nil && !errors.Is(err, itisadb.ErrNotFound) {
+		return s.handleMigrateError(ctx, err)
+	}
+
+	s.migrated.Store(username, struct{}{})
+	s.log(ctx).Info("account data migrated", pkg.String("user", username))
+	return nil
+}
+
+func (s *Storage) handleMigrateError(ctx context.Context, err error) error {
+	if errors.Is(err, itisadb.ErrUnavailable) {
+		return ErrUnavailable
+	}
+	s.log(ctx).Warn("Storage.migrate() failed", pkg.Err(err))
+	return ErrUnknown
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	s, err := New(Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// addLegacyUser creates a user the way it was stored before accounts existed
+func addLegacyUser(t *testing.T, s *Storage, username, password string, secrets map[string]string) {
+	t.Helper()
+
+	ctx := context.Background()
+	index, err := s.users.Index(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = index.Set(ctx, legacyPasswordKey, password, true); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range secrets {
+		if err = index.Set(ctx, k, v, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStorage_migrate(t *testing.T) {
+	ctx := context.Background()
+	username := "legacy-" + uuid.NewString()
+
+	addLegacyUser(t, newTestStorage(t), username, "legacy-password", map[string]string{"db": "value"})
+
+	s := newTestStorage(t)
+
+	names, err := s.GetAllNames(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "db" {
+		t.Errorf("GetAllNames() = %v, want [db]", names)
+	}
+
+	if got, err := s.GetPassword(ctx, username); err != nil || got != "legacy-password" {
+		t.Errorf("GetPassword() = %v, %v, want legacy-password", got, err)
+	}
+
+	// a secret called password no longer touches the credential
+	if err = s.Set(ctx, username, "password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetPassword(ctx, username); err != nil || got != "legacy-password" {
+		t.Errorf("GetPassword() = %v, %v, want legacy-password", got, err)
+	}
+	if got, err := s.Get(ctx, username, "password"); err != nil || got != "secret" {
+		t.Errorf("Get() = %v, %v, want secret", got, err)
+	}
+}
+
+func TestStorage_AddUserLegacy(t *testing.T) {
+	username := "legacy-" + uuid.NewString()
+	addLegacyUser(t, newTestStorage(t), username, "legacy-password", nil)
+
+	s := newTestStorage(t)
+	if err := s.AddUser(context.Background(), username, "other-password"); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("AddUser() error = %v, want %v", err, ErrAlreadyExists)
+	}
+}
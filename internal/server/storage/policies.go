@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/egorgasay/itisadb-go-sdk"
+	"github.com/google/uuid"
+	"secret-keeper/pkg"
+	"time"
+)
+
+// SetPolicy saves the policy document with name
+func (s *Storage) SetPolicy(ctx context.Context, name, doc string) (err error) {
+	defer s.observe("SetPolicy", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	err = s.policies.Set(ctx, name, doc, false)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.SetPolicy() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+	return s.changePoliciesVersion(ctx)
+}
+
+// GetPolicy returns the policy document with name
+func (s *Storage) GetPolicy(ctx context.Context, name string) (_ string, err error) {
+	defer s.observe("GetPolicy", time.Now(), &err)
+
+	if s.closed.Load() {
+		return "", ErrUnavailable
+	}
+
+	doc, err := s.policies.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrNotFound) {
+			return "", ErrNotFound
+		}
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return "", ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.GetPolicy() failed", pkg.Err(err))
+		return "", ErrUnknown
+	}
+	return doc, nil
+}
+
+// DeletePolicy deletes the policy document with name
+func (s *Storage) DeletePolicy(ctx context.Context, name string) (err error) {
+	defer s.observe("DeletePolicy", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	err = s.policies.DeleteAttr(ctx, name)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrNotFound) {
+			return ErrNotFound
+		}
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.DeletePolicy() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+	return s.changePoliciesVersion(ctx)
+}
+
+// Policies returns all policy documents by name
+func (s *Storage) Policies(ctx context.Context) (_ map[string]string, err error) {
+	defer s.observe("Policies", time.Now(), &err)
+
+	if s.closed.Load() {
+		return nil, ErrUnavailable
+	}
+
+	docs, err := s.policies.GetIndex(ctx)
+	if err != nil {
+		return nil, s.handleIndexError(ctx, err)
+	}
+	return docs, nil
+}
+
+// policiesVersionKey is the key of the version of the policies in versions
+const policiesVersionKey = "policies"
+
+// PoliciesVersion returns the version of the policies, which changes with
+// every policy put or deleted, empty if no policy ever was. Policies read
+// after it are at least as new.
+func (s *Storage) PoliciesVersion(ctx context.Context) (_ string, err error) {
+	defer s.observe("PoliciesVersion", time.Now(), &err)
+
+	if s.closed.Load() {
+		return "", ErrUnavailable
+	}
+
+	version, err := s.versions.Get(ctx, policiesVersionKey)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrNotFound) {
+			return "", nil
+		}
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return "", ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.PoliciesVersion() failed", pkg.Err(err))
+		return "", ErrUnknown
+	}
+	return version, nil
+}
+
+// changePoliciesVersion is called after a policy is written, so that a
+// reader seeing the new version also sees the policy
+func (s *Storage) changePoliciesVersion(ctx context.Context) error {
+	err := s.versions.Set(ctx, policiesVersionKey, uuid.NewString(), false)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.changePoliciesVersion() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/egorgasay/itisadb-go-sdk"
+	"net/url"
+	"secret-keeper/pkg"
+	"sort"
+	"time"
+)
+
+// Share grants Recipient access to the secret Key of Owner
+type Share struct {
+	Owner     string `json:"owner"`
+	Key       string `json:"key"`
+	Recipient string `json:"recipient"`
+	Mode      string `json:"mode"`
+}
+
+// shareList is where the shares of a user are kept from one side, every
+// share in its own entry
+type shareList struct {
+	index *itisadb.Index
+	// other returns the user on the other side of a share
+	other func(Share) string
+}
+
+// entryKey returns the key of the entry of sh, unique among the shares of
+// a user
+func (l shareList) entryKey(sh Share) string {
+	return url.PathEscape(l.other(sh)) + "/" + sh.Key
+}
+
+func (s *Storage) sharedWithList() shareList {
+	return shareList{index: s.sharedWith, other: func(sh Share) string { return sh.Owner }}
+}
+
+func (s *Storage) sharedByList() shareList {
+	return shareList{index: s.sharedBy, other: func(sh Share) string { return sh.Recipient }}
+}
+
+// AddShare saves share, replacing the mode of an existing one
+func (s *Storage) AddShare(ctx context.Context, share Share) (err error) {
+	defer s.observe("AddShare", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	if err = s.setShare(ctx, s.sharedWithList(), share.Recipient, share); err != nil {
+		return err
+	}
+	return s.setShare(ctx, s.sharedByList(), share.Owner, share)
+}
+
+// RemoveShares deletes the shares matching keep returning false and
+// reports how many there were. Shares are looked up by owner if it is not
+// empty, otherwise by recipient.
+func (s *Storage) RemoveShares(ctx context.Context, owner, recipient string, keep func(Share) bool) (_ int, err error) {
+	defer s.observe("RemoveShares", time.Now(), &err)
+
+	if s.closed.Load() {
+		return 0, ErrUnavailable
+	}
+
+	var shares []Share
+	if owner != "" {
+		shares, err = s.getShares(ctx, s.sharedByList(), owner)
+	} else {
+		shares, err = s.getShares(ctx, s.sharedWithList(), recipient)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int
+	for _, sh := range shares {
+		if keep(sh) {
+			continue
+		}
+
+		if err = s.deleteShare(ctx, s.sharedWithList(), sh.Recipient, sh); err != nil {
+			return removed, err
+		}
+		if err = s.deleteShare(ctx, s.sharedByList(), sh.Owner, sh); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// SharedWith returns the shares granted to recipient sorted by owner and
+// key
+func (s *Storage) SharedWith(ctx context.Context, recipient string) (_ []Share, err error) {
+	defer s.observe("SharedWith", time.Now(), &err)
+
+	if s.closed.Load() {
+		return nil, ErrUnavailable
+	}
+
+	return s.getShares(ctx, s.sharedWithList(), recipient)
+}
+
+// SharedBy returns the shares granted by owner sorted by recipient and key
+func (s *Storage) SharedBy(ctx context.Context, owner string) (_ []Share, err error) {
+	defer s.observe("SharedBy", time.Now(), &err)
+
+	if s.closed.Load() {
+		return nil, ErrUnavailable
+	}
+
+	return s.getShares(ctx, s.sharedByList(), owner)
+}
+
+func (s *Storage) getShares(ctx context.Context, list shareList, username string) ([]Share, error) {
+	vals, err := s.getAllSecrets(ctx, list.index, username)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(vals))
+	for key := range vals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	shares := make([]Share, 0, len(keys))
+	for _, key := range keys {
+		var sh Share
+		if err = json.Unmarshal([]byte(vals[key]), &sh); err != nil {
+			s.log(ctx).Warn("Storage.getShares() failed", pkg.Err(err))
+			return nil, ErrUnknown
+		}
+		shares = append(shares, sh)
+	}
+	return shares, nil
+}
+
+func (s *Storage) setShare(ctx context.Context, list shareList, username string, sh Share) error {
+	val, err := json.Marshal(sh)
+	if err != nil {
+		return err
+	}
+	return s.setSecret(ctx, "setShare", list.index, username, list.entryKey(sh), string(val))
+}
+
+func (s *Storage) deleteShare(ctx context.Context, list shareList, username string, sh Share) error {
+	err := s.deleteSecret(ctx, "deleteShare", list.index, username, list.entryKey(sh))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
@@ -2,17 +2,87 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/egorgasay/itisadb-go-sdk"
 	"secret-keeper/pkg"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Storage for data
+// Storage for data.
+//
+// Secrets of a user are kept in their own index inside users, account data
+// is kept apart from them in accounts, tokens and totp, all keyed by
+// username except tokens. The shares of a user are kept in their own index
+// inside shared_with and shared_by. Secrets of a team are kept in their own
+// index inside vaults, the team itself in teams and the teams of a user in
+// their own index inside memberships. Access policies are kept in policies
+// by name, their version in versions. One-time share links are kept in
+// links by the hash of their token. When the secrets of a vault expire is
+// kept in its own index inside expiries, the vaults with expiring secrets
+// in expiring_vaults. The names and tags of the secrets of a user are kept
+// in their own index inside catalogs. Files of a user are kept in their own
+// index inside files, their chunks in their own index inside blobs.
 type Storage struct {
-	users  *itisadb.Index
-	tokens *itisadb.Index
-	logger pkg.Logger
+	db             *itisadb.Client
+	users          *itisadb.Index
+	accounts       *itisadb.Index
+	tokens         *itisadb.Index
+	totp           *itisadb.Index
+	sharedWith     *itisadb.Index
+	sharedBy       *itisadb.Index
+	teams          *itisadb.Index
+	members        *itisadb.Index
+	vaults         *itisadb.Index
+	policies       *itisadb.Index
+	versions       *itisadb.Index
+	links          *itisadb.Index
+	expiries       *itisadb.Index
+	expiringVaults *itisadb.Index
+	catalogs       *itisadb.Index
+	files          *itisadb.Index
+	blobs          *itisadb.Index
+	logger         pkg.Logger
+	closed         atomic.Bool
+
+	totpLocks    keyedMutex
+	teamLocks    keyedMutex
+	linkLocks    keyedMutex
+	expiryLocks  keyedMutex
+	catalogLocks keyedMutex
+	fileLocks    keyedMutex
+
+	// migrated holds the usernames whose account data is known to be out of
+	// their secrets index
+	migrated sync.Map
+
+	observer Observer
+}
+
+// Observer is notified about the duration and the result of every storage
+// operation.
+type Observer interface {
+	ObserveStorage(op string, duration time.Duration, err error)
+}
+
+// Option configures the storage
+type Option func(s *Storage)
+
+// WithLogger sets the logger used when a request carries none
+func WithLogger(l pkg.Logger) Option {
+	return func(s *Storage) {
+		s.logger = l
+	}
+}
+
+// WithObserver sets the observer of storage operations
+func WithObserver(o Observer) Option {
+	return func(s *Storage) {
+		s.observer = o
+	}
 }
 
 // Config for storage
@@ -21,7 +91,7 @@ type Config struct {
 }
 
 // New creates new storage
-func New(c Config) (*Storage, error) {
+func New(c Config, opts ...Option) (*Storage, error) {
 	db, err := itisadb.New(c.URI)
 	if err != nil {
 		return nil, err
@@ -37,10 +107,113 @@ func New(c Config) (*Storage, error) {
 		return nil, err
 	}
 
-	return &Storage{
-		users:  users,
-		tokens: tokens,
-	}, nil
+	accounts, err := db.Index(context.Background(), "accounts")
+	if err != nil {
+		return nil, err
+	}
+
+	totp, err := db.Index(context.Background(), "totp")
+	if err != nil {
+		return nil, err
+	}
+
+	sharedWith, err := db.Index(context.Background(), "shared_with")
+	if err != nil {
+		return nil, err
+	}
+
+	sharedBy, err := db.Index(context.Background(), "shared_by")
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := db.Index(context.Background(), "teams")
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := db.Index(context.Background(), "memberships")
+	if err != nil {
+		return nil, err
+	}
+
+	vaults, err := db.Index(context.Background(), "vaults")
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := db.Index(context.Background(), "policies")
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := db.Index(context.Background(), "versions")
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := db.Index(context.Background(), "links")
+	if err != nil {
+		return nil, err
+	}
+
+	expiries, err := db.Index(context.Background(), "expiries")
+	if err != nil {
+		return nil, err
+	}
+
+	expiringVaults, err := db.Index(context.Background(), "expiring_vaults")
+	if err != nil {
+		return nil, err
+	}
+
+	catalogs, err := db.Index(context.Background(), "catalogs")
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := db.Index(context.Background(), "files")
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := db.Index(context.Background(), "blobs")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Storage{
+		db:             db,
+		users:          users,
+		accounts:       accounts,
+		tokens:         tokens,
+		totp:           totp,
+		sharedWith:     sharedWith,
+		sharedBy:       sharedBy,
+		teams:          teams,
+		members:        members,
+		vaults:         vaults,
+		policies:       policies,
+		versions:       versions,
+		links:          links,
+		expiries:       expiries,
+		expiringVaults: expiringVaults,
+		catalogs:       catalogs,
+		files:          files,
+		blobs:          blobs,
+		logger:         pkg.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+func (s *Storage) observe(op string, start time.Time, err *error) {
+	if s.observer != nil {
+		s.observer.ObserveStorage(op, time.Since(start), *err)
+	}
 }
 
 // ErrNotFound when value not found
@@ -55,11 +228,47 @@ var ErrUnknown = errors.New("unknown")
 // ErrAlreadyExists when something is already exists
 var ErrAlreadyExists = errors.New("already exists")
 
+// Close closes the storage. The itisadb SDK does not expose its gRPC
+// connection, so after Close every operation fails with ErrUnavailable.
+func (s *Storage) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+// Ping checks that itisadb is reachable
+func (s *Storage) Ping(ctx context.Context) (err error) {
+	defer s.observe("Ping", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	if _, err = s.db.IsIndex(ctx, "users"); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return nil
+}
+
 // Get returns value by key
-func (s *Storage) Get(ctx context.Context, username, key string) (string, error) {
-	index, err := s.users.Index(ctx, username)
+func (s *Storage) Get(ctx context.Context, username, key string) (_ string, err error) {
+	defer s.observe("Get", time.Now(), &err)
+
+	if s.closed.Load() {
+		return "", ErrUnavailable
+	}
+
+	if err = s.migrate(ctx, username); err != nil {
+		return "", err
+	}
+
+	return s.getSecret(ctx, "Get", s.users, username, key)
+}
+
+// getSecret returns the value of key in the index name inside parent
+func (s *Storage) getSecret(ctx context.Context, op string, parent *itisadb.Index, name, key string) (string, error) {
+	index, err := parent.Index(ctx, name)
 	if err != nil {
-		return "", s.handleIndexError(err)
+		return "", s.handleIndexError(ctx, err)
 	}
 
 	v, err := index.Get(ctx, key)
@@ -70,13 +279,13 @@ func (s *Storage) Get(ctx context.Context, username, key string) (string, error)
 		if errors.Is(err, itisadb.ErrUnavailable) {
 			return "", ErrUnavailable
 		}
-		s.logger.Warn(fmt.Errorf("Storage.Get(): %w", err).Error())
+		s.log(ctx).Warn("Storage."+op+"() failed", pkg.Err(err))
 		return "", ErrUnknown
 	}
 	return v, nil
 }
 
-func (s *Storage) handleIndexError(err error) error {
+func (s *Storage) handleIndexError(ctx context.Context, err error) error {
 	if errors.Is(err, itisadb.ErrIndexNotFound) {
 		// TODO: log error
 		return ErrUnknown
@@ -84,15 +293,38 @@ func (s *Storage) handleIndexError(err error) error {
 	if errors.Is(err, itisadb.ErrUnavailable) {
 		return ErrUnavailable
 	}
-	s.logger.Warn(err.Error())
+	s.log(ctx).Warn("Storage.Index() failed", pkg.Err(err))
 	return ErrUnknown
 }
 
+func (s *Storage) log(ctx context.Context) pkg.Logger {
+	return pkg.LoggerFromContext(ctx, s.logger)
+}
+
 // Set adds k:v to storage
-func (s *Storage) Set(ctx context.Context, username, key, value string) error {
-	index, err := s.users.Index(ctx, username)
+func (s *Storage) Set(ctx context.Context, username, key, value string) (err error) {
+	defer s.observe("Set", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	if err = s.migrate(ctx, username); err != nil {
+		return err
+	}
+
+	if err = s.addToCatalog(ctx, username, key); err != nil {
+		return err
+	}
+
+	return s.setSecret(ctx, "Set", s.users, username, key, value)
+}
+
+// setSecret sets key to value in the index name inside parent
+func (s *Storage) setSecret(ctx context.Context, op string, parent *itisadb.Index, name, key, value string) error {
+	index, err := parent.Index(ctx, name)
 	if err != nil {
-		return s.handleIndexError(err)
+		return s.handleIndexError(ctx, err)
 	}
 
 	err = index.Set(ctx, key, value, false)
@@ -101,15 +333,21 @@ func (s *Storage) Set(ctx context.Context, username, key, value string) error {
 			return ErrUnavailable
 		}
 
-		s.logger.Warn(err.Error())
+		s.log(ctx).Warn("Storage."+op+"() failed", pkg.Err(err))
 		return ErrUnknown
 	}
 	return nil
 }
 
 // AddToken adds token to storage
-func (s *Storage) AddToken(ctx context.Context, token string, username string) error {
-	err := s.tokens.Set(ctx, token, username, false)
+func (s *Storage) AddToken(ctx context.Context, token string, username string) (err error) {
+	defer s.observe("AddToken", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	err = s.tokens.Set(ctx, token, username, false)
 	if err != nil {
 		if errors.Is(err, itisadb.ErrUniqueConstraint) {
 			return ErrAlreadyExists
@@ -117,14 +355,20 @@ func (s *Storage) AddToken(ctx context.Context, token string, username string) e
 		if errors.Is(err, itisadb.ErrUnavailable) {
 			return ErrUnavailable
 		}
-		s.logger.Warn(fmt.Errorf("Storage.AddToken(): %w", err).Error())
+		s.log(ctx).Warn("Storage.AddToken() failed", pkg.Err(err))
 		return ErrUnknown
 	}
 	return nil
 }
 
 // GetUsername returns username of token
-func (s *Storage) GetUsername(ctx context.Context, token string) (string, error) {
+func (s *Storage) GetUsername(ctx context.Context, token string) (_ string, err error) {
+	defer s.observe("GetUsername", time.Now(), &err)
+
+	if s.closed.Load() {
+		return "", ErrUnavailable
+	}
+
 	username, err := s.tokens.Get(ctx, token)
 	if err != nil {
 		if errors.Is(err, itisadb.ErrNotFound) {
@@ -134,20 +378,41 @@ func (s *Storage) GetUsername(ctx context.Context, token string) (string, error)
 			return "", ErrUnavailable
 		}
 
-		s.logger.Warn(fmt.Errorf("Storage.GetUsername(): %w", err).Error())
+		s.log(ctx).Warn("Storage.GetUsername() failed", pkg.Err(err))
 		return "", ErrUnknown
 	}
 	return username, nil
 }
 
-// AddUser adds user to storage
-func (s *Storage) AddUser(ctx context.Context, username string, password string) error {
-	index, err := s.users.Index(ctx, username)
+// CountTokens returns the number of issued tokens
+func (s *Storage) CountTokens(ctx context.Context) (_ uint64, err error) {
+	defer s.observe("CountTokens", time.Now(), &err)
+
+	if s.closed.Load() {
+		return 0, ErrUnavailable
+	}
+
+	size, err := s.tokens.Size(ctx)
 	if err != nil {
-		return s.handleIndexError(err)
+		return 0, s.handleIndexError(ctx, err)
 	}
+	return size, nil
+}
+
+// AddUser adds user to storage
+func (s *Storage) AddUser(ctx context.Context, username string, password string) (err error) {
+	defer s.observe("AddUser", time.Now(), &err)
 
-	err = index.Set(ctx, "password", password, true)
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	// a user of the old layout has to be found by the unique constraint
+	if err = s.migrate(ctx, username); err != nil {
+		return err
+	}
+
+	err = s.accounts.Set(ctx, username, password, true)
 	if err != nil {
 		if errors.Is(err, itisadb.ErrUniqueConstraint) {
 			return ErrAlreadyExists
@@ -157,20 +422,163 @@ func (s *Storage) AddUser(ctx context.Context, username string, password string)
 			return ErrUnavailable
 		}
 
-		s.logger.Warn(fmt.Errorf("Storage.AddUser(): %w", err).Error())
+		s.log(ctx).Warn("Storage.AddUser() failed", pkg.Err(err))
 		return ErrUnknown
 	}
+	s.migrated.Store(username, struct{}{})
 	return nil
 }
 
-// GetPassword returns password of user
-func (s *Storage) GetPassword(ctx context.Context, username string) (string, error) {
+// SetPassword replaces password of user
+func (s *Storage) SetPassword(ctx context.Context, username string, password string) (err error) {
+	defer s.observe("SetPassword", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	if err = s.migrate(ctx, username); err != nil {
+		return err
+	}
+
+	err = s.accounts.Set(ctx, username, password, false)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.SetPassword() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+	return nil
+}
+
+// RevokeTokens deletes all tokens of user except keep and returns how many
+// were deleted
+func (s *Storage) RevokeTokens(ctx context.Context, username string, keep string) (_ int, err error) {
+	defer s.observe("RevokeTokens", time.Now(), &err)
+
+	if s.closed.Load() {
+		return 0, ErrUnavailable
+	}
+
+	// tokens are keyed by token, so all of them have to be scanned
+	tokens, err := s.tokens.GetIndex(ctx)
+	if err != nil {
+		return 0, s.handleIndexError(ctx, err)
+	}
+
+	var revoked int
+	for token, owner := range tokens {
+		if owner != username || token == keep {
+			continue
+		}
+
+		err = s.tokens.DeleteAttr(ctx, token)
+		if err != nil && !errors.Is(err, itisadb.ErrNotFound) {
+			if errors.Is(err, itisadb.ErrUnavailable) {
+				return revoked, ErrUnavailable
+			}
+			s.log(ctx).Warn("Storage.RevokeTokens() failed", pkg.Err(err))
+			return revoked, ErrUnknown
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// DeleteUser deletes the account of user, their secrets and their
+// two-factor authentication state
+func (s *Storage) DeleteUser(ctx context.Context, username string) (err error) {
+	defer s.observe("DeleteUser", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	if err = s.deleteExpiries(ctx, Vault{User: username}.id()); err != nil {
+		return err
+	}
+
+	if err = s.deleteCatalog(ctx, username); err != nil {
+		return err
+	}
+
+	if err = s.deleteFiles(ctx, username); err != nil {
+		return err
+	}
+
 	index, err := s.users.Index(ctx, username)
 	if err != nil {
-		return "", s.handleIndexError(err)
+		return s.handleIndexError(ctx, err)
+	}
+
+	err = index.DeleteIndex(ctx)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+		s.log(ctx).Warn("Storage.DeleteUser() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+
+	// the account goes last: secrets left without it by a failed delete
+	// would be taken for a user created before accounts existed, and a
+	// secret named like the legacy password key for their password
+	for _, index := range []*itisadb.Index{s.totp, s.accounts} {
+		err = index.DeleteAttr(ctx, username)
+		if err != nil && !errors.Is(err, itisadb.ErrNotFound) {
+			if errors.Is(err, itisadb.ErrUnavailable) {
+				return ErrUnavailable
+			}
+			s.log(ctx).Warn("Storage.DeleteUser() failed", pkg.Err(err))
+			return ErrUnknown
+		}
+	}
+	s.migrated.Delete(username)
+	return nil
+}
+
+// UserExists reports whether user is registered
+func (s *Storage) UserExists(ctx context.Context, username string) (_ bool, err error) {
+	defer s.observe("UserExists", time.Now(), &err)
+
+	if s.closed.Load() {
+		return false, ErrUnavailable
+	}
+
+	if err = s.migrate(ctx, username); err != nil {
+		return false, err
+	}
+
+	_, err = s.accounts.Get(ctx, username)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrNotFound) {
+			return false, nil
+		}
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return false, ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.UserExists() failed", pkg.Err(err))
+		return false, ErrUnknown
 	}
+	return true, nil
+}
 
-	val, err := index.Get(ctx, "password")
+// GetPassword returns password of user
+func (s *Storage) GetPassword(ctx context.Context, username string) (_ string, err error) {
+	defer s.observe("GetPassword", time.Now(), &err)
+
+	if s.closed.Load() {
+		return "", ErrUnavailable
+	}
+
+	if err = s.migrate(ctx, username); err != nil {
+		return "", err
+	}
+
+	val, err := s.accounts.Get(ctx, username)
 	if err != nil {
 		if errors.Is(err, itisadb.ErrNotFound) {
 			return "", ErrNotFound
@@ -179,40 +587,90 @@ func (s *Storage) GetPassword(ctx context.Context, username string) (string, err
 			return "", ErrUnavailable
 		}
 
-		s.logger.Warn(fmt.Errorf("Storage.GetPassword(): %w", err).Error())
+		s.log(ctx).Warn("Storage.GetPassword() failed", pkg.Err(err))
 		return "", ErrUnknown
 	}
 	return val, nil
 }
 
-// GetAllNames returns all names of user
-func (s *Storage) GetAllNames(ctx context.Context, username string) ([]string, error) {
-	index, err := s.users.Index(ctx, username)
+// GetAllNames returns all names of user sorted
+func (s *Storage) GetAllNames(ctx context.Context, username string) (_ []string, err error) {
+	defer s.observe("GetAllNames", time.Now(), &err)
+
+	entries, err := s.List(ctx, username)
 	if err != nil {
 		return nil, err
 	}
 
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names, nil
+}
+
+// GetAll returns all secrets of user
+func (s *Storage) GetAll(ctx context.Context, username string) (_ map[string]string, err error) {
+	defer s.observe("GetAll", time.Now(), &err)
+
+	return s.getAll(ctx, username)
+}
+
+func (s *Storage) getAll(ctx context.Context, username string) (map[string]string, error) {
+	if s.closed.Load() {
+		return nil, ErrUnavailable
+	}
+
+	if err := s.migrate(ctx, username); err != nil {
+		return nil, err
+	}
+
+	return s.getAllSecrets(ctx, s.users, username)
+}
+
+// getAllSecrets returns the contents of the index name inside parent
+func (s *Storage) getAllSecrets(ctx context.Context, parent *itisadb.Index, name string) (map[string]string, error) {
+	index, err := parent.Index(ctx, name)
+	if err != nil {
+		return nil, s.handleIndexError(ctx, err)
+	}
+
 	keyValues, err := index.GetIndex(ctx)
 	if err != nil {
-		return nil, s.handleIndexError(err)
+		return nil, s.handleIndexError(ctx, err)
 	}
+	return keyValues, nil
+}
 
-	var names []string
+// Delete deletes key from index
+func (s *Storage) Delete(ctx context.Context, username string, key string) (err error) {
+	defer s.observe("Delete", time.Now(), &err)
 
-	delete(keyValues, "password")
-	delete(keyValues, username)
-	for key := range keyValues {
-		names = append(names, key)
+	if s.closed.Load() {
+		return ErrUnavailable
 	}
 
-	return names, nil
+	if err = s.migrate(ctx, username); err != nil {
+		return err
+	}
+
+	err = s.deleteSecret(ctx, "Delete", s.users, username, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	// a secret that is gone is not listed either
+	if catalogErr := s.removeFromCatalog(ctx, username, key); catalogErr != nil {
+		return catalogErr
+	}
+	return err
 }
 
-// Delete deletes key from index
-func (s *Storage) Delete(ctx context.Context, username string, key string) error {
-	index, err := s.users.Index(ctx, username)
+// deleteSecret deletes key from the index name inside parent
+func (s *Storage) deleteSecret(ctx context.Context, op string, parent *itisadb.Index, name, key string) error {
+	index, err := parent.Index(ctx, name)
 	if err != nil {
-		return s.handleIndexError(err)
+		return s.handleIndexError(ctx, err)
 	}
 
 	err = index.DeleteAttr(ctx, key)
@@ -223,10 +681,137 @@ func (s *Storage) Delete(ctx context.Context, username string, key string) error
 		if errors.Is(err, itisadb.ErrUnavailable) {
 			return ErrUnavailable
 		}
-		s.logger.Warn(fmt.Errorf("Storage.Delete(): %w", err).Error())
+		s.log(ctx).Warn("Storage."+op+"() failed", pkg.Err(err))
 
 		return err
 	}
 
 	return nil
 }
+
+// addSecret sets key to value in the index name inside parent unless key
+// is set already
+func (s *Storage) addSecret(ctx context.Context, op string, parent *itisadb.Index, name, key, value string) error {
+	index, err := parent.Index(ctx, name)
+	if err != nil {
+		return s.handleIndexError(ctx, err)
+	}
+
+	err = index.Set(ctx, key, value, true)
+	if err != nil && !errors.Is(err, itisadb.ErrUniqueConstraint) {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage."+op+"() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+	return nil
+}
+
+// deleteIndex deletes the index name inside parent with its contents
+func (s *Storage) deleteIndex(ctx context.Context, op string, parent *itisadb.Index, name string) error {
+	index, err := parent.Index(ctx, name)
+	if err != nil {
+		return s.handleIndexError(ctx, err)
+	}
+
+	if err = index.DeleteIndex(ctx); err != nil && !errors.Is(err, itisadb.ErrIndexNotFound) {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+		s.log(ctx).Warn("Storage."+op+"() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+	return nil
+}
+
+// TOTP is the two-factor authentication state of a user
+type TOTP struct {
+	// Secret is the key of the confirmed authenticator, empty if two-factor
+	// authentication is disabled.
+	Secret string `json:"secret,omitempty"`
+	// RecoveryCodes are the hashes of the unused recovery codes.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+
+	// PendingSecret and PendingRecoveryCodes are set by an enrollment that
+	// is not confirmed yet.
+	PendingSecret        string   `json:"pending_secret,omitempty"`
+	PendingRecoveryCodes []string `json:"pending_recovery_codes,omitempty"`
+
+	// LastStep is the time step of the last TOTP code accepted, a code is
+	// not accepted again.
+	LastStep int64 `json:"last_step,omitempty"`
+}
+
+// GetTOTP returns the two-factor authentication state of user
+func (s *Storage) GetTOTP(ctx context.Context, username string) (_ TOTP, err error) {
+	defer s.observe("GetTOTP", time.Now(), &err)
+
+	if s.closed.Load() {
+		return TOTP{}, ErrUnavailable
+	}
+
+	return s.getTOTP(ctx, username)
+}
+
+func (s *Storage) getTOTP(ctx context.Context, username string) (TOTP, error) {
+	val, err := s.totp.Get(ctx, username)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrNotFound) {
+			return TOTP{}, ErrNotFound
+		}
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return TOTP{}, ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.getTOTP() failed", pkg.Err(err))
+		return TOTP{}, ErrUnknown
+	}
+
+	var t TOTP
+	if err = json.Unmarshal([]byte(val), &t); err != nil {
+		s.log(ctx).Warn("Storage.getTOTP() failed", pkg.Err(err))
+		return TOTP{}, ErrUnknown
+	}
+	return t, nil
+}
+
+// UpdateTOTP applies update to the two-factor authentication state of
+// user, a zero state if there is none, and saves it. Nothing is saved if
+// update fails. Updates of the same user do not overlap.
+func (s *Storage) UpdateTOTP(ctx context.Context, username string, update func(*TOTP) error) (err error) {
+	defer s.observe("UpdateTOTP", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	unlock := s.totpLocks.lock(username)
+	defer unlock()
+
+	t, err := s.getTOTP(ctx, username)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if err = update(&t); err != nil {
+		return err
+	}
+
+	val, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	err = s.totp.Set(ctx, username, string(val), false)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.UpdateTOTP() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+	return nil
+}
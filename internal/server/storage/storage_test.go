@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestStorage_GetAll_ownName(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+	username := "own-" + uuid.NewString()
+
+	if err := s.AddUser(ctx, username, "password"); err != nil {
+		t.Fatal(err)
+	}
+	// a secret may be named like its owner
+	if err := s.Set(ctx, username, username, "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets, err := s.GetAll(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || secrets[username] != "value" {
+		t.Errorf("GetAll() = %v, want the secret named %s", secrets, username)
+	}
+
+	entries, err := s.List(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != username {
+		t.Errorf("List() = %+v, want the secret named %s", entries, username)
+	}
+}
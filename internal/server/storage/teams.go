@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/egorgasay/itisadb-go-sdk"
+	"secret-keeper/pkg"
+	"sort"
+	"time"
+)
+
+// Team is a group of users sharing a vault
+type Team struct {
+	Name string `json:"name"`
+	// Members maps the username of every member to their role
+	Members map[string]string `json:"members"`
+}
+
+// CreateTeam saves a new team, failing with ErrAlreadyExists if the name is
+// taken
+func (s *Storage) CreateTeam(ctx context.Context, team Team) (err error) {
+	defer s.observe("CreateTeam", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	unlock := s.teamLocks.lock(team.Name)
+	defer unlock()
+
+	val, err := json.Marshal(team)
+	if err != nil {
+		return err
+	}
+
+	err = s.teams.Set(ctx, team.Name, string(val), true)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrUniqueConstraint) {
+			return ErrAlreadyExists
+		}
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.CreateTeam() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+
+	for username := range team.Members {
+		if err = s.addMembership(ctx, username, team.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTeam returns the team with name
+func (s *Storage) GetTeam(ctx context.Context, name string) (_ Team, err error) {
+	defer s.observe("GetTeam", time.Now(), &err)
+
+	if s.closed.Load() {
+		return Team{}, ErrUnavailable
+	}
+
+	return s.getTeam(ctx, name)
+}
+
+// UpdateTeam changes the team with name by update and saves it unless
+// update fails. The memberships of added and removed members follow.
+func (s *Storage) UpdateTeam(ctx context.Context, name string, update func(*Team) error) (_ Team, err error) {
+	defer s.observe("UpdateTeam", time.Now(), &err)
+
+	if s.closed.Load() {
+		return Team{}, ErrUnavailable
+	}
+
+	unlock := s.teamLocks.lock(name)
+	defer unlock()
+
+	team, err := s.getTeam(ctx, name)
+	if err != nil {
+		return Team{}, err
+	}
+
+	before := make(map[string]string, len(team.Members))
+	for username, role := range team.Members {
+		before[username] = role
+	}
+
+	if err = update(&team); err != nil {
+		return Team{}, err
+	}
+
+	val, err := json.Marshal(team)
+	if err != nil {
+		return Team{}, err
+	}
+
+	err = s.teams.Set(ctx, name, string(val), false)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return Team{}, ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.UpdateTeam() failed", pkg.Err(err))
+		return Team{}, ErrUnknown
+	}
+
+	for username := range team.Members {
+		if _, ok := before[username]; !ok {
+			if err = s.addMembership(ctx, username, name); err != nil {
+				return Team{}, err
+			}
+		}
+	}
+	for username := range before {
+		if _, ok := team.Members[username]; !ok {
+			if err = s.removeMembership(ctx, username, name); err != nil {
+				return Team{}, err
+			}
+		}
+	}
+	return team, nil
+}
+
+// DeleteTeam deletes the team with name and its vault
+func (s *Storage) DeleteTeam(ctx context.Context, name string) (err error) {
+	defer s.observe("DeleteTeam", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	unlock := s.teamLocks.lock(name)
+	defer unlock()
+
+	team, err := s.getTeam(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	for username := range team.Members {
+		if err = s.removeMembership(ctx, username, name); err != nil {
+			return err
+		}
+	}
+
+	if err = s.deleteExpiries(ctx, Vault{Team: name}.id()); err != nil {
+		return err
+	}
+
+	index, err := s.vaults.Index(ctx, name)
+	if err != nil {
+		return s.handleIndexError(ctx, err)
+	}
+	if err = index.DeleteIndex(ctx); err != nil {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+		s.log(ctx).Warn("Storage.DeleteTeam() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+
+	err = s.teams.DeleteAttr(ctx, name)
+	if err != nil && !errors.Is(err, itisadb.ErrNotFound) {
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return ErrUnavailable
+		}
+		s.log(ctx).Warn("Storage.DeleteTeam() failed", pkg.Err(err))
+		return ErrUnknown
+	}
+	return nil
+}
+
+// TeamsOf returns the names of the teams of username
+func (s *Storage) TeamsOf(ctx context.Context, username string) (_ []string, err error) {
+	defer s.observe("TeamsOf", time.Now(), &err)
+
+	if s.closed.Load() {
+		return nil, ErrUnavailable
+	}
+
+	return s.getMemberships(ctx, username)
+}
+
+// TeamGet returns value by key from the vault of team
+func (s *Storage) TeamGet(ctx context.Context, team, key string) (_ string, err error) {
+	defer s.observe("TeamGet", time.Now(), &err)
+
+	if s.closed.Load() {
+		return "", ErrUnavailable
+	}
+
+	return s.getSecret(ctx, "TeamGet", s.vaults, team, key)
+}
+
+// TeamSet adds k:v to the vault of team
+func (s *Storage) TeamSet(ctx context.Context, team, key, value string) (err error) {
+	defer s.observe("TeamSet", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	return s.setSecret(ctx, "TeamSet", s.vaults, team, key, value)
+}
+
+// TeamDelete deletes key from the vault of team
+func (s *Storage) TeamDelete(ctx context.Context, team, key string) (err error) {
+	defer s.observe("TeamDelete", time.Now(), &err)
+
+	if s.closed.Load() {
+		return ErrUnavailable
+	}
+
+	return s.deleteSecret(ctx, "TeamDelete", s.vaults, team, key)
+}
+
+// TeamGetAll returns all secrets in the vault of team
+func (s *Storage) TeamGetAll(ctx context.Context, team string) (_ map[string]string, err error) {
+	defer s.observe("TeamGetAll", time.Now(), &err)
+
+	if s.closed.Load() {
+		return nil, ErrUnavailable
+	}
+
+	return s.getAllSecrets(ctx, s.vaults, team)
+}
+
+func (s *Storage) getTeam(ctx context.Context, name string) (Team, error) {
+	val, err := s.teams.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, itisadb.ErrNotFound) {
+			return Team{}, ErrNotFound
+		}
+		if errors.Is(err, itisadb.ErrUnavailable) {
+			return Team{}, ErrUnavailable
+		}
+
+		s.log(ctx).Warn("Storage.getTeam() failed", pkg.Err(err))
+		return Team{}, ErrUnknown
+	}
+
+	var team Team
+	if err = json.Unmarshal([]byte(val), &team); err != nil {
+		s.log(ctx).Warn("Storage.getTeam() failed", pkg.Err(err))
+		return Team{}, ErrUnknown
+	}
+	return team, nil
+}
+
+func (s *Storage) getMemberships(ctx context.Context, username string) ([]string, error) {
+	vals, err := s.getAllSecrets(ctx, s.members, username)
+	if err != nil {
+		return nil, err
+	}
+
+	teams := make([]string, 0, len(vals))
+	for team := range vals {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+	return teams, nil
+}
+
+func (s *Storage) addMembership(ctx context.Context, username, team string) error {
+	return s.setSecret(ctx, "addMembership", s.members, username, team, team)
+}
+
+func (s *Storage) removeMembership(ctx context.Context, username, team string) error {
+	err := s.deleteSecret(ctx, "removeMembership", s.members, username, team)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
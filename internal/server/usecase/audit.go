@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/peer"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"secret-keeper/pkg"
+)
+
+// ErrAuditDisabled is returned when the audit log is not configured
+var ErrAuditDisabled = errors.New("audit log is disabled")
+
+// AuditLog returns up to limit most recent audit entries of the user
+func (u *UseCase) AuditLog(ctx context.Context, limit int) ([]audit.Entry, error) {
+	username, err := u.getUsernameFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if u.auditLog == nil {
+		return nil, ErrAuditDisabled
+	}
+
+	return u.auditLog.Entries(username, limit)
+}
+
+// AuditReady returns an error while the audit log cannot take entries.
+// Requests fail closed on it before they reach the usecase, see the audit
+// interceptors of the gRPC handler, so that no action runs while its
+// outcome cannot be recorded.
+func (u *UseCase) AuditReady() error {
+	if u.auditLog == nil {
+		return nil
+	}
+	if err := u.auditLog.Ready(); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	return nil
+}
+
+// record writes the outcome of an action to the audit log. It is deferred
+// by the actions, so username and err are read once the action is done.
+// The action has been carried out by then, so a failure to record it is
+// only logged and fails the requests after it through AuditReady.
+func (u *UseCase) record(ctx context.Context, action string, username *string, key string, err *error) {
+	u.recordEvent(ctx, audit.Event{User: *username, Action: action, Key: key}, err)
+}
+
+// recordEvent is record for events with more details than a key
+func (u *UseCase) recordEvent(ctx context.Context, e audit.Event, err *error) {
+	if u.auditLog == nil {
+		return
+	}
+
+	e.Outcome = outcome(e.User, *err)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		e.Peer = p.Addr.String()
+	}
+
+	if recErr := u.auditLog.Record(e); recErr != nil {
+		u.log(ctx).Error("failed to record audit event", pkg.String("action", e.Action), pkg.String("outcome", e.Outcome), pkg.Err(recErr))
+	}
+}
+
+// outcome describes the result of an action for the audit log
+func outcome(username string, err error) string {
+	switch {
+	case err == nil:
+		return audit.OutcomeOK
+	case errors.Is(err, ErrPermissionDenied):
+		return "permission_denied"
+	case errors.Is(err, validate.ErrInvalid):
+		return "invalid_argument"
+	case errors.Is(err, ErrInvalidPassphrase):
+		return "invalid_passphrase"
+	case username == "":
+		return "unauthenticated"
+	case errors.Is(err, ErrLocked):
+		return "locked"
+	case errors.Is(err, ErrQuotaExceeded):
+		return "quota_exceeded"
+	case errors.Is(err, ErrLastOwner):
+		return "last_owner"
+	case errors.Is(err, ErrInvalidPassword):
+		return "invalid_password"
+	case errors.Is(err, ErrTOTPRequired):
+		return "totp_required"
+	case errors.Is(err, ErrInvalidCode):
+		return "invalid_code"
+	case errors.Is(err, ErrInvalidToken):
+		return "invalid_token"
+	case errors.Is(err, storage.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return "already_exists"
+	case errors.Is(err, storage.ErrUnavailable):
+		return "unavailable"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/pkg"
+	"testing"
+	"time"
+)
+
+func TestUseCase_auditFailsClosed(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path, []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditLog.Close()
+	u := UseCase{storage: store, logger: pkg.NewNop(), auditLog: auditLog}
+
+	_, ctx := newTestUser(t, &u, "audit")
+
+	// the head cannot be replaced while a directory takes its temporary name
+	tmp := audit.HeadPath(path) + ".tmp"
+	if err = os.Mkdir(tmp, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	// the set is done before it is recorded, so it is not reported as failed
+	if err = u.Set(ctx, "db", "first", time.Time{}); err != nil {
+		t.Errorf("Set() error = %v, want nil although it was not recorded", err)
+	}
+	// the requests after it fail closed in the audit interceptors
+	if err = u.AuditReady(); err == nil {
+		t.Error("AuditReady() error = nil, want the audit log failure")
+	}
+
+	if err = os.Remove(tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.AuditReady(); err != nil {
+		t.Errorf("AuditReady() error = %v, want nil once the log can be written", err)
+	}
+	if got, err := u.Get(ctx, "db"); err != nil || got != "first" {
+		t.Errorf("Get() = %q, %v, want the value set while the audit log failed", got, err)
+	}
+}
+
+func Test_outcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		err      error
+		want     string
+	}{
+		{name: "ok", username: "alice", want: "ok"},
+		{name: "unauthenticated", err: fmt.Errorf("getFromContext: %w", ErrInvalidToken), want: "unauthenticated"},
+		{name: "invalidPassword", username: "alice", err: ErrInvalidPassword, want: "invalid_password"},
+		{name: "notFound", username: "alice", err: fmt.Errorf("get: %w", storage.ErrNotFound), want: "not_found"},
+		{name: "unavailable", username: "alice", err: storage.ErrUnavailable, want: "unavailable"},
+		{name: "other", username: "alice", err: errors.New("boom"), want: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outcome(tt.username, tt.err); got != tt.want {
+				t.Errorf("outcome() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/policy"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"secret-keeper/internal/server/watch"
+	"secret-keeper/pkg"
+	"time"
+)
+
+// MaxBatchSize limits the items of a batch
+const MaxBatchSize = 100
+
+// ErrBatchAborted is the result of the items of a transactional batch that
+// were not applied because another item failed
+var ErrBatchAborted = errors.New("batch aborted")
+
+// BatchItem is a secret to set in a batch. A zero ExpiresAt makes it never
+// expire.
+type BatchItem struct {
+	Key       string
+	Value     string
+	ExpiresAt time.Time
+}
+
+// BatchResult is the result of an item of a batch. Value is only set by
+// BatchGet.
+type BatchResult struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+// BatchGet gets the keys of the user. Every key has its own result, an
+// error is only returned when the batch as a whole is rejected.
+func (u *UseCase) BatchGet(ctx context.Context, keys []string) ([]BatchResult, error) {
+	if _, err := u.getUsernameFromContext(ctx); err != nil {
+		return nil, fmt.Errorf("getFromContext: %w", err)
+	}
+	if err := checkBatch(keys); err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(keys))
+	for i, key := range keys {
+		results[i].Key = key
+		results[i].Value, results[i].Err = u.Get(ctx, key)
+	}
+	return results, nil
+}
+
+// BatchDelete deletes the keys of the user. Every key has its own result,
+// an error is only returned when the batch as a whole is rejected.
+func (u *UseCase) BatchDelete(ctx context.Context, keys []string) ([]BatchResult, error) {
+	if _, err := u.getUsernameFromContext(ctx); err != nil {
+		return nil, fmt.Errorf("getFromContext: %w", err)
+	}
+	if err := checkBatch(keys); err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(keys))
+	for i, key := range keys {
+		results[i].Key = key
+		results[i].Err = u.Delete(ctx, key)
+	}
+	return results, nil
+}
+
+// BatchSet sets the items for the user. Every item has its own result, an
+// error is only returned when the batch as a whole is rejected.
+//
+// If transactional is set either every item is set or none: the items are
+// checked before any is set, and the items set already are restored when
+// one fails. Meanwhile concurrent writes to the same keys are not held off.
+func (u *UseCase) BatchSet(ctx context.Context, items []BatchItem, transactional bool) ([]BatchResult, error) {
+	username, err := u.getUsernameFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	if err = checkBatch(keys); err != nil {
+		return nil, err
+	}
+
+	if transactional {
+		return u.batchSetAll(ctx, username, items)
+	}
+
+	results := make([]BatchResult, len(items))
+	for i, item := range items {
+		results[i].Key = item.Key
+		results[i].Err = u.Set(ctx, item.Key, item.Value, item.ExpiresAt)
+	}
+	return results, nil
+}
+
+func (u *UseCase) batchSetAll(ctx context.Context, username string, items []BatchItem) (results []BatchResult, err error) {
+	results = make([]BatchResult, len(items))
+	for i, item := range items {
+		results[i].Key = item.Key
+	}
+	defer func() {
+		for i := range results {
+			u.record(ctx, audit.ActionSet, &username, results[i].Key, &results[i].Err)
+		}
+	}()
+
+	vault := storage.Vault{User: username}
+	secrets, err := u.storage.GetAll(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("GetAll: %w", err)
+	}
+	expiries, err := u.storage.Expiries(ctx, vault)
+	if err != nil {
+		return nil, fmt.Errorf("Expiries: %w", err)
+	}
+	fileBytes, err := u.fileBytes(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	// every item is checked before any is set, the quota against the
+	// secrets as they are after the items before it
+	after := make(map[string]string, len(secrets)+len(items))
+	for k, v := range secrets {
+		after[k] = v
+	}
+	var rejected bool
+	for i, item := range items {
+		err := u.checkBatchItem(ctx, username, item, after, fileBytes)
+		if err == nil {
+			after[item.Key] = item.Value
+		}
+		results[i].Err = err
+		rejected = rejected || err != nil
+	}
+	if rejected {
+		abortBatch(results)
+		return results, nil
+	}
+
+	for i, item := range items {
+		err := u.storage.SetExpiry(ctx, vault, item.Key, item.ExpiresAt)
+		if err == nil {
+			err = u.storage.Set(ctx, username, item.Key, item.Value)
+		}
+		if err != nil {
+			results[i].Err = err
+			u.rollbackBatch(ctx, username, items[:i+1], secrets, expiries)
+			abortBatch(results)
+			return results, nil
+		}
+	}
+
+	// watchers only learn about a batch that is applied as a whole
+	for _, item := range items {
+		change := watch.Created
+		if _, ok := secrets[item.Key]; ok {
+			change = watch.Updated
+		}
+		u.publish(username, item.Key, change)
+	}
+	return results, nil
+}
+
+// checkBatchItem checks that item may be set over secrets and fileBytes of
+// files
+func (u *UseCase) checkBatchItem(ctx context.Context, username string, item BatchItem, secrets map[string]string, fileBytes int) error {
+	if err := u.authorize(ctx, username, item.Key, policy.Write); err != nil {
+		return err
+	}
+	if err := u.validator.Secret(item.Key, item.Value); err != nil {
+		return err
+	}
+	if !item.ExpiresAt.IsZero() && !item.ExpiresAt.After(time.Now()) {
+		return &validate.Error{Violations: []validate.Violation{{Field: "expires_at", Description: "must be in the future"}}}
+	}
+	if u.quota == (Quota{}) {
+		return nil
+	}
+	return u.quotaAllows(secrets, fileBytes, item.Key, item.Value)
+}
+
+// rollbackBatch restores the items to their values and expiries before the
+// batch. Failures are logged, as the batch failed already.
+func (u *UseCase) rollbackBatch(ctx context.Context, username string, items []BatchItem, secrets map[string]string, expiries map[string]time.Time) {
+	vault := storage.Vault{User: username}
+	for i := len(items) - 1; i >= 0; i-- {
+		key := items[i].Key
+
+		var err error
+		if old, ok := secrets[key]; ok {
+			err = u.storage.Set(ctx, username, key, old)
+		} else if err = u.storage.Delete(ctx, username, key); errors.Is(err, storage.ErrNotFound) {
+			err = nil
+		}
+		if err == nil {
+			err = u.storage.SetExpiry(ctx, vault, key, expiries[key])
+		}
+		if err != nil {
+			u.log(ctx).Error("failed to roll back batch", pkg.String("key", key), pkg.Err(err))
+		}
+	}
+}
+
+// abortBatch marks the items of a failed transactional batch that did not
+// fail themselves
+func abortBatch(results []BatchResult) {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = ErrBatchAborted
+		}
+	}
+}
+
+// checkBatch checks the number of keys of a batch and that none repeats
+func checkBatch(keys []string) error {
+	if len(keys) == 0 || len(keys) > MaxBatchSize {
+		return &validate.Error{Violations: []validate.Violation{{Field: "items", Description: fmt.Sprintf("must be 1 to %d", MaxBatchSize)}}}
+	}
+
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			return &validate.Error{Violations: []validate.Violation{{Field: "items", Description: fmt.Sprintf("key %q repeats", key)}}}
+		}
+		seen[key] = struct{}{}
+	}
+	return nil
+}
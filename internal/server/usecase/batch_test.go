@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"errors"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"testing"
+	"time"
+)
+
+func TestUseCase_Batch(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store, quota: Quota{MaxSecrets: 3}}
+
+	_, ctx := newTestUser(t, &u, "batch")
+
+	if err = u.Set(ctx, "db", "old", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the third new secret exceeds the quota, so none is set
+	results, err := u.BatchSet(ctx, []BatchItem{
+		{Key: "db", Value: "new"},
+		{Key: "api", Value: "new"},
+		{Key: "cache", Value: "new"},
+		{Key: "queue", Value: "new"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantErrs := []error{ErrBatchAborted, ErrBatchAborted, ErrBatchAborted, ErrQuotaExceeded}
+	for i, r := range results {
+		if !errors.Is(r.Err, wantErrs[i]) {
+			t.Errorf("BatchSet() result %s error = %v, want %v", r.Key, r.Err, wantErrs[i])
+		}
+	}
+	if got, err := u.Get(ctx, "db"); err != nil || got != "old" {
+		t.Errorf("Get() = %v, %v, want the value before the aborted batch", got, err)
+	}
+	if _, err = u.Get(ctx, "api"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Get() error = %v, want %v after the aborted batch", err, storage.ErrNotFound)
+	}
+
+	// without a transaction the items within the quota are set
+	results, err = u.BatchSet(ctx, []BatchItem{
+		{Key: "db", Value: "new"},
+		{Key: "api", Value: "new"},
+		{Key: "cache", Value: "new"},
+		{Key: "queue", Value: "new"},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantErrs = []error{nil, nil, nil, ErrQuotaExceeded}
+	for i, r := range results {
+		if !errors.Is(r.Err, wantErrs[i]) {
+			t.Errorf("BatchSet() result %s error = %v, want %v", r.Key, r.Err, wantErrs[i])
+		}
+	}
+
+	results, err = u.BatchGet(ctx, []string{"db", "queue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Value != "new" || results[0].Err != nil || !errors.Is(results[1].Err, storage.ErrNotFound) {
+		t.Errorf("BatchGet() = %+v, want db and a missing queue", results)
+	}
+
+	results, err = u.BatchDelete(ctx, []string{"api", "queue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != nil || !errors.Is(results[1].Err, storage.ErrNotFound) {
+		t.Errorf("BatchDelete() = %+v, want api deleted and a missing queue", results)
+	}
+
+	for _, keys := range [][]string{nil, {"db", "db"}, make([]string, MaxBatchSize+1)} {
+		if _, err = u.BatchGet(ctx, keys); !errors.Is(err, validate.ErrInvalid) {
+			t.Errorf("BatchGet(%d keys) error = %v, want %v", len(keys), err, validate.ErrInvalid)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"secret-keeper/internal/server/watch"
+	"sort"
+	"time"
+)
+
+// SecretInfo describes a secret in a listing
+type SecretInfo struct {
+	Name string
+	// ExpiresAt is zero for secrets that never expire
+	ExpiresAt time.Time
+	// ExpiringSoon tells that the secret expires within the expiry warning
+	ExpiringSoon bool
+	// Tags are only filled in by ListSecrets
+	Tags []string
+}
+
+// WithExpiryWarning flags the secrets expiring within warning in listings
+func WithExpiryWarning(warning time.Duration) Option {
+	return func(u *UseCase) {
+		u.expiryWarning = warning
+	}
+}
+
+// SweepExpired deletes the expired secrets of every vault and the expired
+// share links and reports how many secrets it deleted. Until they are swept
+// expired secrets are only hidden.
+func (u *UseCase) SweepExpired(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	removed, err := u.storage.RemoveExpired(ctx, now, func(v storage.Vault, key string) (err error) {
+		defer u.recordEvent(ctx, audit.Event{User: v.User, Team: v.Team, Action: audit.ActionExpire, Key: key}, &err)
+		// the sweep is no request, so no interceptor checks the audit log
+		if err = u.AuditReady(); err != nil {
+			return err
+		}
+
+		if v.Team != "" {
+			err = u.storage.TeamDelete(ctx, v.Team, key)
+		} else if err = u.storage.Delete(ctx, v.User, key); err == nil {
+			u.publish(v.User, key, watch.Deleted)
+			_, err = u.storage.RemoveShares(ctx, v.User, "", func(sh storage.Share) bool {
+				return sh.Key != key
+			})
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return removed, fmt.Errorf("RemoveExpired: %w", err)
+	}
+
+	_, err = u.storage.RemoveLinks(ctx, func(l storage.Link) bool {
+		return now.Before(l.ExpiresAt)
+	})
+	if err != nil {
+		return removed, fmt.Errorf("RemoveLinks: %w", err)
+	}
+	return removed, nil
+}
+
+// setExpiry validates expiresAt and saves it for key of vault. It is saved
+// before the value, so that a sweep never deletes a value written with a
+// new expiry for the old one.
+func (u *UseCase) setExpiry(ctx context.Context, vault storage.Vault, key string, expiresAt time.Time) error {
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return &validate.Error{Violations: []validate.Violation{{Field: "expires_at", Description: "must be in the future"}}}
+	}
+
+	if err := u.storage.SetExpiry(ctx, vault, key, expiresAt); err != nil {
+		return fmt.Errorf("SetExpiry: %w", err)
+	}
+	return nil
+}
+
+// checkExpiry reports an expired key of vault as not found
+func (u *UseCase) checkExpiry(ctx context.Context, vault storage.Vault, key string) error {
+	at, err := u.storage.Expiry(ctx, vault, key)
+	if err != nil {
+		return fmt.Errorf("Expiry: %w", err)
+	}
+
+	if !at.IsZero() && !time.Now().Before(at) {
+		return fmt.Errorf("expired: %w", storage.ErrNotFound)
+	}
+	return nil
+}
+
+// secretInfos describes the names of vault, leaving out the expired ones,
+// sorted by name
+func (u *UseCase) secretInfos(ctx context.Context, vault storage.Vault, names []string) ([]SecretInfo, error) {
+	expiries, err := u.storage.Expiries(ctx, vault)
+	if err != nil {
+		return nil, fmt.Errorf("Expiries: %w", err)
+	}
+
+	now := time.Now()
+	infos := make([]SecretInfo, 0, len(names))
+	for _, name := range names {
+		at := expiries[name]
+		if !at.IsZero() && !now.Before(at) {
+			continue
+		}
+		infos = append(infos, SecretInfo{
+			Name:         name,
+			ExpiresAt:    at,
+			ExpiringSoon: !at.IsZero() && at.Sub(now) <= u.expiryWarning,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"testing"
+	"time"
+)
+
+func TestUseCase_expiry(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store, expiryWarning: time.Hour}
+
+	username, ctx := newTestUser(t, &u, "expiry")
+
+	if err = u.Set(ctx, "past", "secret", time.Now().Add(-time.Second)); !errors.Is(err, validate.ErrInvalid) {
+		t.Errorf("Set() error = %v, want %v for an expiry in the past", err, validate.ErrInvalid)
+	}
+
+	soon := time.Now().Add(100 * time.Millisecond)
+	for key, expiresAt := range map[string]time.Time{
+		"soon":    soon,
+		"later":   time.Now().Add(24 * time.Hour),
+		"never":   {},
+		"revived": soon,
+	} {
+		if err = u.Set(ctx, key, "secret", expiresAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// setting it again without an expiry makes it never expire
+	if err = u.Set(ctx, "revived", "secret", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := u.GetAllNames(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SecretInfo{
+		{Name: "later", ExpiresAt: time.Now().Add(24 * time.Hour)},
+		{Name: "never"},
+		{Name: "revived"},
+		{Name: "soon", ExpiresAt: soon, ExpiringSoon: true},
+	}
+	if len(infos) != len(want) {
+		t.Fatalf("GetAllNames() = %+v, want %+v", infos, want)
+	}
+	for i := range want {
+		got := infos[i]
+		if got.Name != want[i].Name || got.ExpiresAt.IsZero() != want[i].ExpiresAt.IsZero() || got.ExpiringSoon != want[i].ExpiringSoon {
+			t.Errorf("GetAllNames()[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	time.Sleep(time.Until(soon))
+
+	if _, err = u.Get(ctx, "soon"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Get() error = %v, want %v once expired", err, storage.ErrNotFound)
+	}
+	if _, err = u.Get(ctx, "revived"); err != nil {
+		t.Errorf("Get() error = %v for a secret set again without expiry", err)
+	}
+	if infos, err = u.GetAllNames(ctx); err != nil || len(infos) != 3 {
+		t.Errorf("GetAllNames() = %+v, %v, want the expired secret left out", infos, err)
+	}
+
+	if _, err = u.SweepExpired(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Get(ctx, username, "soon"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("storage Get() error = %v, want %v once swept", err, storage.ErrNotFound)
+	}
+	if _, err = store.Get(ctx, username, "later"); err != nil {
+		t.Errorf("storage Get() error = %v for a secret not expired yet", err)
+	}
+}
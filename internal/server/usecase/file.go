@@ -0,0 +1,239 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/policy"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"secret-keeper/pkg"
+	"sort"
+	"time"
+)
+
+// ErrChecksumMismatch is returned when the content of a file does not match
+// its SHA-256
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrFileChanged is returned when a file is replaced or deleted while it is
+// downloaded
+var ErrFileChanged = errors.New("file changed during download")
+
+// uploadCleanupTimeout bounds deleting the chunks of a failed upload
+const uploadCleanupTimeout = 10 * time.Second
+
+// FileInfo describes a file
+type FileInfo struct {
+	Key    string
+	Size   int64
+	SHA256 string
+}
+
+// WithMaxFileSize limits the size of a file in bytes, zero means no limit
+func WithMaxFileSize(size int64) Option {
+	return func(u *UseCase) {
+		u.maxFileSize = size
+	}
+}
+
+// Upload stores the chunks returned by next until io.EOF as the file key of
+// the user, replacing it at once when every chunk is stored. If sum is set
+// it is the hex SHA-256 the content has to match.
+func (u *UseCase) Upload(ctx context.Context, key, sum string, next func() ([]byte, error)) (_ FileInfo, err error) {
+	var username string
+	defer u.record(ctx, audit.ActionUpload, &username, key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if err = u.validator.Key(key); err != nil {
+		return FileInfo{}, err
+	}
+
+	if err = u.authorize(ctx, username, key, policy.Write); err != nil {
+		return FileInfo{}, err
+	}
+
+	allowance, err := u.fileAllowance(ctx, username, key)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	blob, err := generateBlobName()
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("generateBlobName: %w", err)
+	}
+
+	file := storage.File{Blob: blob}
+	// written counts the chunks that may be stored, a failed PutChunk
+	// included
+	var written int
+	defer func() {
+		if err == nil || written == 0 {
+			return
+		}
+
+		// the upload may have failed because the request was cancelled, so
+		// its context cannot be used
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), uploadCleanupTimeout)
+		defer cancel()
+
+		if cleanupErr := u.storage.DeleteChunks(cleanupCtx, username, blob, written); cleanupErr != nil {
+			u.log(ctx).Warn("failed to delete the chunks of a failed upload", pkg.Err(cleanupErr))
+		}
+	}()
+
+	hash := sha256.New()
+	for {
+		chunk, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return FileInfo{}, err
+		}
+		if len(chunk) == 0 {
+			continue
+		}
+
+		file.Size += int64(len(chunk))
+		if u.maxFileSize > 0 && file.Size > u.maxFileSize {
+			return FileInfo{}, &validate.Error{Violations: []validate.Violation{{Field: "file", Description: fmt.Sprintf("must be at most %d bytes", u.maxFileSize)}}}
+		}
+		if allowance >= 0 && file.Size > allowance {
+			return FileInfo{}, &QuotaError{Subject: "bytes", Limit: u.quota.MaxBytes}
+		}
+		hash.Write(chunk)
+
+		written++
+		if err = u.storage.PutChunk(ctx, username, blob, file.Chunks, chunk); err != nil {
+			return FileInfo{}, fmt.Errorf("PutChunk: %w", err)
+		}
+		file.Chunks++
+	}
+
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if sum != "" && sum != file.SHA256 {
+		return FileInfo{}, ErrChecksumMismatch
+	}
+
+	if err = u.storage.CommitFile(ctx, username, key, file); err != nil {
+		return FileInfo{}, fmt.Errorf("CommitFile: %w", err)
+	}
+	return FileInfo{Key: key, Size: file.Size, SHA256: file.SHA256}, nil
+}
+
+// Download calls start with the file key of the user and then send with
+// every chunk of its content. The content is checked against its SHA-256
+// before the last chunk is sent.
+func (u *UseCase) Download(ctx context.Context, key string, start func(FileInfo) error, send func([]byte) error) (err error) {
+	var username string
+	defer u.record(ctx, audit.ActionDownload, &username, key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if err = u.authorize(ctx, username, key, policy.Read); err != nil {
+		return err
+	}
+
+	file, err := u.storage.GetFile(ctx, username, key)
+	if err != nil {
+		return fmt.Errorf("GetFile: %w", err)
+	}
+
+	if err = start(FileInfo{Key: key, Size: file.Size, SHA256: file.SHA256}); err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	for n := 0; n < file.Chunks; n++ {
+		chunk, err := u.storage.GetChunk(ctx, username, file.Blob, n)
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrFileChanged
+		}
+		if err != nil {
+			return fmt.Errorf("GetChunk: %w", err)
+		}
+
+		hash.Write(chunk)
+		if n == file.Chunks-1 && hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+			return ErrChecksumMismatch
+		}
+
+		if err = send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListFiles returns the files of the user sorted by key
+func (u *UseCase) ListFiles(ctx context.Context) ([]FileInfo, error) {
+	username, err := u.getUsernameFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	files, err := u.storage.ListFiles(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("ListFiles: %w", err)
+	}
+
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	keys, err = u.filterNames(ctx, username, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]FileInfo, 0, len(keys))
+	for _, key := range keys {
+		file := files[key]
+		infos = append(infos, FileInfo{Key: key, Size: file.Size, SHA256: file.SHA256})
+	}
+	return infos, nil
+}
+
+// DeleteFile deletes the file key of the user
+func (u *UseCase) DeleteFile(ctx context.Context, key string) (err error) {
+	var username string
+	defer u.record(ctx, audit.ActionDeleteFile, &username, key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if err = u.authorize(ctx, username, key, policy.Delete); err != nil {
+		return err
+	}
+
+	if err = u.storage.DeleteFile(ctx, username, key); err != nil {
+		return fmt.Errorf("DeleteFile: %w", err)
+	}
+	return nil
+}
+
+// generateBlobName returns a random name for the chunks of an upload
+func generateBlobName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/egorgasay/itisadb-go-sdk"
+	"io"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"testing"
+	"time"
+)
+
+// chunks returns a next func handing out content in chunks of size
+func chunks(content []byte, size int) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		if len(content) == 0 {
+			return nil, io.EOF
+		}
+		n := size
+		if n > len(content) {
+			n = len(content)
+		}
+		chunk := content[:n]
+		content = content[n:]
+		return chunk, nil
+	}
+}
+
+func TestUseCase_Upload(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store, maxFileSize: 1 << 20}
+
+	_, ctx := newTestUser(t, &u, "file")
+
+	download := func(key string) ([]byte, FileInfo, error) {
+		var content bytes.Buffer
+		var info FileInfo
+		err := u.Download(ctx, key, func(i FileInfo) error {
+			info = i
+			return nil
+		}, func(chunk []byte) error {
+			content.Write(chunk)
+			return nil
+		})
+		return content.Bytes(), info, err
+	}
+
+	content := bytes.Repeat([]byte("\x00\xffkeystore"), 1000)
+	sum := sha256.Sum256(content)
+
+	info, err := u.Upload(ctx, "keystore.jks", hex.EncodeToString(sum[:]), chunks(content, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(content)) || info.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("Upload() = %+v, want %d bytes", info, len(content))
+	}
+
+	got, info, err := download("keystore.jks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) || info.Size != int64(len(content)) {
+		t.Errorf("Download() got %d bytes, want %d", len(got), len(content))
+	}
+
+	// a failed upload leaves the file as it was
+	if _, err = u.Upload(ctx, "keystore.jks", hex.EncodeToString(make([]byte, 32)), chunks([]byte("other"), 2)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Upload() error = %v, want %v", err, ErrChecksumMismatch)
+	}
+	if _, err = u.Upload(ctx, "keystore.jks", "", chunks(make([]byte, 1<<20+1), 1<<16)); !errors.Is(err, validate.ErrInvalid) {
+		t.Errorf("Upload() error = %v, want %v for a file too large", err, validate.ErrInvalid)
+	}
+	if got, _, err = download("keystore.jks"); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("Download() = %d bytes, %v after failed uploads, want the first file", len(got), err)
+	}
+
+	if _, err = u.Upload(ctx, "keystore.jks", "", chunks([]byte("replaced"), 3)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err = download("keystore.jks"); err != nil || string(got) != "replaced" {
+		t.Errorf("Download() = %q, %v, want the replaced file", got, err)
+	}
+	if infos, err := u.ListFiles(ctx); err != nil || len(infos) != 1 || infos[0].Key != "keystore.jks" || infos[0].Size != int64(len("replaced")) {
+		t.Errorf("ListFiles() = %+v, %v, want the replaced file", infos, err)
+	}
+
+	if err = u.DeleteFile(ctx, "keystore.jks"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = download("keystore.jks"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Download() error = %v, want %v once deleted", err, storage.ErrNotFound)
+	}
+}
+
+func TestUseCase_Upload_cancelled(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	username, ctx := newTestUser(t, &u, "file")
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the client goes away after two chunks
+	next := chunks([]byte("abcdef"), 3)
+	var sent int
+	_, err = u.Upload(ctx, "aborted.bin", "", func() ([]byte, error) {
+		if sent == 2 {
+			cancel()
+			return nil, ctx.Err()
+		}
+		sent++
+		return next()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Upload() error = %v, want %v", err, context.Canceled)
+	}
+
+	db, err := itisadb.New(":800")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := db.Index(context.Background(), "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := blobs.Index(context.Background(), username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, err := index.GetIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 0 {
+		t.Errorf("%d chunks left after a cancelled upload, want none", len(left))
+	}
+}
+
+func TestUseCase_Upload_quota(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store, quota: Quota{MaxBytes: 100}}
+
+	_, ctx := newTestUser(t, &u, "file-quota")
+
+	// 4 bytes of the key and 60 of the content
+	if _, err = u.Upload(ctx, "cert", "", chunks(make([]byte, 60), 16)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = u.Upload(ctx, "key", "", chunks(make([]byte, 40), 16)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Upload() error = %v, want %v", err, ErrQuotaExceeded)
+	}
+	// a replaced file does not count
+	if _, err = u.Upload(ctx, "cert", "", chunks(make([]byte, 90), 16)); err != nil {
+		t.Errorf("Upload() error = %v, want the file replaced", err)
+	}
+	if err = u.Set(ctx, "db", "0123456789", time.Time{}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Set() error = %v, want %v with the quota taken by files", err, ErrQuotaExceeded)
+	}
+
+	usage, err := u.GetUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Files != 1 || usage.Bytes != 94 {
+		t.Errorf("GetUsage() = %+v, want 1 file of 94 bytes", usage)
+	}
+}
@@ -0,0 +1,211 @@
+package usecase
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/policy"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"secret-keeper/pkg"
+	"time"
+)
+
+// Limits of share links
+const (
+	DefaultLinkTTL = 24 * time.Hour
+	MaxLinkTTL     = 7 * 24 * time.Hour
+	MaxLinkViews   = 100
+)
+
+// ErrInvalidPassphrase is returned when a share link is redeemed with a
+// wrong or without its passphrase
+var ErrInvalidPassphrase = errors.New("invalid passphrase")
+
+// ShareLink is a created share link
+type ShareLink struct {
+	Token     string
+	ExpiresAt time.Time
+	MaxViews  int
+}
+
+// RedeemedLink is the secret behind a share link
+type RedeemedLink struct {
+	Owner     string
+	Key       string
+	Value     string
+	ViewsLeft int
+}
+
+// CreateShareLink snapshots the key of the user into a link that can be
+// redeemed without an account up to maxViews times until ttl passes. A zero
+// ttl or maxViews means a day or a single view. Later changes to the secret
+// do not reach the link.
+func (u *UseCase) CreateShareLink(ctx context.Context, key string, ttl time.Duration, maxViews int, passphrase string) (_ ShareLink, err error) {
+	var username string
+	defer u.record(ctx, audit.ActionCreateShareLink, &username, key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return ShareLink{}, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if ttl == 0 {
+		ttl = DefaultLinkTTL
+	}
+	if maxViews == 0 {
+		maxViews = 1
+	}
+	var violations []validate.Violation
+	if ttl < 0 || ttl > MaxLinkTTL {
+		violations = append(violations, validate.Violation{Field: "ttl", Description: fmt.Sprintf("must be positive and at most %v", MaxLinkTTL)})
+	}
+	if maxViews < 0 || maxViews > MaxLinkViews {
+		violations = append(violations, validate.Violation{Field: "max_views", Description: fmt.Sprintf("must be positive and at most %d", MaxLinkViews)})
+	}
+	if len(violations) != 0 {
+		return ShareLink{}, &validate.Error{Violations: violations}
+	}
+
+	if err = u.authorize(ctx, username, key, policy.Share); err != nil {
+		return ShareLink{}, err
+	}
+
+	if err = u.checkExpiry(ctx, storage.Vault{User: username}, key); err != nil {
+		return ShareLink{}, fmt.Errorf("get: %w", err)
+	}
+
+	val, err := u.storage.Get(ctx, username, key)
+	if err != nil {
+		return ShareLink{}, fmt.Errorf("get: %w", err)
+	}
+
+	token, err := generateLinkToken()
+	if err != nil {
+		return ShareLink{}, fmt.Errorf("generateLinkToken: %w", err)
+	}
+
+	sealed, err := sealLink(token, passphrase, val)
+	if err != nil {
+		return ShareLink{}, fmt.Errorf("sealLink: %w", err)
+	}
+
+	link := storage.Link{
+		Owner:      username,
+		Key:        key,
+		Sealed:     sealed,
+		Passphrase: passphrase != "",
+		ExpiresAt:  time.Now().Add(ttl).UTC(),
+		ViewsLeft:  maxViews,
+	}
+	if err = u.storage.AddLink(ctx, linkID(token), link); err != nil {
+		return ShareLink{}, fmt.Errorf("AddLink: %w", err)
+	}
+
+	return ShareLink{Token: token, ExpiresAt: link.ExpiresAt, MaxViews: maxViews}, nil
+}
+
+// RedeemShareLink returns the secret behind the link with token and uses
+// up one of its views. It needs no account, so failures are counted by the
+// limiter against the peer IP.
+func (u *UseCase) RedeemShareLink(ctx context.Context, token, passphrase string) (_ RedeemedLink, err error) {
+	var link storage.Link
+	defer func() {
+		u.recordEvent(ctx, audit.Event{Action: audit.ActionRedeemShareLink, Key: link.Key, Owner: link.Owner}, &err)
+	}()
+
+	var keys []string
+	if ip := peerIP(ctx); ip != "" {
+		keys = append(keys, peerKey(ip))
+	}
+	if wait := u.limiter.Wait(keys...); wait > 0 {
+		return RedeemedLink{}, &LockedError{RetryAfter: wait}
+	}
+
+	var value string
+	link, err = u.storage.RedeemLink(ctx, linkID(token), time.Now(), func(l storage.Link) error {
+		if l.Passphrase != (passphrase != "") {
+			return ErrInvalidPassphrase
+		}
+
+		var openErr error
+		value, openErr = openLink(token, passphrase, l.Sealed)
+		return openErr
+	})
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, ErrInvalidPassphrase) {
+		wait := u.limiter.Fail(keys...)
+		u.log(ctx).Warn("share link redemption failed", pkg.Err(err), pkg.Duration("retry_after", wait))
+	}
+	if err != nil {
+		return RedeemedLink{}, fmt.Errorf("RedeemLink: %w", err)
+	}
+
+	return RedeemedLink{Owner: link.Owner, Key: link.Key, Value: value, ViewsLeft: link.ViewsLeft - 1}, nil
+}
+
+// generateLinkToken returns 256 random bits, as the token alone gives the
+// secret away
+func generateLinkToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// linkID is what a link is stored by, so that the tokens are not stored
+func linkID(token string) string {
+	sum := sha256.Sum256([]byte("id\x00" + token))
+	return hex.EncodeToString(sum[:])
+}
+
+func linkCipher(token, passphrase string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("seal\x00" + token + "\x00" + passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealLink encrypts value with a key derived from the token and the
+// passphrase
+func sealLink(token, passphrase, value string) (string, error) {
+	aead, err := linkCipher(token, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+// openLink decrypts what sealLink returned, failing with
+// ErrInvalidPassphrase when the passphrase does not match
+func openLink(token, passphrase, sealed string) (string, error) {
+	aead, err := linkCipher(token, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(b) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed link: %w", storage.ErrUnknown)
+	}
+
+	value, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrInvalidPassphrase
+	}
+	return string(value), nil
+}
@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"testing"
+	"time"
+)
+
+func TestUseCase_ShareLink(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	username, ctx := newTestUser(t, &u, "link")
+	anonymous := context.Background()
+
+	if err = u.Set(ctx, "db", "secret", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	createTests := []struct {
+		name     string
+		key      string
+		ttl      time.Duration
+		maxViews int
+		wantErr  error
+	}{
+		{name: "unknownKey", key: "nope", wantErr: storage.ErrNotFound},
+		{name: "negativeTTL", key: "db", ttl: -time.Second, wantErr: validate.ErrInvalid},
+		{name: "longTTL", key: "db", ttl: MaxLinkTTL + time.Second, wantErr: validate.ErrInvalid},
+		{name: "tooManyViews", key: "db", maxViews: MaxLinkViews + 1, wantErr: validate.ErrInvalid},
+	}
+	for _, tt := range createTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := u.CreateShareLink(ctx, tt.key, tt.ttl, tt.maxViews, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateShareLink() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("oneTime", func(t *testing.T) {
+		link, err := u.CreateShareLink(ctx, "db", 0, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if link.MaxViews != 1 {
+			t.Errorf("MaxViews = %d, want 1", link.MaxViews)
+		}
+
+		// the link is a snapshot
+		if err = u.Set(ctx, "db", "changed", time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { u.Set(ctx, "db", "secret", time.Time{}) })
+
+		got, err := u.RedeemShareLink(anonymous, link.Token, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Value != "secret" || got.Owner != username || got.Key != "db" || got.ViewsLeft != 0 {
+			t.Errorf("RedeemShareLink() = %+v", got)
+		}
+
+		if _, err = u.RedeemShareLink(anonymous, link.Token, ""); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("RedeemShareLink() error = %v, want %v the second time", err, storage.ErrNotFound)
+		}
+	})
+
+	t.Run("passphrase", func(t *testing.T) {
+		link, err := u.CreateShareLink(ctx, "db", time.Hour, 2, "open sesame")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, passphrase := range []string{"", "wrong"} {
+			if _, err = u.RedeemShareLink(anonymous, link.Token, passphrase); !errors.Is(err, ErrInvalidPassphrase) {
+				t.Errorf("RedeemShareLink(%q) error = %v, want %v", passphrase, err, ErrInvalidPassphrase)
+			}
+		}
+
+		// failed attempts do not use up views
+		for want := 1; want >= 0; want-- {
+			got, err := u.RedeemShareLink(anonymous, link.Token, "open sesame")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Value != "secret" || got.ViewsLeft != want {
+				t.Errorf("RedeemShareLink() = %+v, want %d views left", got, want)
+			}
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		link, err := u.CreateShareLink(ctx, "db", time.Minute, 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		open := func(storage.Link) error { return nil }
+		if _, err = store.RedeemLink(anonymous, linkID(link.Token), time.Now().Add(time.Hour), open); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("RedeemLink() error = %v, want %v once expired", err, storage.ErrNotFound)
+		}
+		if _, err = u.RedeemShareLink(anonymous, link.Token, ""); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("RedeemShareLink() error = %v, want %v for an expired link", err, storage.ErrNotFound)
+		}
+	})
+
+	t.Run("deleteAccount", func(t *testing.T) {
+		link, err := u.CreateShareLink(ctx, "db", 0, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = u.DeleteAccount(ctx, "password", ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = u.RedeemShareLink(anonymous, link.Token, ""); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("RedeemShareLink() error = %v, want %v after the account was deleted", err, storage.ErrNotFound)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/policy"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits of listings and tags
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+	MaxTags         = 16
+	MaxTagLength    = 64
+)
+
+// ListOptions select the secrets of a listing
+type ListOptions struct {
+	// Prefix keeps the secrets whose name starts with it
+	Prefix string
+	// Tags keeps the secrets having every one of them
+	Tags []string
+	// PageSize is DefaultPageSize if zero
+	PageSize int
+	// PageToken is the NextPageToken of the previous page
+	PageToken string
+}
+
+// SecretPage is a page of a listing
+type SecretPage struct {
+	Secrets []SecretInfo
+	// NextPageToken is empty on the last page
+	NextPageToken string
+}
+
+// ListSecrets returns a page of the secrets of the user sorted by name.
+// Pages continue after the last name of the previous one, so secrets set or
+// deleted meanwhile never shift a page.
+func (u *UseCase) ListSecrets(ctx context.Context, opts ListOptions) (SecretPage, error) {
+	username, err := u.getUsernameFromContext(ctx)
+	if err != nil {
+		return SecretPage{}, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if opts.PageSize == 0 {
+		opts.PageSize = DefaultPageSize
+	}
+	var violations []validate.Violation
+	if opts.PageSize < 0 || opts.PageSize > MaxPageSize {
+		violations = append(violations, validate.Violation{Field: "page_size", Description: fmt.Sprintf("must be positive and at most %d", MaxPageSize)})
+	}
+	after, err := base64.RawURLEncoding.DecodeString(opts.PageToken)
+	if err != nil {
+		violations = append(violations, validate.Violation{Field: "page_token", Description: "is not a token of a previous page"})
+	}
+	if len(violations) != 0 {
+		return SecretPage{}, &validate.Error{Violations: violations}
+	}
+
+	entries, err := u.storage.List(ctx, username)
+	if err != nil {
+		return SecretPage{}, err
+	}
+
+	tags := make(map[string][]string, len(entries))
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if (len(after) != 0 && e.Name <= string(after)) || !strings.HasPrefix(e.Name, opts.Prefix) || !hasTags(e.Tags, opts.Tags) {
+			continue
+		}
+		tags[e.Name] = e.Tags
+		names = append(names, e.Name)
+	}
+
+	if names, err = u.filterNames(ctx, username, names); err != nil {
+		return SecretPage{}, err
+	}
+	infos, err := u.secretInfos(ctx, storage.Vault{User: username}, names)
+	if err != nil {
+		return SecretPage{}, err
+	}
+
+	var page SecretPage
+	if len(infos) > opts.PageSize {
+		infos = infos[:opts.PageSize]
+		page.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(infos[len(infos)-1].Name))
+	}
+	for i := range infos {
+		infos[i].Tags = tags[infos[i].Name]
+	}
+	page.Secrets = infos
+	return page, nil
+}
+
+// SetTags replaces the tags of key of the user
+func (u *UseCase) SetTags(ctx context.Context, key string, tags []string) (err error) {
+	var username string
+	defer u.record(ctx, audit.ActionSetTags, &username, key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if tags, err = normalizeTags(tags); err != nil {
+		return err
+	}
+
+	if err = u.authorize(ctx, username, key, policy.Write); err != nil {
+		return err
+	}
+
+	if err = u.checkExpiry(ctx, storage.Vault{User: username}, key); err != nil {
+		return fmt.Errorf("SetTags: %w", err)
+	}
+
+	if err = u.storage.SetTags(ctx, username, key, tags); err != nil {
+		return fmt.Errorf("SetTags: %w", err)
+	}
+	return nil
+}
+
+// normalizeTags checks tags and returns them sorted without duplicates
+func normalizeTags(tags []string) ([]string, error) {
+	var violations []validate.Violation
+	if len(tags) > MaxTags {
+		violations = append(violations, validate.Violation{Field: "tags", Description: fmt.Sprintf("must be at most %d", MaxTags)})
+	}
+
+	seen := make(map[string]struct{}, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength || strings.TrimSpace(tag) != tag {
+			violations = append(violations, validate.Violation{Field: "tags", Description: fmt.Sprintf("%q must be 1 to %d characters without surrounding spaces", tag, MaxTagLength)})
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		normalized = append(normalized, tag)
+	}
+	if len(violations) != 0 {
+		return nil, &validate.Error{Violations: violations}
+	}
+
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// hasTags tells whether tags contains every one of want
+func hasTags(tags, want []string) bool {
+	for _, w := range want {
+		var found bool
+		for _, tag := range tags {
+			if tag == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"errors"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"testing"
+	"time"
+)
+
+func TestUseCase_ListSecrets(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	_, ctx := newTestUser(t, &u, "list")
+
+	for _, key := range []string{"prod/db", "dev/db", "prod/api", "prod/cache", "dev/api"} {
+		if err = u.Set(ctx, key, "secret", time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for key, tags := range map[string][]string{
+		"prod/db":  {"db", "critical", "db"},
+		"dev/db":   {"db"},
+		"prod/api": {"critical"},
+	} {
+		if err = u.SetTags(ctx, key, tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts ListOptions
+		want []string
+	}{
+		{name: "all", want: []string{"dev/api", "dev/db", "prod/api", "prod/cache", "prod/db"}},
+		{name: "prefix", opts: ListOptions{Prefix: "prod/"}, want: []string{"prod/api", "prod/cache", "prod/db"}},
+		{name: "tag", opts: ListOptions{Tags: []string{"db"}}, want: []string{"dev/db", "prod/db"}},
+		{name: "everyTag", opts: ListOptions{Tags: []string{"db", "critical"}}, want: []string{"prod/db"}},
+		{name: "prefixAndTag", opts: ListOptions{Prefix: "prod/", Tags: []string{"critical"}}, want: []string{"prod/api", "prod/db"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := u.ListSecrets(ctx, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.NextPageToken != "" || len(page.Secrets) != len(tt.want) {
+				t.Fatalf("ListSecrets() = %+v, want %v", page, tt.want)
+			}
+			for i, info := range page.Secrets {
+				if info.Name != tt.want[i] {
+					t.Errorf("ListSecrets()[%d] = %s, want %s", i, info.Name, tt.want[i])
+				}
+			}
+		})
+	}
+
+	// pages continue after the last name even if secrets change meanwhile
+	page, err := u.ListSecrets(ctx, ListOptions{PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Secrets) != 2 || page.Secrets[1].Name != "dev/db" || page.NextPageToken == "" {
+		t.Fatalf("ListSecrets() = %+v, want dev/api, dev/db and a next page", page)
+	}
+	if err = u.Delete(ctx, "dev/api"); err != nil {
+		t.Fatal(err)
+	}
+	page, err = u.ListSecrets(ctx, ListOptions{PageSize: 2, PageToken: page.NextPageToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Secrets) != 2 || page.Secrets[0].Name != "prod/api" || page.NextPageToken == "" {
+		t.Fatalf("ListSecrets() = %+v, want prod/api, prod/cache and a next page", page)
+	}
+	if got := page.Secrets[0].Tags; len(got) != 1 || got[0] != "critical" {
+		t.Errorf("ListSecrets() tags = %v, want [critical]", got)
+	}
+	page, err = u.ListSecrets(ctx, ListOptions{PageSize: 2, PageToken: page.NextPageToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Secrets) != 1 || page.Secrets[0].Name != "prod/db" || page.NextPageToken != "" {
+		t.Errorf("ListSecrets() = %+v, want prod/db on the last page", page)
+	}
+	if got := page.Secrets[0].Tags; len(got) != 2 || got[0] != "critical" || got[1] != "db" {
+		t.Errorf("ListSecrets() tags = %v, want [critical db]", got)
+	}
+
+	for _, opts := range []ListOptions{{PageSize: -1}, {PageSize: MaxPageSize + 1}, {PageToken: "not a token!"}} {
+		if _, err = u.ListSecrets(ctx, opts); !errors.Is(err, validate.ErrInvalid) {
+			t.Errorf("ListSecrets(%+v) error = %v, want %v", opts, err, validate.ErrInvalid)
+		}
+	}
+	if err = u.SetTags(ctx, "prod/db", []string{" padded"}); !errors.Is(err, validate.ErrInvalid) {
+		t.Errorf("SetTags() error = %v, want %v", err, validate.ErrInvalid)
+	}
+	if err = u.SetTags(ctx, "missing", []string{"db"}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("SetTags() error = %v, want %v", err, storage.ErrNotFound)
+	}
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"secret-keeper/internal/server/audit"
+	"time"
+)
+
+// ErrLocked is returned when there were too many failed logins
+var ErrLocked = errors.New("too many failed login attempts")
+
+// ErrPermissionDenied is returned when an admin call is made without the
+// admin token
+var ErrPermissionDenied = errors.New("permission denied")
+
+// adminTokenKey is the metadata key holding the admin token
+const adminTokenKey = "admin-token"
+
+// LockedError tells how long to wait before the next login
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", ErrLocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// Unlock clears the failed logins of the username and the peer IP, any of
+// which may be empty. It reports whether there were failed logins to clear.
+func (u *UseCase) Unlock(ctx context.Context, username, peerIP string) (unlocked bool, err error) {
+	defer u.record(ctx, audit.ActionUnlock, &username, "", &err)
+
+	if !u.isAdmin(ctx) {
+		return false, ErrPermissionDenied
+	}
+
+	if username != "" && u.limiter.Reset(userKey(username)) {
+		unlocked = true
+	}
+	if peerIP != "" && u.limiter.Reset(peerKey(peerIP)) {
+		unlocked = true
+	}
+
+	return unlocked, nil
+}
+
+// isAdmin reports whether ctx carries the admin token
+func (u *UseCase) isAdmin(ctx context.Context) bool {
+	if u.adminToken == "" {
+		return false
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	values := md.Get(adminTokenKey)
+	if len(values) == 0 {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(values[0]), []byte(u.adminToken)) == 1
+}
+
+// loginKeys returns the limiter keys of a login attempt
+func loginKeys(ctx context.Context, username string) []string {
+	keys := []string{userKey(username)}
+	if ip := peerIP(ctx); ip != "" {
+		keys = append(keys, peerKey(ip))
+	}
+	return keys
+}
+
+func userKey(username string) string {
+	return "user:" + username
+}
+
+func peerKey(ip string) string {
+	return "peer:" + ip
+}
+
+// peerIP returns the IP address of the caller
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	switch addr := p.Addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP.String()
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return addr.String()
+		}
+		return host
+	}
+}
@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/metadata"
+	"secret-keeper/internal/server/lockout"
+	"secret-keeper/internal/server/storage"
+	"testing"
+	"time"
+)
+
+func TestUseCase_AuthLockout(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := UseCase{
+		storage:    store,
+		limiter:    lockout.New(lockout.Config{MaxAttempts: 2, Backoff: time.Nanosecond, Lockout: time.Hour}),
+		adminToken: "admin",
+	}
+
+	ctx := setHeader(context.Background())
+	if _, err = u.Register(ctx, "lockout-user", "lockout-pass"); err != nil && !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		time.Sleep(time.Millisecond)
+		if _, err = u.Auth(ctx, "lockout-user", "wrong", ""); !errors.Is(err, ErrInvalidPassword) {
+			t.Fatalf("Auth() error = %v, want %v", err, ErrInvalidPassword)
+		}
+	}
+
+	var locked *LockedError
+	if _, err = u.Auth(ctx, "lockout-user", "lockout-pass", ""); !errors.As(err, &locked) {
+		t.Fatalf("Auth() error = %v, want %v", err, ErrLocked)
+	}
+	if locked.RetryAfter <= 0 || locked.RetryAfter > time.Hour {
+		t.Errorf("RetryAfter = %v, want up to %v", locked.RetryAfter, time.Hour)
+	}
+
+	if _, err = u.Unlock(ctx, "lockout-user", ""); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("Unlock() error = %v, want %v", err, ErrPermissionDenied)
+	}
+
+	adminCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(adminTokenKey, "admin"))
+	unlocked, err := u.Unlock(adminCtx, "lockout-user", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !unlocked {
+		t.Error("Unlock() = false, want true")
+	}
+
+	if _, err = u.Auth(ctx, "lockout-user", "lockout-pass", ""); err != nil {
+		t.Errorf("Auth() error = %v after Unlock", err)
+	}
+}
@@ -0,0 +1,305 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/policy"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"sort"
+	"sync"
+)
+
+// PutPolicy creates or replaces a policy. It requires the admin token.
+func (u *UseCase) PutPolicy(ctx context.Context, p policy.Policy) (err error) {
+	defer u.recordPolicy(ctx, audit.ActionPutPolicy, p.Name, &err)
+
+	if !u.isAdmin(ctx) {
+		return ErrPermissionDenied
+	}
+
+	if err = p.Validate(); err != nil {
+		return err
+	}
+	if err = u.checkPolicySubjects(ctx, p); err != nil {
+		return err
+	}
+
+	doc, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return u.storage.SetPolicy(ctx, p.Name, string(doc))
+}
+
+// checkPolicySubjects checks that the users and teams of p exist. A policy
+// cannot be attached to a name before it is taken, which would let anyone
+// registering or creating a team with that name gain the policy.
+// CreateTeam keeps the team names attached to policies, DeleteAccount
+// detaches the user from them.
+func (u *UseCase) checkPolicySubjects(ctx context.Context, p policy.Policy) error {
+	var violations []validate.Violation
+	for _, username := range p.Users {
+		ok, err := u.storage.UserExists(ctx, username)
+		if err != nil {
+			return fmt.Errorf("UserExists: %w", err)
+		}
+		if !ok {
+			violations = append(violations, validate.Violation{Field: "users", Description: fmt.Sprintf("user %q does not exist", username)})
+		}
+	}
+	for _, team := range p.Teams {
+		_, err := u.storage.GetTeam(ctx, team)
+		if errors.Is(err, storage.ErrNotFound) {
+			violations = append(violations, validate.Violation{Field: "teams", Description: fmt.Sprintf("team %q does not exist", team)})
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("GetTeam: %w", err)
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return &validate.Error{Violations: violations}
+}
+
+// detachPolicies removes username from the users of every policy, so that
+// whoever registers the name later does not gain them
+func (u *UseCase) detachPolicies(ctx context.Context, username string) error {
+	policies, err := u.policies(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range policies {
+		users := make([]string, 0, len(p.Users))
+		for _, user := range p.Users {
+			if user != username {
+				users = append(users, user)
+			}
+		}
+		if len(users) == len(p.Users) {
+			continue
+		}
+		p.Users = users
+
+		doc, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		if err = u.storage.SetPolicy(ctx, p.Name, string(doc)); err != nil {
+			return fmt.Errorf("SetPolicy: %w", err)
+		}
+	}
+	return nil
+}
+
+// policyOfTeam returns the name of a policy attached to team, empty if
+// there is none
+func (u *UseCase) policyOfTeam(ctx context.Context, team string) (string, error) {
+	policies, err := u.policies(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range policies {
+		for _, t := range p.Teams {
+			if t == team {
+				return p.Name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// GetPolicy returns a policy. It requires the admin token.
+func (u *UseCase) GetPolicy(ctx context.Context, name string) (policy.Policy, error) {
+	if !u.isAdmin(ctx) {
+		return policy.Policy{}, ErrPermissionDenied
+	}
+
+	doc, err := u.storage.GetPolicy(ctx, name)
+	if err != nil {
+		return policy.Policy{}, fmt.Errorf("GetPolicy: %w", err)
+	}
+
+	var p policy.Policy
+	if err = json.Unmarshal([]byte(doc), &p); err != nil {
+		return policy.Policy{}, fmt.Errorf("policy %s: %w", name, err)
+	}
+	return p, nil
+}
+
+// DeletePolicy deletes a policy. It requires the admin token.
+func (u *UseCase) DeletePolicy(ctx context.Context, name string) (err error) {
+	defer u.recordPolicy(ctx, audit.ActionDeletePolicy, name, &err)
+
+	if !u.isAdmin(ctx) {
+		return ErrPermissionDenied
+	}
+
+	return u.storage.DeletePolicy(ctx, name)
+}
+
+// ListPolicies returns all policies sorted by name. It requires the admin
+// token.
+func (u *UseCase) ListPolicies(ctx context.Context) ([]policy.Policy, error) {
+	if !u.isAdmin(ctx) {
+		return nil, ErrPermissionDenied
+	}
+
+	return u.policies(ctx)
+}
+
+// CheckPermission tells whether the user may use capability on key without
+// doing anything. Checking another user requires the admin token.
+func (u *UseCase) CheckPermission(ctx context.Context, username, key, capability string) (policy.Decision, error) {
+	if username == "" {
+		var err error
+		if username, err = u.getUsernameFromContext(ctx); err != nil {
+			return policy.Decision{}, fmt.Errorf("getFromContext: %w", err)
+		}
+	} else if !u.isAdmin(ctx) {
+		return policy.Decision{}, ErrPermissionDenied
+	}
+
+	if err := policy.CheckCapability(capability); err != nil {
+		return policy.Decision{}, err
+	}
+
+	return u.evaluate(ctx, username, key, capability)
+}
+
+// authorize checks that the policies of username allow capability on key
+func (u *UseCase) authorize(ctx context.Context, username, key, capability string) error {
+	d, err := u.evaluate(ctx, username, key, capability)
+	if err != nil {
+		return err
+	}
+	if !d.Allowed {
+		return fmt.Errorf("%w: %s", ErrPermissionDenied, d.Reason)
+	}
+	return nil
+}
+
+// filterNames returns the names username may list
+func (u *UseCase) filterNames(ctx context.Context, username string, names []string) ([]string, error) {
+	policies, err := u.policies(ctx)
+	if err != nil || len(policies) == 0 {
+		return names, err
+	}
+
+	subject, err := u.subject(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := names[:0]
+	for _, name := range names {
+		if policy.Evaluate(policies, subject, name, policy.List).Allowed {
+			allowed = append(allowed, name)
+		}
+	}
+	return allowed, nil
+}
+
+func (u *UseCase) evaluate(ctx context.Context, username, key, capability string) (policy.Decision, error) {
+	policies, err := u.policies(ctx)
+	if err != nil {
+		return policy.Decision{}, err
+	}
+	if len(policies) == 0 {
+		return policy.Evaluate(nil, policy.Subject{User: username}, key, capability), nil
+	}
+
+	subject, err := u.subject(ctx, username)
+	if err != nil {
+		return policy.Decision{}, err
+	}
+	return policy.Evaluate(policies, subject, key, capability), nil
+}
+
+func (u *UseCase) subject(ctx context.Context, username string) (policy.Subject, error) {
+	teams, err := u.storage.TeamsOf(ctx, username)
+	if err != nil {
+		return policy.Subject{}, fmt.Errorf("TeamsOf: %w", err)
+	}
+	return policy.Subject{User: username, Teams: teams}, nil
+}
+
+// policies returns the policies sorted by name. They are parsed again only
+// when their version in storage changes, so a change made through any
+// instance is seen by the next decision. The result is shared by the
+// callers and must not be changed.
+func (u *UseCase) policies(ctx context.Context) ([]policy.Policy, error) {
+	version, err := u.storage.PoliciesVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("PoliciesVersion: %w", err)
+	}
+
+	if policies, ok := u.policyCache.get(version); ok {
+		return policies, nil
+	}
+
+	// a change after the version was read makes the next call load again
+	policies, err := u.loadPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u.policyCache.set(policies, version)
+	return policies, nil
+}
+
+func (u *UseCase) loadPolicies(ctx context.Context) ([]policy.Policy, error) {
+	docs, err := u.storage.Policies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Policies: %w", err)
+	}
+
+	policies := make([]policy.Policy, 0, len(docs))
+	for name, doc := range docs {
+		var p policy.Policy
+		if err = json.Unmarshal([]byte(doc), &p); err != nil {
+			return nil, fmt.Errorf("policy %s: %w", name, err)
+		}
+		policies = append(policies, p)
+	}
+
+	// deterministic decisions when several policies allow
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Name < policies[j].Name
+	})
+	return policies, nil
+}
+
+// policyCache keeps the policies of one version
+type policyCache struct {
+	mu       sync.RWMutex
+	policies []policy.Policy
+	version  string
+	loaded   bool
+}
+
+// get returns the cached policies if they are of version
+func (c *policyCache) get(version string) ([]policy.Policy, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.policies, c.loaded && c.version == version
+}
+
+func (c *policyCache) set(policies []policy.Policy, version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policies, c.version, c.loaded = policies, version, true
+}
+
+// recordPolicy records an administrative change of a policy
+func (u *UseCase) recordPolicy(ctx context.Context, action, name string, err *error) {
+	u.recordEvent(ctx, audit.Event{Action: action, Target: name}, err)
+}
@@ -0,0 +1,210 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+	"secret-keeper/internal/server/policy"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"testing"
+	"time"
+)
+
+func TestUseCase_policies(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store, adminToken: "admin"}
+	adminCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(adminTokenKey, "admin"))
+
+	username, ctx := newTestUser(t, &u, "policy")
+
+	// written before the policy is attached
+	for _, key := range []string{"prod/db", "dev/db"} {
+		if err = u.Set(ctx, key, "secret", time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := policy.Policy{
+		Name:  "prod-read-" + uuid.NewString(),
+		Rules: []policy.Rule{{Path: "prod/*", Capabilities: []string{policy.Read, policy.List}}},
+		Users: []string{username},
+	}
+	if err = u.PutPolicy(ctx, p); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("PutPolicy() error = %v, want %v without the admin token", err, ErrPermissionDenied)
+	}
+	if err = u.PutPolicy(adminCtx, p); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { u.DeletePolicy(adminCtx, p.Name) })
+
+	if _, err = u.Get(ctx, "prod/db"); err != nil {
+		t.Errorf("Get(prod/db) error = %v", err)
+	}
+	if _, err = u.Get(ctx, "dev/db"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Get(dev/db) error = %v, want %v", err, ErrPermissionDenied)
+	}
+	if err = u.Set(ctx, "prod/db", "changed", time.Time{}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Set(prod/db) error = %v, want %v", err, ErrPermissionDenied)
+	}
+	if err = u.Delete(ctx, "prod/db"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Delete(prod/db) error = %v, want %v", err, ErrPermissionDenied)
+	}
+
+	names, err := u.GetAllNames(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0].Name != "prod/db" {
+		t.Errorf("GetAllNames() = %v, want [prod/db]", names)
+	}
+
+	d, err := u.CheckPermission(ctx, "", "prod/db", policy.Write)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed {
+		t.Errorf("CheckPermission() = %+v, want denied", d)
+	}
+	if d, err = u.CheckPermission(adminCtx, username, "prod/db", policy.Read); err != nil || !d.Allowed || d.Policy != p.Name {
+		t.Errorf("CheckPermission() = %+v, %v, want allowed by %s", d, err, p.Name)
+	}
+	if _, err = u.CheckPermission(ctx, "someone-else", "prod/db", policy.Read); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("CheckPermission() error = %v, want %v for another user", err, ErrPermissionDenied)
+	}
+
+	if err = u.DeletePolicy(adminCtx, p.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.Set(ctx, "prod/db", "changed", time.Time{}); err != nil {
+		t.Errorf("Set() error = %v after the policy was deleted", err)
+	}
+}
+
+func TestUseCase_policies_teams(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store, adminToken: "admin"}
+	adminCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(adminTokenKey, "admin"))
+
+	_, ownerCtx := newTestUser(t, &u, "owner")
+	_, otherCtx := newTestUser(t, &u, "other")
+
+	team := "team-" + uuid.NewString()[:8]
+	p := policy.Policy{
+		Name:  "team-read-" + uuid.NewString(),
+		Rules: []policy.Rule{{Path: "prod/*", Capabilities: []string{policy.Read}}},
+		Teams: []string{team},
+	}
+	if err = u.PutPolicy(adminCtx, p); !errors.Is(err, validate.ErrInvalid) {
+		t.Errorf("PutPolicy() error = %v, want %v for a team not created yet", err, validate.ErrInvalid)
+	}
+
+	if err = u.CreateTeam(ownerCtx, team); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.PutPolicy(adminCtx, p); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { u.DeletePolicy(adminCtx, p.Name) })
+
+	// the name stays with the policy once the team is gone
+	if err = u.DeleteTeam(ownerCtx, team); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.CreateTeam(otherCtx, team); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("CreateTeam() error = %v, want %v for a team named by a policy", err, storage.ErrAlreadyExists)
+	}
+
+	if err = u.DeletePolicy(adminCtx, p.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.CreateTeam(otherCtx, team); err != nil {
+		t.Errorf("CreateTeam() error = %v once the policy is deleted", err)
+	}
+}
+
+func TestUseCase_policies_users(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store, adminToken: "admin"}
+	adminCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(adminTokenKey, "admin"))
+
+	p := policy.Policy{
+		Name:  "user-read-" + uuid.NewString(),
+		Rules: []policy.Rule{{Path: "prod/*", Capabilities: []string{policy.Read}}},
+		Users: []string{"policy-" + uuid.NewString()},
+	}
+	if err = u.PutPolicy(adminCtx, p); !errors.Is(err, validate.ErrInvalid) {
+		t.Errorf("PutPolicy() error = %v, want %v for a user not registered yet", err, validate.ErrInvalid)
+	}
+
+	username, ctx := newTestUser(t, &u, "policy")
+	p.Users = []string{username}
+	if err = u.PutPolicy(adminCtx, p); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { u.DeletePolicy(adminCtx, p.Name) })
+
+	// whoever registers the name again does not gain the policy
+	if err = u.DeleteAccount(ctx, "password", ""); err != nil {
+		t.Fatal(err)
+	}
+	got, err := u.GetPolicy(adminCtx, p.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Users) != 0 {
+		t.Errorf("GetPolicy() users = %v, want none after the account is deleted", got.Users)
+	}
+
+	if _, err = u.Register(setHeader(context.Background()), username, "password"); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := u.CheckPermission(adminCtx, username, "prod/db", policy.Read); err != nil || d.Policy != "" {
+		t.Errorf("CheckPermission() = %+v, %v, want no policy", d, err)
+	}
+}
+
+func TestUseCase_policies_otherInstance(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := UseCase{storage: store, adminToken: "admin"}
+	b := UseCase{storage: store, adminToken: "admin"}
+	adminCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(adminTokenKey, "admin"))
+
+	username, _ := newTestUser(t, &a, "policy")
+
+	// b caches the policies before a puts a new one
+	if _, err = b.CheckPermission(adminCtx, username, "prod/db", policy.Read); err != nil {
+		t.Fatal(err)
+	}
+
+	p := policy.Policy{
+		Name:  "deny-" + uuid.NewString(),
+		Rules: []policy.Rule{{Path: "prod/*", Capabilities: []string{policy.Read}, Deny: true}},
+		Users: []string{username},
+	}
+	if err = a.PutPolicy(adminCtx, p); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.DeletePolicy(adminCtx, p.Name) })
+
+	d, err := b.CheckPermission(adminCtx, username, "prod/db", policy.Read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed || d.Policy != p.Name {
+		t.Errorf("CheckPermission() = %+v on another instance, want denied by %s", d, p.Name)
+	}
+}
@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"secret-keeper/internal/server/storage"
+)
+
+// ErrQuotaExceeded is returned when a user reaches a storage limit
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota limits the storage of every user and every team vault. Zero means
+// no limit.
+type Quota struct {
+	MaxSecrets int
+	MaxBytes   int
+}
+
+// Usage is the storage used by a user. Bytes count both names and values,
+// of secrets and of files alike.
+type Usage struct {
+	Secrets int
+	Files   int
+	Bytes   int
+	Quota
+}
+
+// QuotaError tells which limit was reached
+type QuotaError struct {
+	// Subject is the exceeded limit, "secrets" or "bytes"
+	Subject string
+	Limit   int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: at most %d %s per user", ErrQuotaExceeded, e.Limit, e.Subject)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// WithQuota limits the number and the size of secrets of every user
+func WithQuota(q Quota) Option {
+	return func(u *UseCase) {
+		u.quota = q
+	}
+}
+
+// GetUsage returns the storage used by the user and their limits
+func (u *UseCase) GetUsage(ctx context.Context) (Usage, error) {
+	username, err := u.getUsernameFromContext(ctx)
+	if err != nil {
+		return Usage{}, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	secrets, err := u.storage.GetAll(ctx, username)
+	if err != nil {
+		return Usage{}, err
+	}
+	files, err := u.storage.ListFiles(ctx, username)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	usage := usageOf(secrets, u.quota)
+	usage.Files = len(files)
+	usage.Bytes += filesSize(files)
+	return usage, nil
+}
+
+// checkQuota checks that setting key to value keeps the user within the
+// quota. Concurrent calls may overshoot it slightly, since itisadb keeps no
+// counters to update atomically.
+func (u *UseCase) checkQuota(ctx context.Context, username, key, value string) error {
+	if u.quota == (Quota{}) {
+		return nil
+	}
+
+	secrets, err := u.storage.GetAll(ctx, username)
+	if err != nil {
+		return fmt.Errorf("GetAll: %w", err)
+	}
+	fileBytes, err := u.fileBytes(ctx, username)
+	if err != nil {
+		return err
+	}
+	return u.quotaAllows(secrets, fileBytes, key, value)
+}
+
+// fileBytes returns the bytes the files of the user take from their quota
+func (u *UseCase) fileBytes(ctx context.Context, username string) (int, error) {
+	if u.quota.MaxBytes == 0 {
+		return 0, nil
+	}
+
+	files, err := u.storage.ListFiles(ctx, username)
+	if err != nil {
+		return 0, fmt.Errorf("ListFiles: %w", err)
+	}
+	return filesSize(files), nil
+}
+
+// quotaAllows checks that setting key to value keeps secrets, along with
+// fileBytes of files, within the quota
+func (u *UseCase) quotaAllows(secrets map[string]string, fileBytes int, key, value string) error {
+	usage := usageOf(secrets, u.quota)
+	usage.Bytes += fileBytes
+	if old, ok := secrets[key]; ok {
+		usage.Bytes -= len(key) + len(old)
+	} else {
+		usage.Secrets++
+	}
+	usage.Bytes += len(key) + len(value)
+
+	if u.quota.MaxSecrets > 0 && usage.Secrets > u.quota.MaxSecrets {
+		return &QuotaError{Subject: "secrets", Limit: u.quota.MaxSecrets}
+	}
+	if u.quota.MaxBytes > 0 && usage.Bytes > u.quota.MaxBytes {
+		return &QuotaError{Subject: "bytes", Limit: u.quota.MaxBytes}
+	}
+	return nil
+}
+
+func usageOf(secrets map[string]string, q Quota) Usage {
+	usage := Usage{Secrets: len(secrets), Quota: q}
+	for k, v := range secrets {
+		usage.Bytes += len(k) + len(v)
+	}
+	return usage
+}
+
+// fileAllowance returns how many bytes the file key of the user may take
+// within the quota, the file it replaces not counted, or -1 without a limit
+func (u *UseCase) fileAllowance(ctx context.Context, username, key string) (int64, error) {
+	if u.quota.MaxBytes == 0 {
+		return -1, nil
+	}
+
+	secrets, err := u.storage.GetAll(ctx, username)
+	if err != nil {
+		return 0, fmt.Errorf("GetAll: %w", err)
+	}
+	files, err := u.storage.ListFiles(ctx, username)
+	if err != nil {
+		return 0, fmt.Errorf("ListFiles: %w", err)
+	}
+	delete(files, key)
+
+	used := usageOf(secrets, u.quota).Bytes + filesSize(files) + len(key)
+	return int64(u.quota.MaxBytes - used), nil
+}
+
+func filesSize(files map[string]storage.File) int {
+	var size int
+	for key, file := range files {
+		size += len(key) + int(file.Size)
+	}
+	return size
+}
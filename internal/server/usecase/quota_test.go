@@ -0,0 +1,54 @@
+package usecase
+
+import (
+	"errors"
+	"secret-keeper/internal/server/storage"
+	"testing"
+	"time"
+)
+
+func TestUseCase_SetQuota(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store, quota: Quota{MaxSecrets: 2, MaxBytes: 20}}
+
+	_, ctx := newTestUser(t, &u, "quota")
+
+	tests := []struct {
+		name        string
+		key         string
+		value       string
+		wantSubject string
+	}{
+		{name: "first", key: "a", value: "1234"},
+		{name: "second", key: "b", value: "1234"},
+		{name: "tooManySecrets", key: "c", value: "1", wantSubject: "secrets"},
+		{name: "overwrite", key: "a", value: "123456789"},
+		{name: "tooManyBytes", key: "b", value: "1234567890", wantSubject: "bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := u.Set(ctx, tt.key, tt.value, time.Time{})
+
+			var quota *QuotaError
+			if tt.wantSubject == "" {
+				if err != nil {
+					t.Errorf("Set() error = %v", err)
+				}
+			} else if !errors.As(err, &quota) || quota.Subject != tt.wantSubject {
+				t.Errorf("Set() error = %v, want %s quota exceeded", err, tt.wantSubject)
+			}
+		})
+	}
+
+	usage, err := u.GetUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Usage{Secrets: 2, Bytes: 15, Quota: u.quota}
+	if usage != want {
+		t.Errorf("GetUsage() = %+v, want %+v", usage, want)
+	}
+}
@@ -0,0 +1,205 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/policy"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"time"
+)
+
+// Share modes
+const (
+	ShareRead      = "read"
+	ShareReadWrite = "read_write"
+)
+
+// ShareSecret grants recipient access to the key of the user. Sharing a
+// key again changes the mode.
+func (u *UseCase) ShareSecret(ctx context.Context, key, recipient, mode string) (err error) {
+	var username string
+	defer u.recordShare(ctx, audit.ActionShare, &username, key, recipient, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if mode != ShareRead && mode != ShareReadWrite {
+		return &validate.Error{Violations: []validate.Violation{{Field: "mode", Description: "must be read or read_write"}}}
+	}
+	if recipient == username {
+		return &validate.Error{Violations: []validate.Violation{{Field: "recipient", Description: "must not be yourself"}}}
+	}
+
+	if err = u.authorize(ctx, username, key, policy.Share); err != nil {
+		return err
+	}
+
+	if _, err = u.storage.Get(ctx, username, key); err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+
+	exists, err := u.storage.UserExists(ctx, recipient)
+	if err != nil {
+		return fmt.Errorf("UserExists: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("recipient: %w", storage.ErrNotFound)
+	}
+
+	return u.storage.AddShare(ctx, storage.Share{Owner: username, Key: key, Recipient: recipient, Mode: mode})
+}
+
+// RevokeShare revokes the access of recipient to the key of the user and
+// reports whether it was shared
+func (u *UseCase) RevokeShare(ctx context.Context, key, recipient string) (_ bool, err error) {
+	var username string
+	defer u.recordShare(ctx, audit.ActionRevokeShare, &username, key, recipient, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if err = u.authorize(ctx, username, key, policy.Share); err != nil {
+		return false, err
+	}
+
+	removed, err := u.storage.RemoveShares(ctx, username, "", func(sh storage.Share) bool {
+		return sh.Key != key || sh.Recipient != recipient
+	})
+	if err != nil {
+		return false, fmt.Errorf("RemoveShares: %w", err)
+	}
+	return removed != 0, nil
+}
+
+// ListSharedWithMe returns the secrets shared with the user
+func (u *UseCase) ListSharedWithMe(ctx context.Context) ([]storage.Share, error) {
+	username, err := u.getUsernameFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	return u.storage.SharedWith(ctx, username)
+}
+
+// ListSharedByMe returns the secrets the user shared with others
+func (u *UseCase) ListSharedByMe(ctx context.Context) ([]storage.Share, error) {
+	username, err := u.getUsernameFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	return u.storage.SharedBy(ctx, username)
+}
+
+// GetShared gets the value of a key of owner shared with the user. Keys
+// that are not shared are reported as not found.
+func (u *UseCase) GetShared(ctx context.Context, owner, key string) (_ string, err error) {
+	var username string
+	defer u.recordShared(ctx, audit.ActionGet, &username, owner, key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if err = u.authorize(ctx, username, key, policy.Read); err != nil {
+		return "", err
+	}
+
+	if owner != username {
+		if _, err = u.findShare(ctx, username, owner, key); err != nil {
+			return "", err
+		}
+	}
+
+	if err = u.checkExpiry(ctx, storage.Vault{User: owner}, key); err != nil {
+		return "", fmt.Errorf("get: %w", err)
+	}
+
+	val, err := u.storage.Get(ctx, owner, key)
+	if err != nil {
+		return "", fmt.Errorf("get: %w", err)
+	}
+	return val, nil
+}
+
+// SetShared sets the value of a key of owner shared with the user in
+// read_write mode. A zero expiresAt makes it never expire.
+func (u *UseCase) SetShared(ctx context.Context, owner, key, value string, expiresAt time.Time) (err error) {
+	var username string
+	defer u.recordShared(ctx, audit.ActionSet, &username, owner, key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if err = u.authorize(ctx, username, key, policy.Write); err != nil {
+		return err
+	}
+
+	if owner != username {
+		share, err := u.findShare(ctx, username, owner, key)
+		if err != nil {
+			return err
+		}
+		if share.Mode != ShareReadWrite {
+			return ErrPermissionDenied
+		}
+	}
+
+	if err = u.validator.Secret(key, value); err != nil {
+		return err
+	}
+
+	// the secret stays in the quota of the owner
+	if err = u.checkQuota(ctx, owner, key, value); err != nil {
+		return err
+	}
+
+	if err = u.setExpiry(ctx, storage.Vault{User: owner}, key, expiresAt); err != nil {
+		return err
+	}
+
+	change := u.changeOf(ctx, owner, key)
+	if err = u.storage.Set(ctx, owner, key, value); err != nil {
+		return err
+	}
+	u.publish(owner, key, change)
+	return nil
+}
+
+// findShare returns the share of the key of owner with recipient
+func (u *UseCase) findShare(ctx context.Context, recipient, owner, key string) (storage.Share, error) {
+	shares, err := u.storage.SharedWith(ctx, recipient)
+	if err != nil {
+		return storage.Share{}, fmt.Errorf("SharedWith: %w", err)
+	}
+
+	for _, sh := range shares {
+		if sh.Owner == owner && sh.Key == key {
+			return sh, nil
+		}
+	}
+	return storage.Share{}, fmt.Errorf("share: %w", storage.ErrNotFound)
+}
+
+// recordShare records a change of the shares of a key
+func (u *UseCase) recordShare(ctx context.Context, action string, username *string, key, recipient string, err *error) {
+	u.recordEvent(ctx, audit.Event{User: *username, Action: action, Key: key, Target: recipient}, err)
+}
+
+// recordShared records an access to a secret of owner
+func (u *UseCase) recordShared(ctx context.Context, action string, username *string, owner, key string, err *error) {
+	e := audit.Event{User: *username, Action: action, Key: key}
+	if owner != *username {
+		e.Owner = owner
+	}
+	u.recordEvent(ctx, e, err)
+}
@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"testing"
+	"time"
+)
+
+func TestUseCase_ShareSecret(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	owner, ownerCtx := newTestUser(t, &u, "owner")
+	recipient, recipientCtx := newTestUser(t, &u, "recipient")
+
+	if err = u.Set(ownerCtx, "db", "secret", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	shareTests := []struct {
+		name      string
+		key       string
+		recipient string
+		mode      string
+		wantErr   error
+	}{
+		{name: "unknownKey", key: "nope", recipient: recipient, mode: ShareRead, wantErr: storage.ErrNotFound},
+		{name: "unknownRecipient", key: "db", recipient: "nobody-" + uuid.NewString(), mode: ShareRead, wantErr: storage.ErrNotFound},
+		{name: "self", key: "db", recipient: owner, mode: ShareRead, wantErr: validate.ErrInvalid},
+		{name: "badMode", key: "db", recipient: recipient, mode: "write", wantErr: validate.ErrInvalid},
+		{name: "ok", key: "db", recipient: recipient, mode: ShareRead},
+	}
+	for _, tt := range shareTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := u.ShareSecret(ownerCtx, tt.key, tt.recipient, tt.mode)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ShareSecret() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	shared, err := u.ListSharedWithMe(recipientCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := storage.Share{Owner: owner, Key: "db", Recipient: recipient, Mode: ShareRead}
+	if len(shared) != 1 || shared[0] != want {
+		t.Fatalf("ListSharedWithMe() = %+v, want [%+v]", shared, want)
+	}
+
+	if val, err := u.GetShared(recipientCtx, owner, "db"); err != nil || val != "secret" {
+		t.Errorf("GetShared() = %q, %v, want secret", val, err)
+	}
+	if err = u.SetShared(recipientCtx, owner, "db", "changed", time.Time{}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("SetShared() error = %v, want %v", err, ErrPermissionDenied)
+	}
+
+	if err = u.ShareSecret(ownerCtx, "db", recipient, ShareReadWrite); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.SetShared(recipientCtx, owner, "db", "changed", time.Time{}); err != nil {
+		t.Errorf("SetShared() error = %v", err)
+	}
+	if val, err := u.Get(ownerCtx, "db"); err != nil || val != "changed" {
+		t.Errorf("Get() = %q, %v, want changed", val, err)
+	}
+
+	revoked, err := u.RevokeShare(ownerCtx, "db", recipient)
+	if err != nil || !revoked {
+		t.Fatalf("RevokeShare() = %v, %v, want true", revoked, err)
+	}
+	if _, err = u.GetShared(recipientCtx, owner, "db"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetShared() error = %v, want %v", err, storage.ErrNotFound)
+	}
+	if revoked, err = u.RevokeShare(ownerCtx, "db", recipient); err != nil || revoked {
+		t.Errorf("RevokeShare() = %v, %v, want false", revoked, err)
+	}
+}
+
+func TestUseCase_DeleteRemovesShares(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	_, ownerCtx := newTestUser(t, &u, "owner")
+	recipient, recipientCtx := newTestUser(t, &u, "recipient")
+
+	if err = u.Set(ownerCtx, "db", "secret", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.ShareSecret(ownerCtx, "db", recipient, ShareRead); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.Delete(ownerCtx, "db"); err != nil {
+		t.Fatal(err)
+	}
+
+	shared, err := u.ListSharedWithMe(recipientCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shared) != 0 {
+		t.Errorf("ListSharedWithMe() = %+v, want none", shared)
+	}
+}
@@ -0,0 +1,414 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/policy"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"time"
+)
+
+// Team roles, from the most to the least privileged
+const (
+	RoleOwner    = "owner"
+	RoleAdmin    = "admin"
+	RoleMember   = "member"
+	RoleReadOnly = "read_only"
+)
+
+// roleRank orders the roles by privilege
+var roleRank = map[string]int{
+	RoleOwner:    3,
+	RoleAdmin:    2,
+	RoleMember:   1,
+	RoleReadOnly: 0,
+}
+
+// ErrLastOwner is returned when a change would leave a team without an
+// owner
+var ErrLastOwner = errors.New("a team needs at least one owner")
+
+// Membership is a team of the user with their role in it
+type Membership struct {
+	Team string
+	Role string
+}
+
+// CreateTeam creates a team with the user as its owner
+func (u *UseCase) CreateTeam(ctx context.Context, team string) (err error) {
+	var username string
+	defer u.recordTeam(ctx, audit.ActionCreateTeam, &username, team, "", "", &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if err = u.validator.Team(team); err != nil {
+		return err
+	}
+
+	// the name of a deleted team stays with its policies
+	name, err := u.policyOfTeam(ctx, team)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		return fmt.Errorf("%w: the team is named by policy %s", storage.ErrAlreadyExists, name)
+	}
+
+	return u.storage.CreateTeam(ctx, storage.Team{Name: team, Members: map[string]string{username: RoleOwner}})
+}
+
+// DeleteTeam deletes a team owned by the user with its vault
+func (u *UseCase) DeleteTeam(ctx context.Context, team string) (err error) {
+	var username string
+	defer u.recordTeam(ctx, audit.ActionDeleteTeam, &username, team, "", "", &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if _, err = u.teamRole(ctx, username, team, RoleOwner); err != nil {
+		return err
+	}
+
+	return u.storage.DeleteTeam(ctx, team)
+}
+
+// ListTeams returns the teams of the user
+func (u *UseCase) ListTeams(ctx context.Context) ([]Membership, error) {
+	username, err := u.getUsernameFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	names, err := u.storage.TeamsOf(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("TeamsOf: %w", err)
+	}
+
+	memberships := make([]Membership, 0, len(names))
+	for _, name := range names {
+		team, err := u.storage.GetTeam(ctx, name)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("GetTeam: %w", err)
+		}
+		if role, ok := team.Members[username]; ok {
+			memberships = append(memberships, Membership{Team: name, Role: role})
+		}
+	}
+	return memberships, nil
+}
+
+// GetTeam returns a team of the user with its members
+func (u *UseCase) GetTeam(ctx context.Context, team string) (storage.Team, error) {
+	username, err := u.getUsernameFromContext(ctx)
+	if err != nil {
+		return storage.Team{}, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	t, err := u.storage.GetTeam(ctx, team)
+	if err != nil {
+		return storage.Team{}, fmt.Errorf("GetTeam: %w", err)
+	}
+	if _, ok := t.Members[username]; !ok {
+		return storage.Team{}, fmt.Errorf("team: %w", storage.ErrNotFound)
+	}
+	return t, nil
+}
+
+// SetTeamMember adds member to the team or changes their role. Admins
+// manage members and read-only members, owners manage everyone.
+func (u *UseCase) SetTeamMember(ctx context.Context, team, member, role string) (err error) {
+	var username string
+	defer u.recordTeam(ctx, audit.ActionSetTeamMember, &username, team, member, "", &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if _, ok := roleRank[role]; !ok {
+		return &validate.Error{Violations: []validate.Violation{{Field: "role", Description: "must be owner, admin, member or read_only"}}}
+	}
+
+	exists, err := u.storage.UserExists(ctx, member)
+	if err != nil {
+		return fmt.Errorf("UserExists: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("member: %w", storage.ErrNotFound)
+	}
+
+	_, err = u.storage.UpdateTeam(ctx, team, func(t *storage.Team) error {
+		if err := canManage(t, username, member, role); err != nil {
+			return err
+		}
+		t.Members[member] = role
+		return hasOwner(t)
+	})
+	return err
+}
+
+// RemoveTeamMember removes member from the team. Any member may leave,
+// an empty member meaning the user.
+func (u *UseCase) RemoveTeamMember(ctx context.Context, team, member string) (err error) {
+	var username string
+	defer u.recordTeam(ctx, audit.ActionRemoveTeamMember, &username, team, member, "", &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if member == "" {
+		member = username
+	}
+
+	_, err = u.storage.UpdateTeam(ctx, team, func(t *storage.Team) error {
+		if _, ok := t.Members[username]; !ok {
+			return fmt.Errorf("team: %w", storage.ErrNotFound)
+		}
+		if _, ok := t.Members[member]; !ok {
+			return fmt.Errorf("member: %w", storage.ErrNotFound)
+		}
+		if member != username {
+			if err := canManage(t, username, member, ""); err != nil {
+				return err
+			}
+		}
+		delete(t.Members, member)
+		return hasOwner(t)
+	})
+	return err
+}
+
+// TeamGet gets value for key from the vault of team
+func (u *UseCase) TeamGet(ctx context.Context, team, key string) (_ string, err error) {
+	var username string
+	defer u.recordTeam(ctx, audit.ActionGet, &username, team, "", key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if _, err = u.teamRole(ctx, username, team, RoleReadOnly); err != nil {
+		return "", err
+	}
+	if err = u.authorize(ctx, username, key, policy.Read); err != nil {
+		return "", err
+	}
+
+	if err = u.checkExpiry(ctx, storage.Vault{Team: team}, key); err != nil {
+		return "", fmt.Errorf("get: %w", err)
+	}
+
+	val, err := u.storage.TeamGet(ctx, team, key)
+	if err != nil {
+		return "", fmt.Errorf("get: %w", err)
+	}
+	return val, nil
+}
+
+// TeamSet sets value for key in the vault of team. A zero expiresAt makes
+// it never expire.
+func (u *UseCase) TeamSet(ctx context.Context, team, key, value string, expiresAt time.Time) (err error) {
+	var username string
+	defer u.recordTeam(ctx, audit.ActionSet, &username, team, "", key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if _, err = u.teamRole(ctx, username, team, RoleMember); err != nil {
+		return err
+	}
+	if err = u.authorize(ctx, username, key, policy.Write); err != nil {
+		return err
+	}
+
+	if err = u.validator.Secret(key, value); err != nil {
+		return err
+	}
+
+	if u.quota != (Quota{}) {
+		secrets, err := u.storage.TeamGetAll(ctx, team)
+		if err != nil {
+			return fmt.Errorf("TeamGetAll: %w", err)
+		}
+		if err = u.quotaAllows(secrets, 0, key, value); err != nil {
+			return err
+		}
+	}
+
+	if err = u.setExpiry(ctx, storage.Vault{Team: team}, key, expiresAt); err != nil {
+		return err
+	}
+
+	return u.storage.TeamSet(ctx, team, key, value)
+}
+
+// TeamDelete deletes key from the vault of team
+func (u *UseCase) TeamDelete(ctx context.Context, team, key string) (err error) {
+	var username string
+	defer u.recordTeam(ctx, audit.ActionDelete, &username, team, "", key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if _, err = u.teamRole(ctx, username, team, RoleMember); err != nil {
+		return err
+	}
+	if err = u.authorize(ctx, username, key, policy.Delete); err != nil {
+		return err
+	}
+
+	if err = u.storage.TeamDelete(ctx, team, key); err != nil {
+		return err
+	}
+
+	if err = u.storage.SetExpiry(ctx, storage.Vault{Team: team}, key, time.Time{}); err != nil {
+		return fmt.Errorf("SetExpiry: %w", err)
+	}
+	return nil
+}
+
+// TeamGetAllNames gets all names in the vault of team
+func (u *UseCase) TeamGetAllNames(ctx context.Context, team string) ([]SecretInfo, error) {
+	username, err := u.getUsernameFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if _, err = u.teamRole(ctx, username, team, RoleReadOnly); err != nil {
+		return nil, err
+	}
+
+	secrets, err := u.storage.TeamGetAll(ctx, team)
+	if err != nil {
+		return nil, fmt.Errorf("TeamGetAll: %w", err)
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	if names, err = u.filterNames(ctx, username, names); err != nil {
+		return nil, err
+	}
+	return u.secretInfos(ctx, storage.Vault{Team: team}, names)
+}
+
+// teamRole returns the role of username in team if it is at least min.
+// Teams the user is not a member of are reported as not found.
+func (u *UseCase) teamRole(ctx context.Context, username, team, min string) (string, error) {
+	t, err := u.storage.GetTeam(ctx, team)
+	if err != nil {
+		return "", fmt.Errorf("GetTeam: %w", err)
+	}
+
+	role, ok := t.Members[username]
+	if !ok {
+		return "", fmt.Errorf("team: %w", storage.ErrNotFound)
+	}
+	if roleRank[role] < roleRank[min] {
+		return "", ErrPermissionDenied
+	}
+	return role, nil
+}
+
+// leaveTeams removes username from all their teams, deleting the teams
+// left without members. With dryRun it only checks that no team would be
+// left without an owner.
+func (u *UseCase) leaveTeams(ctx context.Context, username string, dryRun bool) error {
+	names, err := u.storage.TeamsOf(ctx, username)
+	if err != nil {
+		return fmt.Errorf("TeamsOf: %w", err)
+	}
+
+	for _, name := range names {
+		t, err := u.storage.GetTeam(ctx, name)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("GetTeam: %w", err)
+		}
+
+		if len(t.Members) == 1 {
+			if !dryRun {
+				if err = u.storage.DeleteTeam(ctx, name); err != nil {
+					return fmt.Errorf("DeleteTeam: %w", err)
+				}
+			}
+			continue
+		}
+
+		delete(t.Members, username)
+		if err = hasOwner(&t); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if dryRun {
+			continue
+		}
+
+		_, err = u.storage.UpdateTeam(ctx, name, func(t *storage.Team) error {
+			delete(t.Members, username)
+			return hasOwner(t)
+		})
+		if err != nil {
+			return fmt.Errorf("UpdateTeam: %w", err)
+		}
+	}
+	return nil
+}
+
+// canManage checks that username may change the role of member from the
+// current one to role, an empty role meaning removal
+func canManage(t *storage.Team, username, member, role string) error {
+	callerRole, ok := t.Members[username]
+	if !ok {
+		return fmt.Errorf("team: %w", storage.ErrNotFound)
+	}
+	if callerRole == RoleOwner {
+		return nil
+	}
+	if callerRole != RoleAdmin {
+		return ErrPermissionDenied
+	}
+
+	// admins cannot touch owners and other admins nor create them
+	if current, ok := t.Members[member]; ok && roleRank[current] >= roleRank[RoleAdmin] {
+		return ErrPermissionDenied
+	}
+	if role != "" && roleRank[role] >= roleRank[RoleAdmin] {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+func hasOwner(t *storage.Team) error {
+	for _, role := range t.Members {
+		if role == RoleOwner {
+			return nil
+		}
+	}
+	return ErrLastOwner
+}
+
+// recordTeam records an action on team, about member or key
+func (u *UseCase) recordTeam(ctx context.Context, action string, username *string, team, member, key string, err *error) {
+	u.recordEvent(ctx, audit.Event{User: *username, Action: action, Team: team, Target: member, Key: key}, err)
+}
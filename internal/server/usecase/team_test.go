@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"secret-keeper/internal/server/storage"
+	"testing"
+	"time"
+)
+
+func TestUseCase_Team(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	owner, ownerCtx := newTestUser(t, &u, "owner")
+	admin, adminCtx := newTestUser(t, &u, "admin")
+	member, memberCtx := newTestUser(t, &u, "member")
+	reader, readerCtx := newTestUser(t, &u, "reader")
+	_, outsiderCtx := newTestUser(t, &u, "outsider")
+
+	team := "team-" + uuid.NewString()[:8]
+	if err = u.CreateTeam(ownerCtx, team); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.CreateTeam(adminCtx, team); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("CreateTeam() error = %v, want %v", err, storage.ErrAlreadyExists)
+	}
+
+	if err = u.SetTeamMember(ownerCtx, team, admin, RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	memberTests := []struct {
+		name    string
+		ctx     context.Context
+		member  string
+		role    string
+		wantErr error
+	}{
+		{name: "adminAddsMember", ctx: adminCtx, member: member, role: RoleMember},
+		{name: "adminAddsReader", ctx: adminCtx, member: reader, role: RoleReadOnly},
+		{name: "adminCannotPromote", ctx: adminCtx, member: reader, role: RoleAdmin, wantErr: ErrPermissionDenied},
+		{name: "memberCannotManage", ctx: memberCtx, member: reader, role: RoleMember, wantErr: ErrPermissionDenied},
+		{name: "outsider", ctx: outsiderCtx, member: reader, role: RoleMember, wantErr: storage.ErrNotFound},
+		{name: "unknownUser", ctx: ownerCtx, member: "nobody-" + uuid.NewString(), role: RoleMember, wantErr: storage.ErrNotFound},
+		{name: "lastOwner", ctx: ownerCtx, member: owner, role: RoleAdmin, wantErr: ErrLastOwner},
+	}
+	for _, tt := range memberTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := u.SetTeamMember(tt.ctx, team, tt.member, tt.role)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SetTeamMember() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	vaultTests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr error
+	}{
+		{name: "member", ctx: memberCtx},
+		{name: "readOnly", ctx: readerCtx, wantErr: ErrPermissionDenied},
+		{name: "outsider", ctx: outsiderCtx, wantErr: storage.ErrNotFound},
+	}
+	for _, tt := range vaultTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := u.TeamSet(tt.ctx, team, "db", "secret", time.Time{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("TeamSet() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if val, err := u.TeamGet(readerCtx, team, "db"); err != nil || val != "secret" {
+		t.Errorf("TeamGet() = %q, %v, want secret", val, err)
+	}
+	if names, err := u.TeamGetAllNames(readerCtx, team); err != nil || len(names) != 1 || names[0].Name != "db" {
+		t.Errorf("TeamGetAllNames() = %v, %v, want [db]", names, err)
+	}
+	if _, err = u.Get(ownerCtx, "db"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Get() error = %v, want the team secret out of the personal vault", err)
+	}
+
+	teams, err := u.ListTeams(memberCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(teams) != 1 || teams[0] != (Membership{Team: team, Role: RoleMember}) {
+		t.Errorf("ListTeams() = %+v, want member of %s", teams, team)
+	}
+
+	if err = u.RemoveTeamMember(memberCtx, team, ""); err != nil {
+		t.Fatalf("RemoveTeamMember() error = %v", err)
+	}
+	if _, err = u.TeamGet(memberCtx, team, "db"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("TeamGet() error = %v, want %v after leaving", err, storage.ErrNotFound)
+	}
+
+	if err = u.DeleteTeam(adminCtx, team); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("DeleteTeam() error = %v, want %v", err, ErrPermissionDenied)
+	}
+	if err = u.DeleteTeam(ownerCtx, team); err != nil {
+		t.Fatalf("DeleteTeam() error = %v", err)
+	}
+	if teams, err = u.ListTeams(readerCtx); err != nil || len(teams) != 0 {
+		t.Errorf("ListTeams() = %+v, %v, want none", teams, err)
+	}
+}
+
+func TestUseCase_DeleteAccount_lastOwner(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	_, ownerCtx := newTestUser(t, &u, "owner")
+	member, _ := newTestUser(t, &u, "member")
+
+	team := "team-" + uuid.NewString()[:8]
+	if err = u.CreateTeam(ownerCtx, team); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.SetTeamMember(ownerCtx, team, member, RoleMember); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = u.DeleteAccount(ownerCtx, "password", ""); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("DeleteAccount() error = %v, want %v", err, ErrLastOwner)
+	}
+
+	if err = u.SetTeamMember(ownerCtx, team, member, RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.DeleteAccount(ownerCtx, "password", ""); err != nil {
+		t.Fatalf("DeleteAccount() error = %v", err)
+	}
+
+	got, err := store.GetTeam(context.Background(), team)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Members) != 1 || got.Members[member] != RoleOwner {
+		t.Errorf("members = %v, want only %s", got.Members, member)
+	}
+}
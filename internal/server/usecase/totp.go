@@ -0,0 +1,237 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/pkg"
+	"strings"
+	"time"
+)
+
+// ErrTOTPRequired is returned by Auth when the user has two-factor
+// authentication enabled and no code was given
+var ErrTOTPRequired = errors.New("two-factor code required")
+
+// ErrInvalidCode is returned when a two-factor code is invalid
+var ErrInvalidCode = errors.New("invalid two-factor code")
+
+// ErrTOTPEnabled is returned when two-factor authentication is already enabled
+var ErrTOTPEnabled = errors.New("two-factor authentication is already enabled")
+
+// ErrTOTPNotEnrolled is returned when there is no enrollment to confirm
+var ErrTOTPNotEnrolled = errors.New("two-factor authentication is not enrolled")
+
+const (
+	totpIssuer = "secret-keeper"
+	// totpPeriod is how many seconds a TOTP code is valid for
+	totpPeriod = 30
+
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 10
+)
+
+// Enrollment is a TOTP authenticator waiting for confirmation
+type Enrollment struct {
+	Secret string
+	// URL is the otpauth:// URL of the secret, usually shown as a QR code.
+	URL string
+	// RecoveryCodes are single-use codes accepted instead of a TOTP code.
+	// They are shown only once.
+	RecoveryCodes []string
+}
+
+// EnrollTOTP generates a TOTP secret and recovery codes for the user. They
+// are not used until ConfirmTOTP.
+func (u *UseCase) EnrollTOTP(ctx context.Context) (_ Enrollment, err error) {
+	var username string
+	defer u.record(ctx, audit.ActionEnrollTOTP, &username, "", &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return Enrollment{}, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: totpIssuer, AccountName: username})
+	if err != nil {
+		return Enrollment{}, fmt.Errorf("generate: %w", err)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return Enrollment{}, fmt.Errorf("generateRecoveryCodes: %w", err)
+	}
+
+	err = u.storage.UpdateTOTP(ctx, username, func(state *storage.TOTP) error {
+		if state.Secret != "" {
+			return ErrTOTPEnabled
+		}
+
+		state.PendingSecret = key.Secret()
+		state.PendingRecoveryCodes = hashes
+		return nil
+	})
+	if errors.Is(err, ErrTOTPEnabled) {
+		return Enrollment{}, err
+	}
+	if err != nil {
+		return Enrollment{}, fmt.Errorf("UpdateTOTP: %w", err)
+	}
+
+	return Enrollment{Secret: key.Secret(), URL: key.URL(), RecoveryCodes: codes}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication if code matches the
+// enrolled secret
+func (u *UseCase) ConfirmTOTP(ctx context.Context, code string) (err error) {
+	var username string
+	defer u.record(ctx, audit.ActionConfirmTOTP, &username, "", &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	err = u.storage.UpdateTOTP(ctx, username, func(state *storage.TOTP) error {
+		if state.PendingSecret == "" {
+			return ErrTOTPNotEnrolled
+		}
+
+		step, ok := validateTOTP(code, state.PendingSecret, time.Now(), 0)
+		if !ok {
+			return ErrInvalidCode
+		}
+
+		*state = storage.TOTP{Secret: state.PendingSecret, RecoveryCodes: state.PendingRecoveryCodes, LastStep: step}
+		return nil
+	})
+	if errors.Is(err, ErrTOTPNotEnrolled) || errors.Is(err, ErrInvalidCode) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("UpdateTOTP: %w", err)
+	}
+
+	u.log(ctx).Info("two-factor authentication enabled")
+	return nil
+}
+
+// checkSecondFactor checks the code of a user who has two-factor
+// authentication enabled. A TOTP code is accepted once and a recovery code
+// can be used only once.
+func (u *UseCase) checkSecondFactor(ctx context.Context, username, code string) error {
+	state, err := u.storage.GetTOTP(ctx, username)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("GetTOTP: %w", err)
+	}
+	if state.Secret == "" {
+		return nil
+	}
+
+	if code == "" {
+		return ErrTOTPRequired
+	}
+
+	// remaining is the number of recovery codes left if one was used
+	remaining := -1
+	err = u.storage.UpdateTOTP(ctx, username, func(state *storage.TOTP) error {
+		if state.Secret == "" {
+			// disabled meanwhile
+			return nil
+		}
+
+		if step, ok := validateTOTP(code, state.Secret, time.Now(), state.LastStep); ok {
+			state.LastStep = step
+			return nil
+		}
+
+		hash := hashRecoveryCode(code)
+		for i, h := range state.RecoveryCodes {
+			if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+				state.RecoveryCodes = append(state.RecoveryCodes[:i], state.RecoveryCodes[i+1:]...)
+				remaining = len(state.RecoveryCodes)
+				return nil
+			}
+		}
+		return ErrInvalidCode
+	})
+	if errors.Is(err, ErrInvalidCode) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("UpdateTOTP: %w", err)
+	}
+
+	if remaining >= 0 {
+		u.log(ctx).Warn("recovery code used", pkg.String("remaining", fmt.Sprint(remaining)))
+	}
+	return nil
+}
+
+// validateTOTP reports whether code is valid for secret at now, one period
+// before or after included, and returns its time step. Codes of steps up
+// to last are rejected, so that a code is accepted once.
+func validateTOTP(code, secret string, now time.Time, last int64) (int64, bool) {
+	step := now.Unix() / totpPeriod
+	for s := step - 1; s <= step+1; s++ {
+		if s <= last {
+			continue
+		}
+
+		want, err := totp.GenerateCodeCustom(secret, time.Unix(s*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// generateRecoveryCodes returns recovery codes and their hashes
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err = rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(enc.EncodeToString(b))
+		var parts []string
+		for len(raw) > 4 {
+			parts = append(parts, raw[:4])
+			raw = raw[4:]
+		}
+		code := strings.Join(append(parts, raw), "-")
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring case and dashes
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
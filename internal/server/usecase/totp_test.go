@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/pquerna/otp/totp"
+	"secret-keeper/internal/server/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUseCase_TOTP(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	username, sessionCtx := newTestUser(t, &u, "totp-user")
+	ctx := setHeader(context.Background())
+
+	if err = u.ConfirmTOTP(sessionCtx, "000000"); !errors.Is(err, ErrTOTPNotEnrolled) {
+		t.Fatalf("ConfirmTOTP() error = %v, want %v", err, ErrTOTPNotEnrolled)
+	}
+
+	e, err := u.EnrollTOTP(sessionCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("EnrollTOTP() got %d recovery codes, want %d", len(e.RecoveryCodes), recoveryCodeCount)
+	}
+
+	// not enabled until confirmed
+	if _, err = u.Auth(ctx, username, "password", ""); err != nil {
+		t.Fatalf("Auth() error = %v before ConfirmTOTP", err)
+	}
+
+	if err = u.ConfirmTOTP(sessionCtx, "not-a-code"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("ConfirmTOTP() error = %v, want %v", err, ErrInvalidCode)
+	}
+
+	code, err := totp.GenerateCode(e.Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = u.ConfirmTOTP(sessionCtx, code); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = u.EnrollTOTP(sessionCtx); !errors.Is(err, ErrTOTPEnabled) {
+		t.Errorf("EnrollTOTP() error = %v, want %v", err, ErrTOTPEnabled)
+	}
+
+	// the code used to confirm is not accepted again, the next one is
+	next, err := totp.GenerateCode(e.Secret, time.Now().Add(totpPeriod*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{name: "noCode", wantErr: ErrTOTPRequired},
+		{name: "invalidCode", code: "123", wantErr: ErrInvalidCode},
+		{name: "confirmCode", code: code, wantErr: ErrInvalidCode},
+		{name: "totpCode", code: next},
+		{name: "replayedCode", code: next, wantErr: ErrInvalidCode},
+		{name: "recoveryCode", code: strings.ToUpper(e.RecoveryCodes[0])},
+		{name: "usedRecoveryCode", code: e.RecoveryCodes[0], wantErr: ErrInvalidCode},
+		{name: "otherRecoveryCode", code: e.RecoveryCodes[1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := u.Auth(ctx, username, "password", tt.code)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Auth() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// a recovery code used at once by several logins lets only one in
+	results := make(chan error, 5)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			_, err := u.Auth(ctx, username, "password", e.RecoveryCodes[2])
+			results <- err
+		}()
+	}
+	var accepted int
+	for i := 0; i < cap(results); i++ {
+		if err := <-results; err == nil {
+			accepted++
+		} else if !errors.Is(err, ErrInvalidCode) {
+			t.Errorf("Auth() error = %v, want %v", err, ErrInvalidCode)
+		}
+	}
+	if accepted != 1 {
+		t.Errorf("Auth() accepted a recovery code %d times, want once", accepted)
+	}
+}
+
+func Test_generateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if len(code) != 19 || strings.Count(code, "-") != 3 {
+			t.Errorf("code %q, want xxxx-xxxx-xxxx-xxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q is repeated", code)
+		}
+		seen[code] = true
+
+		if hashes[i] != hashRecoveryCode(strings.ReplaceAll(code, "-", "")) {
+			t.Errorf("hash of %q does not ignore dashes", code)
+		}
+	}
+}
@@ -7,17 +7,66 @@ import (
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+	"secret-keeper/internal/server/audit"
+	"secret-keeper/internal/server/lockout"
+	"secret-keeper/internal/server/policy"
 	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/validate"
+	"secret-keeper/internal/server/watch"
+	"secret-keeper/pkg"
+	"time"
 )
 
 // IUseCase interface for UseCase
 type IUseCase interface {
 	Get(ctx context.Context, key string) (string, error)
-	Set(ctx context.Context, key, value string) error
-	Auth(ctx context.Context, username string, password string) (string, error)
+	Set(ctx context.Context, key, value string, expiresAt time.Time) error
+	Auth(ctx context.Context, username, password, code string) (string, error)
 	Register(ctx context.Context, username string, password string) (string, error)
-	GetAllNames(ctx context.Context) ([]string, error)
+	CurrentUser(ctx context.Context) (string, error)
+	GetAllNames(ctx context.Context) ([]SecretInfo, error)
+	ListSecrets(ctx context.Context, opts ListOptions) (SecretPage, error)
+	SetTags(ctx context.Context, key string, tags []string) error
+	BatchGet(ctx context.Context, keys []string) ([]BatchResult, error)
+	BatchSet(ctx context.Context, items []BatchItem, transactional bool) ([]BatchResult, error)
+	BatchDelete(ctx context.Context, keys []string) ([]BatchResult, error)
+	Upload(ctx context.Context, key, sum string, next func() ([]byte, error)) (FileInfo, error)
+	Download(ctx context.Context, key string, start func(FileInfo) error, send func([]byte) error) error
+	ListFiles(ctx context.Context) ([]FileInfo, error)
+	DeleteFile(ctx context.Context, key string) error
 	Delete(ctx context.Context, key string) error
+	AuditLog(ctx context.Context, limit int) ([]audit.Entry, error)
+	Unlock(ctx context.Context, username, peerIP string) (bool, error)
+	EnrollTOTP(ctx context.Context) (Enrollment, error)
+	ConfirmTOTP(ctx context.Context, code string) error
+	ChangePassword(ctx context.Context, oldPassword, newPassword string) (int, error)
+	DeleteAccount(ctx context.Context, password, code string) error
+	GetUsage(ctx context.Context) (Usage, error)
+	ShareSecret(ctx context.Context, key, recipient, mode string) error
+	RevokeShare(ctx context.Context, key, recipient string) (bool, error)
+	ListSharedWithMe(ctx context.Context) ([]storage.Share, error)
+	ListSharedByMe(ctx context.Context) ([]storage.Share, error)
+	GetShared(ctx context.Context, owner, key string) (string, error)
+	SetShared(ctx context.Context, owner, key, value string, expiresAt time.Time) error
+	CreateTeam(ctx context.Context, team string) error
+	DeleteTeam(ctx context.Context, team string) error
+	ListTeams(ctx context.Context) ([]Membership, error)
+	GetTeam(ctx context.Context, team string) (storage.Team, error)
+	SetTeamMember(ctx context.Context, team, member, role string) error
+	RemoveTeamMember(ctx context.Context, team, member string) error
+	TeamGet(ctx context.Context, team, key string) (string, error)
+	TeamSet(ctx context.Context, team, key, value string, expiresAt time.Time) error
+	TeamDelete(ctx context.Context, team, key string) error
+	TeamGetAllNames(ctx context.Context, team string) ([]SecretInfo, error)
+	PutPolicy(ctx context.Context, p policy.Policy) error
+	GetPolicy(ctx context.Context, name string) (policy.Policy, error)
+	DeletePolicy(ctx context.Context, name string) error
+	ListPolicies(ctx context.Context) ([]policy.Policy, error)
+	CheckPermission(ctx context.Context, username, key, capability string) (policy.Decision, error)
+	CreateShareLink(ctx context.Context, key string, ttl time.Duration, maxViews int, passphrase string) (ShareLink, error)
+	RedeemShareLink(ctx context.Context, token, passphrase string) (RedeemedLink, error)
+	Watch(ctx context.Context, key string, prefix bool, after uint64, send func(watch.Event) error) error
+	AuditReady() error
 }
 
 // ErrInvalidToken is returned when token is invalid
@@ -28,33 +77,101 @@ var ErrInvalidPassword = errors.New("invalid password")
 
 // UseCase logic layer
 type UseCase struct {
-	storage *storage.Storage
+	storage    *storage.Storage
+	logger     pkg.Logger
+	auditLog   *audit.Log
+	limiter    *lockout.Limiter
+	validator  *validate.Validator
+	quota      Quota
+	adminToken string
+
+	expiryWarning time.Duration
+	watch         *watch.Hub
+	maxFileSize   int64
+
+	// policyCache holds the parsed policies of their last version
+	policyCache policyCache
+}
+
+// Option configures the UseCase
+type Option func(u *UseCase)
+
+// WithAudit records the outcome of every operation to the audit log
+func WithAudit(l *audit.Log) Option {
+	return func(u *UseCase) {
+		u.auditLog = l
+	}
+}
+
+// WithLimiter delays and locks out logins after failed attempts
+func WithLimiter(l *lockout.Limiter) Option {
+	return func(u *UseCase) {
+		u.limiter = l
+	}
+}
+
+// WithValidator rejects usernames, passwords and secrets breaking the rules
+func WithValidator(v *validate.Validator) Option {
+	return func(u *UseCase) {
+		u.validator = v
+	}
+}
+
+// WithAdminToken enables admin calls made with the token
+func WithAdminToken(token string) Option {
+	return func(u *UseCase) {
+		u.adminToken = token
+	}
 }
 
 // New UseCase constructor
-func New(storage *storage.Storage) (*UseCase, error) {
-	return &UseCase{
+func New(storage *storage.Storage, logger pkg.Logger, opts ...Option) (*UseCase, error) {
+	u := &UseCase{
 		storage: storage,
-	}, nil
+		logger:  logger,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	return u, nil
 }
 
 // GetAllNames gets all names
-func (u *UseCase) GetAllNames(ctx context.Context) ([]string, error) {
+func (u *UseCase) GetAllNames(ctx context.Context) ([]SecretInfo, error) {
 	username, err := u.getUsernameFromContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getFromContext: %w", err)
 	}
 
-	return u.storage.GetAllNames(ctx, username)
+	names, err := u.storage.GetAllNames(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if names, err = u.filterNames(ctx, username, names); err != nil {
+		return nil, err
+	}
+	return u.secretInfos(ctx, storage.Vault{User: username}, names)
 }
 
 // Get gets value for key
-func (u *UseCase) Get(ctx context.Context, key string) (string, error) {
-	username, err := u.getUsernameFromContext(ctx)
+func (u *UseCase) Get(ctx context.Context, key string) (_ string, err error) {
+	var username string
+	defer u.record(ctx, audit.ActionGet, &username, key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
 	if err != nil {
 		return "", fmt.Errorf("getFromContext: %w", err)
 	}
 
+	if err = u.authorize(ctx, username, key, policy.Read); err != nil {
+		return "", err
+	}
+
+	if err = u.checkExpiry(ctx, storage.Vault{User: username}, key); err != nil {
+		return "", fmt.Errorf("get: %w", err)
+	}
+
 	val, err := u.storage.Get(ctx, username, key)
 	if err != nil {
 		return "", fmt.Errorf("get: %w", err)
@@ -62,40 +179,81 @@ func (u *UseCase) Get(ctx context.Context, key string) (string, error) {
 	return val, nil
 }
 
-// Set sets value for key
-func (u *UseCase) Set(ctx context.Context, key, value string) error {
-	username, err := u.getUsernameFromContext(ctx)
+// Set sets value for key. A zero expiresAt makes it never expire.
+func (u *UseCase) Set(ctx context.Context, key, value string, expiresAt time.Time) (err error) {
+	var username string
+	defer u.record(ctx, audit.ActionSet, &username, key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
 	if err != nil {
 		return fmt.Errorf("getFromContext: %w", err)
 	}
 
-	return u.storage.Set(ctx, username, key, value)
+	if err = u.authorize(ctx, username, key, policy.Write); err != nil {
+		return err
+	}
+
+	if err = u.validator.Secret(key, value); err != nil {
+		return err
+	}
+
+	if err = u.checkQuota(ctx, username, key, value); err != nil {
+		return err
+	}
+
+	if err = u.setExpiry(ctx, storage.Vault{User: username}, key, expiresAt); err != nil {
+		return err
+	}
+
+	change := u.changeOf(ctx, username, key)
+	if err = u.storage.Set(ctx, username, key, value); err != nil {
+		return err
+	}
+	u.publish(username, key, change)
+	return nil
 }
 
 // Register registers user
-func (u *UseCase) Register(ctx context.Context, username string, password string) (string, error) {
+func (u *UseCase) Register(ctx context.Context, username string, password string) (_ string, err error) {
+	pkg.AddLoggerFields(ctx, pkg.String("user", username))
+	defer u.record(ctx, audit.ActionRegister, &username, "", &err)
+
+	if err = u.validator.Credentials(username, password); err != nil {
+		return "", err
+	}
+
+	// the user has to be added before a token is issued, otherwise
+	// registering an existing username would hand out a token of its owner
+	if err = u.storage.AddUser(ctx, username, password); err != nil {
+		return "", err
+	}
+
 	ok, token, err := getOrCreateToken(ctx)
 	if err != nil {
 		return "", fmt.Errorf("getOrCreateToken: %w", err)
 	}
 
 	if !ok {
-		err = u.storeToken(ctx, token, username)
-		if err != nil {
-			return token, fmt.Errorf("storeToken: %w", err)
+		if err = u.storeToken(ctx, token, username); err != nil {
+			return "", fmt.Errorf("storeToken: %w", err)
 		}
 	}
 
-	err = u.storage.AddUser(ctx, username, password)
-	if err != nil {
-		return token, err
-	}
-
+	u.log(ctx).Info("user registered")
 	return token, nil
 }
 
-// Auth authenticates user
-func (u *UseCase) Auth(ctx context.Context, username string, password string) (string, error) {
+// Auth authenticates user. If the user has two-factor authentication
+// enabled code must be a TOTP or recovery code.
+func (u *UseCase) Auth(ctx context.Context, username, password, code string) (_ string, err error) {
+	pkg.AddLoggerFields(ctx, pkg.String("user", username))
+	defer u.record(ctx, audit.ActionAuth, &username, "", &err)
+
+	// check the credentials before a token is issued
+	if err = u.checkCredentials(ctx, username, password, code); err != nil {
+		return "", err
+	}
+
 	ok, token, err := getOrCreateToken(ctx)
 	if err != nil {
 		return "", fmt.Errorf("getOrCreateToken: %w", err)
@@ -114,27 +272,190 @@ func (u *UseCase) Auth(ctx context.Context, username string, password string) (s
 		return "", fmt.Errorf("validateToken: %w", ErrInvalidToken)
 	}
 
-	// check if password is correct
-	var passwordFromDB string
-	if passwordFromDB, err = u.storage.GetPassword(ctx, username); err != nil { // TODO: index.Cmp(attrName, strToCmp)
-		return "", fmt.Errorf("GetPassword: %w", err)
+	return token, nil
+}
+
+// CurrentUser returns the user the token of ctx belongs to, failing with
+// ErrInvalidToken if it belongs to none
+func (u *UseCase) CurrentUser(ctx context.Context) (string, error) {
+	username, err := u.getUsernameFromContext(ctx)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", ErrInvalidToken
+	}
+	return username, err
+}
+
+// checkCredentials checks the password and, if two-factor authentication
+// is enabled, the code of the user. Failures are counted by the limiter.
+func (u *UseCase) checkCredentials(ctx context.Context, username, password, code string) error {
+	keys := loginKeys(ctx, username)
+	if wait := u.limiter.Wait(keys...); wait > 0 {
+		u.log(ctx).Warn("authentication locked", pkg.Duration("retry_after", wait))
+		return &LockedError{RetryAfter: wait}
+	}
+
+	passwordFromDB, err := u.storage.GetPassword(ctx, username) // TODO: index.Cmp(attrName, strToCmp)
+	if errors.Is(err, storage.ErrNotFound) {
+		u.limiter.Fail(keys...)
+		return fmt.Errorf("GetPassword: %w", err)
+	} else if err != nil {
+		return fmt.Errorf("GetPassword: %w", err)
 	}
 
 	if passwordFromDB != password {
-		return "", ErrInvalidPassword
+		wait := u.limiter.Fail(keys...)
+		u.log(ctx).Warn("authentication failed", pkg.Err(ErrInvalidPassword), pkg.Duration("retry_after", wait))
+		return ErrInvalidPassword
 	}
 
-	return token, nil
+	if err = u.checkSecondFactor(ctx, username, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			wait := u.limiter.Fail(keys...)
+			u.log(ctx).Warn("authentication failed", pkg.Err(err), pkg.Duration("retry_after", wait))
+		}
+		return err
+	}
+
+	u.limiter.Reset(userKey(username))
+	return nil
+}
+
+// DeleteAccount deletes the user with all their secrets and sessions and
+// removes them from their teams and policies. The user has to authenticate
+// again with password and, if enabled, code.
+func (u *UseCase) DeleteAccount(ctx context.Context, password, code string) (err error) {
+	var username string
+	defer u.record(ctx, audit.ActionDeleteAccount, &username, "", &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if err = u.checkCredentials(ctx, username, password, code); err != nil {
+		return err
+	}
+
+	// teams the user is the only owner of must be handed over first
+	if err = u.leaveTeams(ctx, username, true); err != nil {
+		return err
+	}
+
+	// sessions go first, so that none of them can write to the account
+	// while it is being purged
+	revoked, err := u.storage.RevokeTokens(ctx, username, "")
+	if err != nil {
+		return fmt.Errorf("RevokeTokens: %w", err)
+	}
+
+	all := func(storage.Share) bool { return false }
+	if _, err = u.storage.RemoveShares(ctx, username, "", all); err != nil {
+		return fmt.Errorf("RemoveShares: %w", err)
+	}
+	if _, err = u.storage.RemoveShares(ctx, "", username, all); err != nil {
+		return fmt.Errorf("RemoveShares: %w", err)
+	}
+	_, err = u.storage.RemoveLinks(ctx, func(l storage.Link) bool {
+		return l.Owner != username
+	})
+	if err != nil {
+		return fmt.Errorf("RemoveLinks: %w", err)
+	}
+
+	if err = u.leaveTeams(ctx, username, false); err != nil {
+		return err
+	}
+	if err = u.detachPolicies(ctx, username); err != nil {
+		return err
+	}
+
+	if err = u.storage.DeleteUser(ctx, username); err != nil {
+		return fmt.Errorf("DeleteUser: %w", err)
+	}
+
+	u.log(ctx).Info("account deleted", pkg.String("revoked_sessions", fmt.Sprint(revoked)))
+	return nil
+}
+
+// ChangePassword replaces the password of the user after checking the old
+// one and revokes all sessions except the current one, returning how many
+// were revoked. Secrets are stored as given rather than encrypted with a
+// key derived from the password, so they need no re-keying.
+func (u *UseCase) ChangePassword(ctx context.Context, oldPassword, newPassword string) (revoked int, err error) {
+	var username string
+	defer u.record(ctx, audit.ActionChangePassword, &username, "", &err)
+
+	username, err = u.getUsernameFromContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if err = u.validator.Password("new_password", newPassword); err != nil {
+		return 0, err
+	}
+
+	keys := loginKeys(ctx, username)
+	if wait := u.limiter.Wait(keys...); wait > 0 {
+		return 0, &LockedError{RetryAfter: wait}
+	}
+
+	passwordFromDB, err := u.storage.GetPassword(ctx, username)
+	if err != nil {
+		return 0, fmt.Errorf("GetPassword: %w", err)
+	}
+	if passwordFromDB != oldPassword {
+		wait := u.limiter.Fail(keys...)
+		u.log(ctx).Warn("password change failed", pkg.Err(ErrInvalidPassword), pkg.Duration("retry_after", wait))
+		return 0, ErrInvalidPassword
+	}
+
+	if err = u.storage.SetPassword(ctx, username, newPassword); err != nil {
+		return 0, fmt.Errorf("SetPassword: %w", err)
+	}
+
+	_, token, err := getOrCreateToken(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("getOrCreateToken: %w", err)
+	}
+	if revoked, err = u.storage.RevokeTokens(ctx, username, token); err != nil {
+		return revoked, fmt.Errorf("RevokeTokens: %w", err)
+	}
+
+	u.log(ctx).Info("password changed", pkg.String("revoked_sessions", fmt.Sprint(revoked)))
+	return revoked, nil
 }
 
 // Delete deletes value for key
-func (u *UseCase) Delete(ctx context.Context, key string) error {
-	username, err := u.getUsernameFromContext(ctx)
+func (u *UseCase) Delete(ctx context.Context, key string) (err error) {
+	var username string
+	defer u.record(ctx, audit.ActionDelete, &username, key, &err)
+
+	username, err = u.getUsernameFromContext(ctx)
 	if err != nil {
 		return fmt.Errorf("getFromContext: %w", err)
 	}
 
-	return u.storage.Delete(ctx, username, key)
+	if err = u.authorize(ctx, username, key, policy.Delete); err != nil {
+		return err
+	}
+
+	if err = u.storage.Delete(ctx, username, key); err != nil {
+		return err
+	}
+
+	if err = u.storage.SetExpiry(ctx, storage.Vault{User: username}, key, time.Time{}); err != nil {
+		return fmt.Errorf("SetExpiry: %w", err)
+	}
+
+	u.publish(username, key, watch.Deleted)
+
+	_, err = u.storage.RemoveShares(ctx, username, "", func(sh storage.Share) bool {
+		return sh.Key != key
+	})
+	if err != nil {
+		return fmt.Errorf("RemoveShares: %w", err)
+	}
+	return nil
 }
 
 // validateToken validates token
@@ -148,7 +469,8 @@ func (u *UseCase) validateToken(ctx context.Context, token string) (ok bool, err
 
 // storeToken stores token
 func (u *UseCase) storeToken(ctx context.Context, token, username string) error {
-	fmt.Println(token, username)
+	u.log(ctx).Info("session issued", pkg.String("token", pkg.RedactToken(token)))
+
 	// create a header that the gateway will watch for
 	header := metadata.Pairs("token", token)
 	// send the header back to the gateway
@@ -198,6 +520,11 @@ func (u *UseCase) getUsernameFromContext(ctx context.Context) (username string,
 	if err != nil {
 		return "", fmt.Errorf("getUsername: %w", err)
 	}
+	pkg.AddLoggerFields(ctx, pkg.String("user", username))
 
 	return username, nil
 }
+
+func (u *UseCase) log(ctx context.Context) pkg.Logger {
+	return pkg.LoggerFromContext(ctx, u.logger)
+}
@@ -3,11 +3,13 @@ package usecase
 import (
 	"context"
 	"errors"
+	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 	"secret-keeper/internal/server/storage"
 	"secret-keeper/pkg"
 	"testing"
+	"time"
 )
 
 func TestUseCase_Auth(t *testing.T) {
@@ -71,25 +73,24 @@ func TestUseCase_Auth(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			u := UseCase{storage: store}
 
-			var token string
 			if !tt.wantErr {
-				token, err = u.Register(tt.args.ctx, tt.args.username, tt.args.password)
+				_, err = u.Register(tt.args.ctx, tt.args.username, tt.args.password)
 				if err != nil && !errors.Is(err, storage.ErrAlreadyExists) {
 					t.Error(err)
 				}
 
-				err := u.storage.AddToken(tt.args.ctx, token, tt.args.username)
+				err := u.storage.AddToken(tt.args.ctx, tt.token, tt.args.username)
 				if err != nil {
 					t.Error(err)
 				}
 			}
-			got, err := u.Auth(tt.args.ctx, tt.args.username, tt.args.password)
+			got, err := u.Auth(tt.args.ctx, tt.args.username, tt.args.password, "")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Auth() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !tt.wantErr && got != token {
-				t.Errorf("Auth() got = %v, want %v", got, token)
+			if !tt.wantErr && got != tt.token {
+				t.Errorf("Auth() got = %v, want %v", got, tt.token)
 			}
 		})
 	}
@@ -127,6 +128,19 @@ func setHeader(ctx context.Context) context.Context {
 	return grpc.NewContextWithServerTransportStream(ctx, &streamerStub{})
 }
 
+// newTestUser registers prefix-<uuid> with the password "password" and
+// returns the username and a context with its token
+func newTestUser(t *testing.T, u *UseCase, prefix string) (string, context.Context) {
+	t.Helper()
+
+	username := prefix + "-" + uuid.NewString()
+	token, err := u.Register(setHeader(context.Background()), username, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return username, setHeader(setToken(context.Background(), token))
+}
+
 func TestUseCase_Delete(t *testing.T) {
 	store, err := storage.New(storage.Config{URI: ":800"})
 	if err != nil {
@@ -182,7 +196,7 @@ func TestUseCase_Delete(t *testing.T) {
 					t.Error(err)
 				}
 
-				err = u.Set(tt.args.ctx, tt.args.key, tt.username)
+				err = u.Set(tt.args.ctx, tt.args.key, tt.username, time.Time{})
 				if err != nil {
 					t.Error(err)
 				}
@@ -257,7 +271,7 @@ func TestUseCase_Get(t *testing.T) {
 					t.Error(err)
 				}
 
-				err = u.Set(tt.args.ctx, tt.args.key, tt.want)
+				err = u.Set(tt.args.ctx, tt.args.key, tt.want, time.Time{})
 				if err != nil {
 					t.Error(err)
 				}
@@ -344,18 +358,22 @@ func TestUseCase_GetAllNames(t *testing.T) {
 				}
 
 				for _, name := range tt.want {
-					err = u.Set(tt.args.ctx, name, tt.username)
+					err = u.Set(tt.args.ctx, name, tt.username, time.Time{})
 					if err != nil {
 						t.Error(err)
 					}
 				}
 			}
 
-			got, err := u.GetAllNames(tt.args.ctx)
+			infos, err := u.GetAllNames(tt.args.ctx)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetAllNames() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			got := make([]string, 0, len(infos))
+			for _, info := range infos {
+				got = append(got, info.Name)
+			}
 			if !pkg.IsTheSameArray(got, tt.want) {
 				t.Errorf("GetAllNames() got = %v, want %v", got, tt.want)
 			}
@@ -429,6 +447,12 @@ func TestUseCase_Register(t *testing.T) {
 					t.Errorf("Register() error = %v, wantErr %v", err, tt.wantErr)
 					return
 				}
+				// the user is left from an earlier run, no token is issued
+				// for an existing user
+				if got != "" {
+					t.Errorf("Register() token = %v for an existing user, want none", got)
+				}
+				return
 			}
 
 			if !tt.wantErr {
@@ -437,7 +461,7 @@ func TestUseCase_Register(t *testing.T) {
 					t.Error(err)
 				}
 
-				token, err := u.Auth(tt.args.ctx, tt.args.username, tt.args.password)
+				token, err := u.Auth(tt.args.ctx, tt.args.username, tt.args.password, "")
 				if err != nil {
 					t.Error(err)
 				}
@@ -449,6 +473,37 @@ func TestUseCase_Register(t *testing.T) {
 	}
 }
 
+// headerRecorder records the headers sent by the usecase
+type headerRecorder struct {
+	streamerStub
+	header metadata.MD
+}
+
+func (s *headerRecorder) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestUseCase_Register_existing(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	username, _ := newTestUser(t, &u, "existing")
+
+	// registering the username again must not issue a token of its owner
+	rec := &headerRecorder{}
+	token, err := u.Register(grpc.NewContextWithServerTransportStream(context.Background(), rec), username, "other")
+	if !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("Register() error = %v, want %v", err, storage.ErrAlreadyExists)
+	}
+	if token != "" || len(rec.header.Get("token")) != 0 {
+		t.Errorf("Register() issued token %q, header %v, want none", token, rec.header)
+	}
+}
+
 func TestUseCase_Set(t *testing.T) {
 	store, err := storage.New(storage.Config{URI: ":800"})
 	if err != nil {
@@ -523,7 +578,7 @@ func TestUseCase_Set(t *testing.T) {
 				}
 			}
 
-			if err = u.Set(tt.args.ctx, tt.args.key, tt.args.value); (err != nil) != tt.wantErr {
+			if err = u.Set(tt.args.ctx, tt.args.key, tt.args.value, time.Time{}); (err != nil) != tt.wantErr {
 				t.Errorf("Set() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
@@ -829,3 +884,97 @@ func Test_getOrCreateToken(t *testing.T) {
 		})
 	}
 }
+
+func TestUseCase_ChangePassword(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	username, ctx := newTestUser(t, &u, "change-password")
+	other, err := u.Auth(setHeader(context.Background()), username, "password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = u.ChangePassword(ctx, "wrong-password", "new-password"); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("ChangePassword() error = %v, want %v", err, ErrInvalidPassword)
+	}
+
+	revoked, err := u.ChangePassword(ctx, "password", "new-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != 1 {
+		t.Errorf("ChangePassword() revoked = %d, want 1", revoked)
+	}
+
+	if _, err = store.GetUsername(ctx, other); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("other session error = %v, want %v", err, storage.ErrNotFound)
+	}
+	if got, err := u.CurrentUser(ctx); err != nil || got != username {
+		t.Errorf("current session = %v, %v, want %v", got, err, username)
+	}
+
+	if _, err = u.Auth(setHeader(context.Background()), username, "password", ""); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Auth() with old password error = %v, want %v", err, ErrInvalidPassword)
+	}
+	if _, err = u.Auth(setHeader(context.Background()), username, "new-password", ""); err != nil {
+		t.Errorf("Auth() with new password error = %v", err)
+	}
+}
+
+func TestUseCase_DeleteAccount(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	username, ctx := newTestUser(t, &u, "delete-account")
+	other, err := u.Auth(setHeader(context.Background()), username, "password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = u.Set(ctx, "key", "value", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = u.DeleteAccount(ctx, "wrong-password", ""); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("DeleteAccount() error = %v, want %v", err, ErrInvalidPassword)
+	}
+	if err = u.DeleteAccount(ctx, "password", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sessionCtx := range []context.Context{ctx, setHeader(setToken(context.Background(), other))} {
+		if _, err = u.CurrentUser(sessionCtx); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("session error = %v, want %v", err, ErrInvalidToken)
+		}
+	}
+	if _, err = store.GetPassword(ctx, username); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetPassword() error = %v, want %v", err, storage.ErrNotFound)
+	}
+	if _, err = store.Get(ctx, username, "key"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Get() error = %v, want %v", err, storage.ErrNotFound)
+	}
+}
+
+func TestUseCase_CurrentUser(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := UseCase{storage: store}
+
+	username, ctx := newTestUser(t, &u, "current")
+
+	if got, err := u.CurrentUser(ctx); err != nil || got != username {
+		t.Errorf("CurrentUser() = %q, %v, want %q", got, err, username)
+	}
+	if _, err = u.CurrentUser(setHeader(setToken(context.Background(), "unknown-"+uuid.NewString()))); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("CurrentUser() error = %v, want %v for an unknown token", err, ErrInvalidToken)
+	}
+}
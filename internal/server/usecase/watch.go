@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"secret-keeper/internal/server/policy"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/watch"
+)
+
+// WithWatch publishes the changes of the secrets of users to hub
+func WithWatch(hub *watch.Hub) Option {
+	return func(u *UseCase) {
+		u.watch = hub
+	}
+}
+
+// Watch calls send with the changes of key of the user, or with prefix set
+// of every key starting with it, after the revision after until ctx is done
+// or send fails. A zero after watches new changes only. Changes of keys the
+// user may not read are left out.
+func (u *UseCase) Watch(ctx context.Context, key string, prefix bool, after uint64, send func(watch.Event) error) error {
+	username, err := u.getUsernameFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getFromContext: %w", err)
+	}
+
+	if !prefix {
+		if err = u.authorize(ctx, username, key, policy.Read); err != nil {
+			return err
+		}
+	}
+
+	sub, err := u.watch.Subscribe(watch.Filter{User: username, Key: key, Prefix: prefix}, after)
+	if err != nil {
+		return err
+	}
+	defer u.watch.Unsubscribe(sub)
+
+	deliver := func(e watch.Event) error {
+		if prefix {
+			d, err := u.evaluate(ctx, username, e.Key, policy.Read)
+			if err != nil {
+				return err
+			}
+			if !d.Allowed {
+				return nil
+			}
+		}
+		return send(e)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e := <-sub.Events():
+			if err = deliver(e); err != nil {
+				return err
+			}
+		case <-sub.Done():
+			// the events received before it stopped are still delivered,
+			// so that the watch can be resumed after the last one
+			for {
+				select {
+				case e := <-sub.Events():
+					if err = deliver(e); err != nil {
+						return err
+					}
+				default:
+					return sub.Err()
+				}
+			}
+		}
+	}
+}
+
+// changeOf tells whether setting key of the user creates or updates it.
+// Nothing is read unless changes are watched.
+func (u *UseCase) changeOf(ctx context.Context, username, key string) string {
+	if u.watch == nil {
+		return ""
+	}
+
+	_, err := u.storage.Get(ctx, username, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return watch.Created
+	}
+	return watch.Updated
+}
+
+// publish tells the watchers of the user about a change of key
+func (u *UseCase) publish(username, key, change string) {
+	u.watch.Publish(watch.Event{User: username, Key: key, Type: change})
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"errors"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/watch"
+	"testing"
+	"time"
+)
+
+func TestUseCase_Watch(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := watch.New(10)
+	u := UseCase{storage: store, watch: hub}
+
+	username, ctx := newTestUser(t, &u, "watch")
+
+	sub, err := hub.Subscribe(watch.Filter{User: username, Prefix: true}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Unsubscribe(sub)
+
+	if err = u.Set(ctx, "app/db", "secret", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.Set(ctx, "other", "secret", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.Set(ctx, "app/db", "rotated", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.Delete(ctx, "app/db"); err != nil {
+		t.Fatal(err)
+	}
+
+	var published []watch.Event
+	for len(published) < 4 {
+		select {
+		case e := <-sub.Events():
+			published = append(published, e)
+		default:
+			t.Fatalf("published %+v, want 4 events", published)
+		}
+	}
+	wantTypes := []string{watch.Created, watch.Created, watch.Updated, watch.Deleted}
+	for i, e := range published {
+		if e.Type != wantTypes[i] {
+			t.Errorf("event %d = %s %s, want %s", i, e.Type, e.Key, wantTypes[i])
+		}
+	}
+
+	// resuming after the first event replays the changes of app/ since
+	stop := errors.New("stop")
+	var got []watch.Event
+	err = u.Watch(ctx, "app/", true, published[0].Revision, func(e watch.Event) error {
+		got = append(got, e)
+		if len(got) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("Watch() error = %v, want %v", err, stop)
+	}
+	if got[0].Revision != published[2].Revision || got[1].Type != watch.Deleted {
+		t.Errorf("Watch() got %+v, want the update and delete of app/db", got)
+	}
+
+	if err = u.Watch(ctx, "app/db", false, 1, nil); !errors.Is(err, watch.ErrCompacted) {
+		t.Errorf("Watch() error = %v, want %v", err, watch.ErrCompacted)
+	}
+}
@@ -0,0 +1,174 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalid is wrapped by every validation Error
+var ErrInvalid = errors.New("invalid argument")
+
+// Violation is a field that failed validation
+type Violation struct {
+	Field       string
+	Description string
+}
+
+// Error lists the fields that failed validation
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Field+": "+v.Description)
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalid, strings.Join(parts, "; "))
+}
+
+func (e *Error) Unwrap() error {
+	return ErrInvalid
+}
+
+// Config of the rules
+type Config struct {
+	// UsernamePattern is a regular expression a username has to match.
+	UsernamePattern string
+	// PasswordMinLength and PasswordMaxLength limit the number of
+	// characters of a password.
+	PasswordMinLength int
+	PasswordMaxLength int
+	// KeyMaxLength limits the number of characters of a secret name.
+	KeyMaxLength int
+	// ValueMaxSize limits the size of a secret in bytes.
+	ValueMaxSize int
+}
+
+// Validator checks requests against the rules. A nil Validator accepts
+// everything.
+type Validator struct {
+	cfg      Config
+	username *regexp.Regexp
+}
+
+// New creates a Validator
+func New(cfg Config) (*Validator, error) {
+	username, err := regexp.Compile(cfg.UsernamePattern)
+	if err != nil {
+		return nil, fmt.Errorf("username pattern: %w", err)
+	}
+
+	return &Validator{cfg: cfg, username: username}, nil
+}
+
+// Credentials checks the username and the password of a new user
+func (v *Validator) Credentials(username, password string) error {
+	if v == nil {
+		return nil
+	}
+
+	return collect(
+		Violation{"username", v.checkUsername(username)},
+		Violation{"password", v.checkPassword(password)},
+	)
+}
+
+// Team checks the name of a new team, which follows the rules of usernames
+func (v *Validator) Team(name string) error {
+	if v == nil {
+		return nil
+	}
+
+	return collect(Violation{"team", v.checkUsername(name)})
+}
+
+// Password checks a new password sent in field
+func (v *Validator) Password(field, password string) error {
+	if v == nil {
+		return nil
+	}
+
+	return collect(Violation{field, v.checkPassword(password)})
+}
+
+// Secret checks the name and the value of a secret
+func (v *Validator) Secret(key, value string) error {
+	if v == nil {
+		return nil
+	}
+
+	return collect(
+		Violation{"key", v.checkKey(key)},
+		Violation{"value", v.checkValue(value)},
+	)
+}
+
+// Key checks the name of a secret
+func (v *Validator) Key(key string) error {
+	if v == nil {
+		return nil
+	}
+
+	return collect(Violation{"key", v.checkKey(key)})
+}
+
+func (v *Validator) checkUsername(username string) string {
+	if !v.username.MatchString(username) {
+		return fmt.Sprintf("must match %s", v.cfg.UsernamePattern)
+	}
+	return ""
+}
+
+func (v *Validator) checkPassword(password string) string {
+	n := utf8.RuneCountInString(password)
+	switch {
+	case n < v.cfg.PasswordMinLength:
+		return fmt.Sprintf("must be at least %d characters long", v.cfg.PasswordMinLength)
+	case v.cfg.PasswordMaxLength > 0 && n > v.cfg.PasswordMaxLength:
+		return fmt.Sprintf("must be at most %d characters long", v.cfg.PasswordMaxLength)
+	}
+	return ""
+}
+
+func (v *Validator) checkKey(key string) string {
+	switch {
+	case key == "":
+		return "must not be empty"
+	case !utf8.ValidString(key):
+		return "must be valid UTF-8"
+	case strings.IndexFunc(key, unicode.IsControl) >= 0:
+		return "must not contain control characters"
+	case strings.TrimSpace(key) != key:
+		return "must not start or end with whitespace"
+	case v.cfg.KeyMaxLength > 0 && utf8.RuneCountInString(key) > v.cfg.KeyMaxLength:
+		return fmt.Sprintf("must be at most %d characters long", v.cfg.KeyMaxLength)
+	}
+	return ""
+}
+
+func (v *Validator) checkValue(value string) string {
+	if v.cfg.ValueMaxSize > 0 && len(value) > v.cfg.ValueMaxSize {
+		return fmt.Sprintf("must be at most %d bytes", v.cfg.ValueMaxSize)
+	}
+	return ""
+}
+
+// collect returns an Error with the violations that have a description
+func collect(violations ...Violation) error {
+	var failed []Violation
+	for _, v := range violations {
+		if v.Description != "" {
+			failed = append(failed, v)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	return &Error{Violations: failed}
+}
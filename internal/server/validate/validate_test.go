@@ -0,0 +1,108 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestValidator(t *testing.T) *Validator {
+	t.Helper()
+
+	v, err := New(Config{
+		UsernamePattern:   `^[a-z0-9_]{3,16}$`,
+		PasswordMinLength: 8,
+		PasswordMaxLength: 16,
+		KeyMaxLength:      8,
+		ValueMaxSize:      4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValidator_Credentials(t *testing.T) {
+	v := newTestValidator(t)
+
+	tests := []struct {
+		name       string
+		username   string
+		password   string
+		wantFields []string
+	}{
+		{name: "ok", username: "alice", password: "password"},
+		{name: "emptyUsername", username: "", password: "password", wantFields: []string{"username"}},
+		{name: "badUsername", username: "Alice!", password: "password", wantFields: []string{"username"}},
+		{name: "shortPassword", username: "alice", password: "short", wantFields: []string{"password"}},
+		{name: "longPassword", username: "alice", password: strings.Repeat("p", 17), wantFields: []string{"password"}},
+		{name: "both", username: "a", password: "", wantFields: []string{"username", "password"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkFields(t, v.Credentials(tt.username, tt.password), tt.wantFields)
+		})
+	}
+}
+
+func TestValidator_Secret(t *testing.T) {
+	v := newTestValidator(t)
+
+	tests := []struct {
+		name       string
+		key        string
+		value      string
+		wantFields []string
+	}{
+		{name: "ok", key: "db", value: "1234"},
+		{name: "unicode", key: "пароль", value: ""},
+		{name: "emptyKey", key: "", value: "v", wantFields: []string{"key"}},
+		{name: "controlCharacter", key: "a\nb", value: "v", wantFields: []string{"key"}},
+		{name: "whitespace", key: " db", value: "v", wantFields: []string{"key"}},
+		{name: "invalidUTF8", key: "\xff", value: "v", wantFields: []string{"key"}},
+		{name: "longKey", key: "123456789", value: "v", wantFields: []string{"key"}},
+		{name: "largeValue", key: "db", value: "12345", wantFields: []string{"value"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkFields(t, v.Secret(tt.key, tt.value), tt.wantFields)
+		})
+	}
+}
+
+func TestValidator_nil(t *testing.T) {
+	var v *Validator
+	if err := v.Secret("", ""); err != nil {
+		t.Errorf("Secret() error = %v, want nil", err)
+	}
+}
+
+func TestNew_invalidPattern(t *testing.T) {
+	if _, err := New(Config{UsernamePattern: "("}); err == nil {
+		t.Error("New() error = nil, want error")
+	}
+}
+
+func checkFields(t *testing.T, err error, want []string) {
+	t.Helper()
+
+	if len(want) == 0 {
+		if err != nil {
+			t.Errorf("error = %v, want nil", err)
+		}
+		return
+	}
+
+	var verr *Error
+	if !errors.As(err, &verr) || !errors.Is(err, ErrInvalid) {
+		t.Fatalf("error = %v, want *Error", err)
+	}
+
+	var got []string
+	for _, v := range verr.Violations {
+		got = append(got, v.Field)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,226 @@
+package watch
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of events
+const (
+	Created = "created"
+	Updated = "updated"
+	Deleted = "deleted"
+)
+
+// ErrCompacted is returned when events after the revision to resume from
+// are no longer kept
+var ErrCompacted = errors.New("revision is compacted")
+
+// ErrLagged is returned when a subscriber did not keep up with the events
+// and has to resume from the last one it got
+var ErrLagged = errors.New("subscriber lagged behind")
+
+// ErrClosed is returned when the hub is closed
+var ErrClosed = errors.New("watch is closed")
+
+// subscriberBuffer is how many events a subscriber may be behind
+const subscriberBuffer = 64
+
+// Event is a change of the secret Key of User. Values are not part of
+// events, so that no secret is kept in memory.
+type Event struct {
+	Revision uint64
+	User     string
+	Key      string
+	Type     string
+	Time     time.Time
+}
+
+// Filter selects the events a subscriber gets
+type Filter struct {
+	User string
+	Key  string
+	// Prefix makes Key match every key starting with it
+	Prefix bool
+}
+
+func (f Filter) match(e Event) bool {
+	if e.User != f.User {
+		return false
+	}
+	if f.Prefix {
+		return strings.HasPrefix(e.Key, f.Key)
+	}
+	return e.Key == f.Key
+}
+
+// Hub keeps the recent events and hands them to subscribers. A nil Hub
+// drops every event.
+//
+// Revisions start at the start time of the hub in microseconds, so that a
+// revision from before a restart is always compacted rather than mistaken
+// for a new one.
+type Hub struct {
+	mu      sync.Mutex
+	history []Event
+	size    int
+	next    uint64
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// New creates a Hub keeping the last size events
+func New(size int) *Hub {
+	return &Hub{
+		size: size,
+		next: uint64(time.Now().UnixMicro()),
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the next revision to e and hands it to the subscribers.
+// Subscribers that are too far behind are dropped with ErrLagged.
+func (h *Hub) Publish(e Event) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	e.Revision = h.next
+	h.next++
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	if h.size > 0 {
+		if len(h.history) == h.size {
+			copy(h.history, h.history[1:])
+			h.history = h.history[:h.size-1]
+		}
+		h.history = append(h.history, e)
+	}
+
+	for s := range h.subs {
+		if !s.filter.match(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			h.drop(s, ErrLagged)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events matching filter after the
+// revision after. A zero after subscribes to new events only. ErrCompacted
+// is returned if some of the events after it are no longer kept.
+func (h *Hub) Subscribe(filter Filter, after uint64) (*Subscription, error) {
+	if h == nil {
+		return nil, ErrClosed
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrClosed
+	}
+
+	var backlog []Event
+	if after != 0 && after+1 < h.next {
+		if len(h.history) == 0 || h.history[0].Revision > after+1 {
+			return nil, ErrCompacted
+		}
+		for _, e := range h.history {
+			if e.Revision > after && filter.match(e) {
+				backlog = append(backlog, e)
+			}
+		}
+	} else if after >= h.next {
+		// from the future, most likely from before a restart of another
+		// instance
+		return nil, ErrCompacted
+	}
+
+	s := &Subscription{
+		filter: filter,
+		events: make(chan Event, len(backlog)+subscriberBuffer),
+		done:   make(chan struct{}),
+	}
+	for _, e := range backlog {
+		s.events <- e
+	}
+	h.subs[s] = struct{}{}
+	return s, nil
+}
+
+// Unsubscribe stops the subscription
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.drop(s, ErrClosed)
+}
+
+// Close stops every subscription with ErrClosed and drops the following
+// events
+func (h *Hub) Close() {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for s := range h.subs {
+		h.drop(s, ErrClosed)
+	}
+}
+
+func (h *Hub) drop(s *Subscription, err error) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	s.err = err
+	close(s.done)
+}
+
+// Subscription delivers the events of a subscriber
+type Subscription struct {
+	filter Filter
+	events chan Event
+	done   chan struct{}
+	// err is set before done is closed
+	err error
+}
+
+// Events returns the channel of the events
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Done is closed when the subscription stopped, Err then tells why
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the subscription stopped
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
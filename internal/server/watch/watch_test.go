@@ -0,0 +1,150 @@
+package watch
+
+import (
+	"errors"
+	"testing"
+)
+
+func receive(t *testing.T, s *Subscription, n int) []Event {
+	t.Helper()
+
+	events := make([]Event, 0, n)
+	for len(events) < n {
+		select {
+		case e := <-s.Events():
+			events = append(events, e)
+		default:
+			t.Fatalf("got %d events, want %d", len(events), n)
+		}
+	}
+	return events
+}
+
+func TestHub_Subscribe(t *testing.T) {
+	h := New(10)
+
+	all, err := h.Subscribe(Filter{User: "alice", Prefix: true}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := h.Subscribe(Filter{User: "alice", Key: "prod/db"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Publish(Event{User: "alice", Key: "prod/db", Type: Created})
+	h.Publish(Event{User: "bob", Key: "prod/db", Type: Created})
+	h.Publish(Event{User: "alice", Key: "prod/api", Type: Created})
+	h.Publish(Event{User: "alice", Key: "prod/db", Type: Deleted})
+
+	got := receive(t, all, 3)
+	if got[0].Key != "prod/db" || got[1].Key != "prod/api" || got[2].Type != Deleted {
+		t.Errorf("prefix subscription got %+v", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Revision <= got[i-1].Revision {
+			t.Errorf("revisions %d, %d are not increasing", got[i-1].Revision, got[i].Revision)
+		}
+	}
+
+	if got = receive(t, db, 2); got[0].Type != Created || got[1].Type != Deleted {
+		t.Errorf("key subscription got %+v", got)
+	}
+	if len(db.Events()) != 0 {
+		t.Errorf("key subscription got %d more events", len(db.Events()))
+	}
+}
+
+func TestHub_Subscribe_resume(t *testing.T) {
+	h := New(3)
+
+	s, err := h.Subscribe(Filter{User: "alice", Prefix: true}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		h.Publish(Event{User: "alice", Key: key, Type: Created})
+	}
+	events := receive(t, s, 4)
+	h.Unsubscribe(s)
+
+	tests := []struct {
+		name    string
+		after   uint64
+		want    []string
+		wantErr error
+	}{
+		{name: "latest", after: events[3].Revision},
+		{name: "kept", after: events[1].Revision, want: []string{"c", "d"}},
+		{name: "oldestKept", after: events[0].Revision, want: []string{"b", "c", "d"}},
+		{name: "compacted", after: events[0].Revision - 1, wantErr: ErrCompacted},
+		{name: "future", after: events[3].Revision + 1, wantErr: ErrCompacted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := h.Subscribe(Filter{User: "alice", Prefix: true}, tt.after)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Subscribe() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer h.Unsubscribe(s)
+
+			got := receive(t, s, len(tt.want))
+			for i := range tt.want {
+				if got[i].Key != tt.want[i] {
+					t.Errorf("event %d = %s, want %s", i, got[i].Key, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHub_Publish_lagged(t *testing.T) {
+	h := New(0)
+
+	s, err := h.Subscribe(Filter{User: "alice", Prefix: true}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= subscriberBuffer; i++ {
+		h.Publish(Event{User: "alice", Key: "k", Type: Updated})
+	}
+
+	select {
+	case <-s.Done():
+	default:
+		t.Fatal("subscription is not done")
+	}
+	if !errors.Is(s.Err(), ErrLagged) {
+		t.Errorf("Err() = %v, want %v", s.Err(), ErrLagged)
+	}
+	// the events before it lagged are still delivered
+	receive(t, s, subscriberBuffer)
+}
+
+func TestHub_Close(t *testing.T) {
+	h := New(10)
+
+	s, err := h.Subscribe(Filter{User: "alice"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	if !errors.Is(s.Err(), ErrClosed) {
+		t.Errorf("Err() = %v, want %v", s.Err(), ErrClosed)
+	}
+	if _, err = h.Subscribe(Filter{User: "alice"}, 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe() error = %v, want %v after Close", err, ErrClosed)
+	}
+}
+
+func TestHub_nil(t *testing.T) {
+	var h *Hub
+	h.Publish(Event{User: "alice", Key: "k"})
+	if _, err := h.Subscribe(Filter{User: "alice"}, 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe() error = %v, want %v", err, ErrClosed)
+	}
+}
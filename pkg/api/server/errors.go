@@ -0,0 +1,10 @@
+package server
+
+// ErrorDomain is the domain of the ErrorInfo details the server attaches to
+// errors
+const ErrorDomain = "secret-keeper"
+
+// ReasonCodeRequired is the reason of the ErrorInfo detail of an
+// Unauthenticated error of Auth or DeleteAccount when the user has
+// two-factor authentication enabled and sent no code
+const ReasonCodeRequired = "CODE_REQUIRED"
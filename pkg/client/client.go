@@ -0,0 +1,321 @@
+// Package client is the Go client of secret-keeper for services reading and
+// writing their secrets.
+//
+//	c, err := client.New("secrets.internal:8080", client.WithTLS(&tls.Config{}))
+//	if err != nil { ... }
+//	defer c.Close()
+//	if err = c.Login(ctx, "billing", password); err != nil { ... }
+//	dsn, err := c.Get(ctx, "prod/db/dsn")
+//
+// A Client is safe for concurrent use. Errors of calls are *Error values
+// matching the Err values with errors.Is.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"secret-keeper/pkg/api/server"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTimeout limits every call unless set with WithTimeout
+	DefaultTimeout = 10 * time.Second
+	// DefaultRetries is the number of retries of a call failing with
+	// ErrUnavailable unless set with WithRetries
+	DefaultRetries = 3
+	// DefaultBackoff is the delay before the first retry, doubled before
+	// every next one
+	DefaultBackoff = 100 * time.Millisecond
+)
+
+// tokenKey is the metadata key of the token, sent with every call and
+// received on Login
+const tokenKey = "token"
+
+// retryAfterKey is the metadata key telling how many seconds to wait
+// before the next login
+const retryAfterKey = "retry-after"
+
+type options struct {
+	tls         *tls.Config
+	timeout     time.Duration
+	retries     int
+	backoff     time.Duration
+	token       string
+	dialOptions []grpc.DialOption
+}
+
+// Option configures a Client
+type Option func(*options)
+
+// WithTLS connects with TLS configured by cfg. Without it the connection is
+// not encrypted.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tls = cfg
+	}
+}
+
+// WithTimeout limits every call to timeout, including its retries, unless
+// its context ends earlier. Zero means no limit.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetries retries calls failing with ErrUnavailable up to retries
+// times, waiting backoff before the first retry and twice as long before
+// every next one. A retried Set or Delete may have been applied already.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.retries = retries
+		o.backoff = backoff
+	}
+}
+
+// WithToken authenticates with a token issued before instead of Login
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithDialOptions adds options to the gRPC connection
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+// Secret describes a secret of a listing
+type Secret struct {
+	Name string
+	// ExpiresAt is zero for secrets that never expire
+	ExpiresAt time.Time
+	Tags      []string
+}
+
+// Client calls a secret-keeper server
+type Client struct {
+	conn *grpc.ClientConn
+	cl   server.SecretKeeperClient
+	opts options
+
+	mu    sync.RWMutex
+	token string
+}
+
+// New returns a client of the server at addr. It connects lazily, so an
+// unreachable server is only reported by the calls.
+func New(addr string, opts ...Option) (*Client, error) {
+	o := options{
+		timeout: DefaultTimeout,
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.retries < 0 || o.backoff < 0 || o.timeout < 0 {
+		return nil, fmt.Errorf("secret-keeper: retries, backoff and timeout must not be negative")
+	}
+
+	creds := insecure.NewCredentials()
+	if o.tls != nil {
+		creds = credentials.NewTLS(o.tls)
+	}
+	dialOptions := append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, o.dialOptions...)
+
+	conn, err := grpc.Dial(addr, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("secret-keeper: dial: %w", err)
+	}
+
+	return &Client{
+		conn:  conn,
+		cl:    server.NewSecretKeeperClient(conn),
+		opts:  o,
+		token: o.token,
+	}, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Token returns the token the client authenticates with, empty before
+// Login
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// SetToken makes the client authenticate with token
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// Login authenticates the client as username. It returns
+// ErrInvalidCredentials for a wrong username or password and
+// ErrCodeRequired for users with two-factor authentication.
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	return c.LoginWithCode(ctx, username, password, "")
+}
+
+// LoginWithCode authenticates the client as username with a TOTP or
+// recovery code
+func (c *Client) LoginWithCode(ctx context.Context, username, password, code string) error {
+	var header metadata.MD
+	var resp *server.AuthResponse
+	err := c.call(ctx, true, func(ctx context.Context) (err error) {
+		header = nil
+		resp, err = c.cl.Auth(ctx, &server.AuthRequest{Username: username, Password: password, Code: code}, grpc.Header(&header))
+		return err
+	})
+	if err != nil {
+		return toError(err, true, retryAfter(header))
+	}
+
+	token := resp.GetToken()
+	if values := header.Get(tokenKey); len(values) != 0 {
+		token = values[0]
+	}
+	if token == "" {
+		return &Error{Code: codes.Internal, Message: "no token in the response", kind: ErrUnauthenticated}
+	}
+
+	c.SetToken(token)
+	return nil
+}
+
+// Get returns the value of the secret key
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	var resp *server.GetResponse
+	err := c.call(ctx, false, func(ctx context.Context) (err error) {
+		resp, err = c.cl.Get(ctx, &server.GetRequest{Key: key})
+		return err
+	})
+	if err != nil {
+		return "", toError(err, false, 0)
+	}
+	return resp.GetValue(), nil
+}
+
+// Set sets the secret key to value, which never expires
+func (c *Client) Set(ctx context.Context, key, value string) error {
+	return c.set(ctx, &server.SetRequest{Key: key, Value: value})
+}
+
+// SetWithTTL sets the secret key to value, which expires after ttl
+func (c *Client) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	return c.set(ctx, &server.SetRequest{Key: key, Value: value, Ttl: durationpb.New(ttl)})
+}
+
+func (c *Client) set(ctx context.Context, req *server.SetRequest) error {
+	err := c.call(ctx, false, func(ctx context.Context) error {
+		_, err := c.cl.Set(ctx, req)
+		return err
+	})
+	return toError(err, false, 0)
+}
+
+// List returns the secrets whose names start with prefix, sorted by name
+func (c *Client) List(ctx context.Context, prefix string) ([]Secret, error) {
+	var secrets []Secret
+	req := &server.ListSecretsRequest{Prefix: prefix}
+	for {
+		var resp *server.ListSecretsResponse
+		err := c.call(ctx, false, func(ctx context.Context) (err error) {
+			resp, err = c.cl.ListSecrets(ctx, req)
+			return err
+		})
+		if err != nil {
+			return nil, toError(err, false, 0)
+		}
+
+		for _, s := range resp.GetSecrets() {
+			secret := Secret{Name: s.GetName(), Tags: s.GetTags()}
+			if s.GetExpiresAt() != nil {
+				secret.ExpiresAt = s.GetExpiresAt().AsTime()
+			}
+			secrets = append(secrets, secret)
+		}
+
+		if resp.GetNextPageToken() == "" {
+			return secrets, nil
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+}
+
+// Delete deletes the secret key
+func (c *Client) Delete(ctx context.Context, key string) error {
+	err := c.call(ctx, false, func(ctx context.Context) error {
+		_, err := c.cl.Delete(ctx, &server.DeleteRequest{Key: key})
+		return err
+	})
+	return toError(err, false, 0)
+}
+
+// call calls f with the token and the timeout, retrying it while the
+// server is unavailable. Logins are sent without a token.
+func (c *Client) call(ctx context.Context, login bool, f func(ctx context.Context) error) error {
+	if !login {
+		token := c.Token()
+		if token == "" {
+			return &Error{Code: codes.Unauthenticated, Message: "not logged in", kind: ErrUnauthenticated}
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, tokenKey, token)
+	}
+	if c.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeout)
+		defer cancel()
+	}
+
+	backoff := c.opts.backoff
+	for attempt := 0; ; attempt++ {
+		err := f(ctx)
+		if err == nil || attempt >= c.opts.retries || status.Code(err) != codes.Unavailable {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// retryAfter returns the delay of the retry-after header
+func retryAfter(header metadata.MD) time.Duration {
+	values := header.Get(retryAfterKey)
+	if len(values) == 0 {
+		return 0
+	}
+	seconds, err := strconv.Atoi(values[0])
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	grpchandler "secret-keeper/internal/server/handler/grpc"
+	"secret-keeper/internal/server/storage"
+	"secret-keeper/internal/server/usecase"
+	"secret-keeper/internal/server/validate"
+	"secret-keeper/pkg"
+	"secret-keeper/pkg/api/server"
+	"sync"
+	"testing"
+	"time"
+)
+
+// upTestServer serves srv and returns a client of it
+func upTestServer(t *testing.T, srv server.SecretKeeperServer, opts ...Option) *Client {
+	lis := bufconn.Listen(1024 * 1024)
+
+	grpcServer := grpc.NewServer()
+	server.RegisterSecretKeeperServer(grpcServer, srv)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+
+	opts = append(opts, WithDialOptions(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	})))
+	c, err := New("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClient(t *testing.T) {
+	store, err := storage.New(storage.Config{URI: ":800"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator, err := validate.New(validate.Config{KeyMaxLength: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logic, err := usecase.New(store, pkg.NewNop(), usecase.WithValidator(validator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := upTestServer(t, grpchandler.New(logic, pkg.NewNop()))
+	ctx := context.Background()
+
+	if _, err = c.Get(ctx, "db"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Get() error = %v, want %v before Login", err, ErrUnauthenticated)
+	}
+
+	username := "sdk-" + uuid.NewString()
+	if _, err = c.cl.Register(ctx, &server.RegisterRequest{Username: username, Password: "password"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Login(ctx, username, "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() error = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if err = c.Login(ctx, username, "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"app/db", "app/api", "other"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			if err := c.Set(ctx, key, "value of "+key); err != nil {
+				t.Errorf("Set(%s) error = %v", key, err)
+			}
+		}(key)
+	}
+	wg.Wait()
+	if err = c.SetWithTTL(ctx, "app/cache", "value", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := c.Get(ctx, "app/db"); err != nil || got != "value of app/db" {
+		t.Errorf("Get() = %q, %v, want the value set", got, err)
+	}
+
+	secrets, err := c.List(ctx, "app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 3 || secrets[0].Name != "app/api" || secrets[1].Name != "app/cache" || secrets[1].ExpiresAt.IsZero() {
+		t.Errorf("List() = %+v, want app/api, app/cache expiring and app/db", secrets)
+	}
+
+	if err = c.Delete(ctx, "app/db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get(ctx, "app/db"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() error = %v, want %v once deleted", err, ErrNotFound)
+	}
+
+	var e *Error
+	err = c.Set(ctx, string(make([]byte, 65)), "value")
+	if !errors.Is(err, ErrInvalidArgument) || !errors.As(err, &e) || len(e.Violations) == 0 || e.Violations[0].Field != "key" {
+		t.Errorf("Set() error = %#v, want %v with the key violation", err, ErrInvalidArgument)
+	}
+}
+
+// flakyServer is unavailable for its first failures calls
+type flakyServer struct {
+	server.UnimplementedSecretKeeperServer
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (s *flakyServer) Get(ctx context.Context, req *server.GetRequest) (*server.GetResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.calls <= s.failures {
+		return nil, status.Error(codes.Unavailable, "storage unavailable")
+	}
+	return &server.GetResponse{Value: "value"}, nil
+}
+
+func TestClient_retries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		retries   int
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "recovers", failures: 2, retries: 2, wantCalls: 3},
+		{name: "exhausted", failures: 3, retries: 2, wantErr: ErrUnavailable, wantCalls: 3},
+		{name: "noRetries", failures: 1, retries: 0, wantErr: ErrUnavailable, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &flakyServer{failures: tt.failures}
+			c := upTestServer(t, srv, WithToken("token"), WithRetries(tt.retries, time.Millisecond))
+
+			_, err := c.Get(context.Background(), "db")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+			if srv.calls != tt.wantCalls {
+				t.Errorf("Get() made %d calls, want %d", srv.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_toError_codeRequired(t *testing.T) {
+	st := status.New(codes.Unauthenticated, "two-factor code required")
+	st, err := st.WithDetails(&errdetails.ErrorInfo{Reason: server.ReasonCodeRequired, Domain: server.ErrorDomain})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = toError(st.Err(), true, 0); !errors.Is(err, ErrCodeRequired) {
+		t.Errorf("toError() = %v, want %v", err, ErrCodeRequired)
+	}
+	// the message alone does not tell that a code is required
+	err = toError(status.Error(codes.Unauthenticated, "two-factor code required"), true, 0)
+	if !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("toError() = %v, want %v without the detail", err, ErrUnauthenticated)
+	}
+}
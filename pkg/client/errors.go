@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"secret-keeper/pkg/api/server"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for missing secrets. The server also reports
+	// unknown tokens this way.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidCredentials is returned by Login for a wrong username or
+	// password
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrCodeRequired is returned by Login for users with two-factor
+	// authentication, see LoginWithCode
+	ErrCodeRequired = errors.New("two-factor code required")
+	// ErrUnauthenticated is returned when the client has no valid token
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrPermissionDenied is returned when a policy or role forbids a call
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrInvalidArgument is returned when the server rejects a field of the
+	// request, see Error.Violations
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrQuotaExceeded is returned when the user reached a storage limit
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrLocked is returned by Login after too many failed attempts, see
+	// Error.RetryAfter
+	ErrLocked = errors.New("locked out")
+	// ErrUnavailable is returned when the server cannot be reached, after the
+	// retries
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// Violation is a field of a request the server rejected
+type Violation struct {
+	Field       string
+	Description string
+}
+
+// Error is the error of a call. It matches the Err values of its kind with
+// errors.Is.
+type Error struct {
+	Code    codes.Code
+	Message string
+	// Violations are set with ErrInvalidArgument
+	Violations []Violation
+	// RetryAfter is set with ErrLocked
+	RetryAfter time.Duration
+
+	kind error
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Code.String()
+	}
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
+
+// GRPCStatus returns the status of the call, so that status.Code works on
+// e as on the errors of gRPC
+func (e *Error) GRPCStatus() *status.Status {
+	return status.New(e.Code, e.Message)
+}
+
+// toError converts the error of a call to an *Error, login tells that the
+// call was Login
+func toError(err error, login bool, retryAfter time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("secret-keeper: %w", err)
+	}
+
+	e := &Error{Code: st.Code(), Message: st.Message()}
+	switch st.Code() {
+	case codes.NotFound:
+		e.kind = ErrNotFound
+		if login {
+			e.kind = ErrInvalidCredentials
+		}
+	case codes.Unauthenticated:
+		e.kind = ErrUnauthenticated
+		if hasReason(st, server.ReasonCodeRequired) {
+			e.kind = ErrCodeRequired
+		}
+	case codes.PermissionDenied:
+		e.kind = ErrPermissionDenied
+	case codes.InvalidArgument:
+		e.kind = ErrInvalidArgument
+		for _, detail := range st.Details() {
+			if br, ok := detail.(*errdetails.BadRequest); ok {
+				for _, v := range br.GetFieldViolations() {
+					e.Violations = append(e.Violations, Violation{Field: v.GetField(), Description: v.GetDescription()})
+				}
+			}
+		}
+	case codes.ResourceExhausted:
+		e.kind = ErrQuotaExceeded
+		if login {
+			e.kind = ErrLocked
+			e.RetryAfter = retryAfter
+		}
+	case codes.Unavailable:
+		e.kind = ErrUnavailable
+	case codes.Canceled:
+		return context.Canceled
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	}
+	return e
+}
+
+// hasReason tells whether st has an ErrorInfo detail of the server with
+// reason
+func hasReason(st *status.Status, reason string) bool {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetDomain() == server.ErrorDomain && info.GetReason() == reason {
+			return true
+		}
+	}
+	return false
+}
@@ -1,15 +1,41 @@
 package pkg
 
 import (
+	"context"
+	"fmt"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sync"
+	"time"
 )
 
 // Logger on case of changing logger in the future
 type Logger interface {
-	Info(msg string)
-	Fatal(msg string)
-	Debug(msg string)
-	Warn(msg string)
+	Info(msg string, fields ...Field)
+	Fatal(msg string, fields ...Field)
+	Debug(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+	// With returns a logger that adds fields to every message
+	With(fields ...Field) Logger
+}
+
+// Field is a key-value pair attached to a log message
+type Field = zap.Field
+
+// String creates a string field
+func String(key, value string) Field {
+	return zap.String(key, value)
+}
+
+// Duration creates a duration field
+func Duration(key string, value time.Duration) Field {
+	return zap.Duration(key, value)
+}
+
+// Err creates an error field
+func Err(err error) Field {
+	return zap.Error(err)
 }
 
 type logger struct {
@@ -20,18 +46,103 @@ func New(lg *zap.Logger) Logger {
 	return &logger{l: lg}
 }
 
-func (l logger) Info(msg string) {
-	l.l.Info(msg)
+// NewZap creates a zap logger with the level (debug, info, warn, error)
+// and the format (json, console).
+func NewZap(level, format string) (Logger, error) {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg zap.Config
+	switch format {
+	case "json":
+		cfg = zap.NewProductionConfig()
+	case "console":
+		cfg = zap.NewDevelopmentConfig()
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	cfg.Level = zap.NewAtomicLevelAt(lvl)
+
+	lg, err := cfg.Build()
+	if err != nil {
+		return nil, err
+	}
+	return New(lg), nil
+}
+
+// NewNop creates a logger that discards everything
+func NewNop() Logger {
+	return New(zap.NewNop())
+}
+
+func (l logger) Info(msg string, fields ...Field) {
+	l.l.Info(msg, fields...)
+}
+
+func (l logger) Fatal(msg string, fields ...Field) {
+	l.l.Fatal(msg, fields...)
+}
+
+func (l logger) Debug(msg string, fields ...Field) {
+	l.l.Debug(msg, fields...)
+}
+
+func (l logger) Warn(msg string, fields ...Field) {
+	l.l.Warn(msg, fields...)
+}
+
+func (l logger) Error(msg string, fields ...Field) {
+	l.l.Error(msg, fields...)
+}
+
+func (l logger) With(fields ...Field) Logger {
+	return logger{l: l.l.With(fields...)}
+}
+
+type loggerKey struct{}
+
+// loggerHolder lets fields found while handling a request, like the user,
+// be added to the request logger.
+type loggerHolder struct {
+	mu sync.Mutex
+	l  Logger
+}
+
+// ContextWithLogger returns a copy of ctx carrying the logger
+func ContextWithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, &loggerHolder{l: l})
 }
 
-func (l logger) Fatal(msg string) {
-	l.l.Fatal(msg)
+// LoggerFromContext returns the logger carried by ctx or fallback
+func LoggerFromContext(ctx context.Context, fallback Logger) Logger {
+	if h, ok := ctx.Value(loggerKey{}).(*loggerHolder); ok {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.l
+	}
+	if fallback == nil {
+		return NewNop()
+	}
+	return fallback
 }
 
-func (l logger) Debug(msg string) {
-	l.l.Debug(msg)
+// AddLoggerFields adds fields to the logger carried by ctx
+func AddLoggerFields(ctx context.Context, fields ...Field) {
+	if h, ok := ctx.Value(loggerKey{}).(*loggerHolder); ok {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.l = h.l.With(fields...)
+	}
 }
 
-func (l logger) Warn(msg string) {
-	l.l.Warn(msg)
+// RedactToken hides all but the first characters of a session token so that
+// it can be logged.
+func RedactToken(token string) string {
+	const visible = 4
+	if len(token) <= visible {
+		return "****"
+	}
+	return token[:visible] + "****"
 }
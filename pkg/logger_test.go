@@ -0,0 +1,19 @@
+package pkg
+
+import "testing"
+
+func TestRedactToken(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{token: "6ba7b810-9dad-11d1-80b4-00c04fd430c8", want: "6ba7****"},
+		{token: "abc", want: "****"},
+		{token: "", want: "****"},
+	}
+	for _, tt := range tests {
+		if got := RedactToken(tt.token); got != tt.want {
+			t.Errorf("RedactToken(%q) = %q, want %q", tt.token, got, tt.want)
+		}
+	}
+}